@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -158,3 +159,98 @@ func (a *trackingAccumulator) onDelivery(info telegraf.DeliveryInfo) {
 		panic("channel is full")
 	}
 }
+
+// detachableAccumulator passes metrics to the wrapped accumulator until it is
+// detached and drops them afterwards.  It is used for collections that may be
+// abandoned while still running.
+type detachableAccumulator struct {
+	telegraf.Accumulator
+
+	mu       sync.RWMutex
+	detached bool
+}
+
+func newDetachableAccumulator(acc telegraf.Accumulator) *detachableAccumulator {
+	return &detachableAccumulator{Accumulator: acc}
+}
+
+// detach drops all metrics added from now on.  Metrics being added while
+// detaching are passed on before detach returns.
+func (ac *detachableAccumulator) detach() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.detached = true
+}
+
+func (ac *detachableAccumulator) AddFields(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	if !ac.detached {
+		ac.Accumulator.AddFields(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *detachableAccumulator) AddGauge(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	if !ac.detached {
+		ac.Accumulator.AddGauge(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *detachableAccumulator) AddCounter(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	if !ac.detached {
+		ac.Accumulator.AddCounter(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *detachableAccumulator) AddSummary(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	if !ac.detached {
+		ac.Accumulator.AddSummary(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *detachableAccumulator) AddHistogram(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	if !ac.detached {
+		ac.Accumulator.AddHistogram(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *detachableAccumulator) AddMetric(m telegraf.Metric) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	if !ac.detached {
+		ac.Accumulator.AddMetric(m)
+	}
+}
@@ -1,8 +1,10 @@
 package agent
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"runtime"
@@ -14,12 +16,32 @@ import (
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 )
 
 // Agent runs a set of plugins.
 type Agent struct {
 	Config *config.Config
+
+	// RequestReload is called by the control API to request a reload of the
+	// configuration.  Reloading via the API is unavailable if nil.
+	RequestReload func()
+
+	// running holds the units of the pipeline while Run is active and is used
+	// to apply configuration changes without restarting the agent.
+	running   *runningUnits
+	runningMu sync.Mutex
+}
+
+// runningUnits are the units of a running agent.
+type runningUnits struct {
+	ctx       context.Context
+	inputs    *inputUnit
+	pipeline  *pipelineUnit
+	outputs   *outputUnit
+	named     []*namedPipelineUnit
+	swapUnits chan<- *pipelineSwap
 }
 
 // NewAgent returns an Agent for the given Config.
@@ -31,6 +53,8 @@ func NewAgent(config *config.Config) (*Agent, error) {
 }
 
 // inputUnit is a group of input plugins and the shared channel they write to.
+// Inputs selecting a named pipeline write to the source channel of the
+// pipeline instead.
 //
 // ┌───────┐
 // │ Input │───┐
@@ -43,16 +67,50 @@ func NewAgent(config *config.Config) (*Agent, error) {
 // └───────┘
 type inputUnit struct {
 	dst    chan<- telegraf.Metric
+	routes map[string]chan<- telegraf.Metric
 	inputs []*models.RunningInput
+
+	// Used by runInputs to start and stop individual inputs.
+	sync.Mutex
+	wg     sync.WaitGroup
+	stop   map[*models.RunningInput]func()
+	gather map[*models.RunningInput]chan struct{}
 }
 
 //  ______     ┌───────────┐     ______
 // ()_____)──▶ │ Processor │──▶ ()_____)
 //             └───────────┘
+//
+// Units without a processor fan out the metrics to parallel branches instead.
 type processorUnit struct {
 	src       <-chan telegraf.Metric
 	dst       chan<- telegraf.Metric
 	processor *models.RunningProcessor
+	fan       *fanUnit
+}
+
+// fanUnit copies each metric to the branches with a processor selecting the
+// metric, the other metrics skip the branches.  The metrics leaving the
+// branches are merged into the sink channel.
+//
+//                 ┌────────┐
+//            ┌──▶ │ Branch │───┐
+//            │    └────────┘   │
+//  ______    │    ┌────────┐   │     ______
+// ()_____)───┼──▶ │ Branch │───┼──▶ ()_____)
+//            │    └────────┘   │
+//            └─────────────────┘
+type fanUnit struct {
+	branches []*branchUnit
+}
+
+// branchUnit is a chain of processors running in parallel to other branches.
+// The processor units of the branch are run like the units of the main chain.
+type branchUnit struct {
+	name       string
+	processors []*models.RunningProcessor
+	src        chan<- telegraf.Metric
+	dst        <-chan telegraf.Metric
 }
 
 // aggregatorUnit is a group of Aggregators and their source and sink channels.
@@ -75,6 +133,13 @@ type aggregatorUnit struct {
 	aggC        chan<- telegraf.Metric
 	outputC     chan<- telegraf.Metric
 	aggregators []*models.RunningAggregator
+
+	// resumed are aggregators taken over from a previous unit, they keep
+	// their current aggregation window.
+	resumed map[*models.RunningAggregator]bool
+	// kept are aggregators handed over to a new unit, they are not pushed
+	// when this unit stops.
+	kept map[*models.RunningAggregator]bool
 }
 
 // outputUnit is a group of Outputs and their source channel.  Metrics on the
@@ -92,6 +157,48 @@ type aggregatorUnit struct {
 type outputUnit struct {
 	src     <-chan telegraf.Metric
 	outputs []*models.RunningOutput
+
+	// Used by runOutputs to start and stop individual outputs.
+	sync.RWMutex
+	ctx   context.Context
+	wg    sync.WaitGroup
+	stop  map[*models.RunningOutput]func()
+	flush map[*models.RunningOutput]chan struct{}
+}
+
+// pipelineUnit is the chain of processors and aggregators between the inputs
+// and the outputs.  The unit writes to its own sink channel, so it can be
+// replaced while the agent is running without closing the output channel.
+//
+//  ______     ┌────────────┐     ┌─────────────┐     ┌────────────┐     ______
+// ()_____)──▶ │ Processors │──▶ │ Aggregators │──▶ │ Processors │──▶ ()_____)
+//             └────────────┘     └─────────────┘     └────────────┘
+type pipelineUnit struct {
+	src chan<- telegraf.Metric
+	dst <-chan telegraf.Metric
+	pu  []*processorUnit
+	au  *aggregatorUnit
+	apu []*processorUnit
+}
+
+// namedPipelineUnit is a named pipeline with its own outputs, fed by the
+// inputs selecting it.
+//
+//  ______     ┌──────────┐     ______     ┌─────────┐
+// ()_____)──▶ │ Pipeline │──▶ ()_____)──▶ │ Outputs │
+//             └──────────┘                └─────────┘
+type namedPipelineUnit struct {
+	name     string
+	src      chan telegraf.Metric
+	dst      chan<- telegraf.Metric
+	pipeline *pipelineUnit
+	outputs  *outputUnit
+}
+
+// pipelineSwap requests runPipeline to replace the running pipeline unit.
+type pipelineSwap struct {
+	unit *pipelineUnit
+	done chan struct{}
 }
 
 // Run starts and runs the Agent until the context is done.
@@ -101,6 +208,26 @@ func (a *Agent) Run(ctx context.Context) error {
 		time.Duration(a.Config.Agent.Interval), a.Config.Agent.Quiet,
 		a.Config.Agent.Hostname, time.Duration(a.Config.Agent.FlushInterval))
 
+	if a.Config.Agent.APIAddress != "" {
+		api, err := a.startAPI(a.Config.Agent.APIAddress)
+		if err != nil {
+			return err
+		}
+		defer api.stop()
+	}
+
+	if a.Config.Agent.MetricsAddress != "" {
+		metrics, err := a.startMetrics(a.Config.Agent.MetricsAddress)
+		if err != nil {
+			return err
+		}
+		defer metrics.stop()
+	}
+
+	// The log level overrides must not outlive the plugins, e.g. when the
+	// configuration is loaded again after stopping.
+	defer a.releaseLogLevels()
+
 	log.Printf("D! [agent] Initializing plugins")
 	err := a.initPlugins()
 	if err != nil {
@@ -115,33 +242,45 @@ func (a *Agent) Run(ctx context.Context) error {
 		return err
 	}
 
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		aggC := next
-		if len(a.Config.AggProcessors) != 0 {
-			aggC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au = a.startAggregators(aggC, next, a.Config.Aggregators)
+	pu, err := a.startPipeline(a.Config.Processors, a.Config.Aggregators, a.Config.AggProcessors)
+	if err != nil {
+		return err
 	}
 
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
-		if err != nil {
-			return err
-		}
+	named, err := a.startNamedPipelines(ctx, a.Config.Pipelines)
+	if err != nil {
+		return err
 	}
 
-	iu, err := a.startInputs(next, a.Config.Inputs)
+	inputC := make(chan telegraf.Metric, 100)
+	iu, err := a.startInputs(inputC, namedRoutes(named), a.Config.Inputs)
 	if err != nil {
 		return err
 	}
 
+	swapUnits := make(chan *pipelineSwap)
+	a.runningMu.Lock()
+	a.running = &runningUnits{
+		ctx:       ctx,
+		inputs:    iu,
+		pipeline:  pu,
+		outputs:   ou,
+		named:     named,
+		swapUnits: swapUnits,
+	}
+	a.runningMu.Unlock()
+
+	// Stop accepting configuration changes before shutting down, waiting for
+	// any reload in progress to finish.
+	inputCtx, cancelInputs := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+		a.runningMu.Lock()
+		a.running = nil
+		a.runningMu.Unlock()
+		cancelInputs()
+	}()
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -149,32 +288,31 @@ func (a *Agent) Run(ctx context.Context) error {
 		a.runOutputs(ou)
 	}()
 
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(apu)
-		}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.runPipeline(startTime, inputC, next, pu, swapUnits)
+	}()
 
+	for _, np := range named {
 		wg.Add(1)
-		go func() {
+		go func(np *namedPipelineUnit) {
 			defer wg.Done()
-			a.runAggregators(startTime, au)
-		}()
-	}
+			a.runOutputs(np.outputs)
+		}(np)
 
-	if pu != nil {
+		// Named pipelines are not replaced on reload.
 		wg.Add(1)
-		go func() {
+		go func(np *namedPipelineUnit) {
 			defer wg.Done()
-			a.runProcessors(pu)
-		}()
+			a.runPipeline(startTime, np.src, np.dst, np.pipeline, nil)
+		}(np)
 	}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		a.runInputs(ctx, startTime, iu)
+		a.runInputs(inputCtx, startTime, iu)
 	}()
 
 	wg.Wait()
@@ -183,8 +321,59 @@ func (a *Agent) Run(ctx context.Context) error {
 	return err
 }
 
+// releaseLogLevels removes the log level overrides of all plugins of the
+// configuration.
+func (a *Agent) releaseLogLevels() {
+	for _, input := range a.Config.Inputs {
+		releaseLogLevel(input.Log())
+	}
+	releasePipelineLogLevels(a.Config.Processors, a.Config.Aggregators, a.Config.AggProcessors, a.Config.Outputs)
+	for _, p := range a.Config.Pipelines {
+		releasePipelineLogLevels(p.Processors, p.Aggregators, p.AggProcessors, p.Outputs)
+	}
+}
+
+func releasePipelineLogLevels(
+	processors []*models.RunningProcessor,
+	aggregators []*models.RunningAggregator,
+	aggProcessors []*models.RunningProcessor,
+	outputs []*models.RunningOutput,
+) {
+	for _, processor := range processors {
+		releaseLogLevel(processor.Log())
+	}
+	for _, aggregator := range aggregators {
+		releaseLogLevel(aggregator.Log())
+	}
+	for _, processor := range aggProcessors {
+		releaseLogLevel(processor.Log())
+	}
+	for _, output := range outputs {
+		releaseLogLevel(output.Log())
+	}
+}
+
+// releaseLogLevel removes the log level override of a stopped plugin.
+func releaseLogLevel(l telegraf.Logger) {
+	if logger, ok := l.(*models.Logger); ok {
+		logger.ReleaseLevel()
+	}
+}
+
+// useMemoryBuffers keeps the metrics of all outputs in memory, so testing or
+// running a single gather neither replays nor consumes the disk buffer of a
+// running agent.
+func (a *Agent) useMemoryBuffers() {
+	for _, output := range a.Config.AllOutputs() {
+		output.MemoryBuffer = true
+	}
+}
+
 // initPlugins runs the Init function on plugins.
 func (a *Agent) initPlugins() error {
+	if err := a.Config.ResolveSecrets(); err != nil {
+		return err
+	}
 	for _, input := range a.Config.Inputs {
 		err := input.Init()
 		if err != nil {
@@ -220,41 +409,68 @@ func (a *Agent) initPlugins() error {
 				output.Config.Name, err)
 		}
 	}
+	if err := models.LinkDeadLetters(a.Config.Outputs); err != nil {
+		return err
+	}
+	return a.initPipelines()
+}
+
+// initPipelines runs the Init function on the plugins of the named pipelines.
+func (a *Agent) initPipelines() error {
+	if err := a.Config.CheckPipelines(); err != nil {
+		return err
+	}
+	for _, p := range a.Config.Pipelines {
+		for _, processor := range p.Processors {
+			if err := processor.Init(); err != nil {
+				return fmt.Errorf("could not initialize processor %s of pipeline %q: %v",
+					processor.Config.Name, p.Name, err)
+			}
+		}
+		for _, aggregator := range p.Aggregators {
+			if err := aggregator.Init(); err != nil {
+				return fmt.Errorf("could not initialize aggregator %s of pipeline %q: %v",
+					aggregator.Config.Name, p.Name, err)
+			}
+		}
+		for _, processor := range p.AggProcessors {
+			if err := processor.Init(); err != nil {
+				return fmt.Errorf("could not initialize processor %s of pipeline %q: %v",
+					processor.Config.Name, p.Name, err)
+			}
+		}
+		for _, output := range p.Outputs {
+			if err := output.Init(); err != nil {
+				return fmt.Errorf("could not initialize output %s of pipeline %q: %v",
+					output.Config.Name, p.Name, err)
+			}
+		}
+		if err := models.LinkDeadLetters(p.Outputs); err != nil {
+			return fmt.Errorf("pipeline %q: %w", p.Name, err)
+		}
+	}
 	return nil
 }
 
 func (a *Agent) startInputs(
 	dst chan<- telegraf.Metric,
+	routes map[string]chan<- telegraf.Metric,
 	inputs []*models.RunningInput,
 ) (*inputUnit, error) {
 	log.Printf("D! [agent] Starting service inputs")
 
 	unit := &inputUnit{
-		dst: dst,
+		dst:    dst,
+		routes: routes,
+		stop:   make(map[*models.RunningInput]func()),
+		gather: make(map[*models.RunningInput]chan struct{}),
 	}
 
 	for _, input := range inputs {
-		if si, ok := input.Input.(telegraf.ServiceInput); ok {
-			// Service input plugins are not normally subject to timestamp
-			// rounding except for when precision is set on the input plugin.
-			//
-			// This only applies to the accumulator passed to Start(), the
-			// Gather() accumulator does apply rounding according to the
-			// precision and interval agent/plugin settings.
-			var interval time.Duration
-			var precision time.Duration
-			if input.Config.Precision != 0 {
-				precision = input.Config.Precision
-			}
-
-			acc := NewAccumulator(input, dst)
-			acc.SetPrecision(getPrecision(precision, interval))
-
-			err := si.Start(acc)
-			if err != nil {
-				stopServiceInputs(unit.inputs)
-				return nil, fmt.Errorf("starting input %s: %w", input.LogName(), err)
-			}
+		err := startServiceInput(unit.dstFor(input), input)
+		if err != nil {
+			stopServiceInputs(unit.inputs)
+			return nil, err
 		}
 		unit.inputs = append(unit.inputs, input)
 	}
@@ -262,6 +478,53 @@ func (a *Agent) startInputs(
 	return unit, nil
 }
 
+// dstFor returns the channel the input writes to, the source channel of the
+// named pipeline selected by the input or the shared channel.
+func (u *inputUnit) dstFor(input *models.RunningInput) chan<- telegraf.Metric {
+	if input.Config.Pipeline == "" {
+		return u.dst
+	}
+	return u.routes[input.Config.Pipeline]
+}
+
+// close closes the shared channel and the source channels of the named
+// pipelines once all inputs are stopped.
+func (u *inputUnit) close() {
+	close(u.dst)
+	for _, dst := range u.routes {
+		close(dst)
+	}
+}
+
+// startServiceInput calls Start if the input is a service input.
+func startServiceInput(dst chan<- telegraf.Metric, input *models.RunningInput) error {
+	si, ok := input.Input.(telegraf.ServiceInput)
+	if !ok {
+		return nil
+	}
+
+	// Service input plugins are not normally subject to timestamp
+	// rounding except for when precision is set on the input plugin.
+	//
+	// This only applies to the accumulator passed to Start(), the
+	// Gather() accumulator does apply rounding according to the
+	// precision and interval agent/plugin settings.
+	var interval time.Duration
+	var precision time.Duration
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
+	}
+
+	acc := NewAccumulator(input, dst)
+	acc.SetPrecision(getPrecision(precision, interval))
+
+	err := si.Start(acc)
+	if err != nil {
+		return fmt.Errorf("starting input %s: %w", input.LogName(), err)
+	}
+	return nil
+}
+
 // runInputs starts and triggers the periodic gather for Inputs.
 //
 // When the context is done the timers are stopped and this function returns
@@ -271,51 +534,113 @@ func (a *Agent) runInputs(
 	startTime time.Time,
 	unit *inputUnit,
 ) {
-	var wg sync.WaitGroup
+	unit.Lock()
 	for _, input := range unit.inputs {
-		// Overwrite agent interval if this plugin has its own.
-		interval := time.Duration(a.Config.Agent.Interval)
-		if input.Config.Interval != 0 {
-			interval = input.Config.Interval
-		}
+		a.runInput(ctx, startTime, unit, input)
+	}
+	unit.Unlock()
 
-		// Overwrite agent precision if this plugin has its own.
-		precision := time.Duration(a.Config.Agent.Precision)
-		if input.Config.Precision != 0 {
-			precision = input.Config.Precision
-		}
+	<-ctx.Done()
+	unit.wg.Wait()
 
-		// Overwrite agent collection_jitter if this plugin has its own.
-		jitter := time.Duration(a.Config.Agent.CollectionJitter)
-		if input.Config.CollectionJitter != 0 {
-			jitter = input.Config.CollectionJitter
-		}
+	log.Printf("D! [agent] Stopping service inputs")
+	stopServiceInputs(unit.inputs)
 
-		var ticker Ticker
-		if a.Config.Agent.RoundInterval {
-			ticker = NewAlignedTicker(startTime, interval, jitter)
-		} else {
-			ticker = NewUnalignedTicker(interval, jitter)
+	unit.close()
+	log.Printf("D! [agent] Input channel closed")
+}
+
+// runInput starts the gather loop of a single input until the context is done
+// or the input is stopped.  The unit must be locked by the caller.
+func (a *Agent) runInput(
+	ctx context.Context,
+	startTime time.Time,
+	unit *inputUnit,
+	input *models.RunningInput,
+) {
+	// Overwrite agent interval if this plugin has its own.
+	interval := time.Duration(a.Config.Agent.Interval)
+	if input.Config.Interval != 0 {
+		interval = input.Config.Interval
+	}
+
+	// Overwrite agent precision if this plugin has its own.
+	precision := time.Duration(a.Config.Agent.Precision)
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
+	}
+
+	// Overwrite agent collection_jitter if this plugin has its own.
+	jitter := time.Duration(a.Config.Agent.CollectionJitter)
+	if input.Config.CollectionJitter != 0 {
+		jitter = input.Config.CollectionJitter
+	}
+
+	var ticker Ticker
+	if input.Config.Schedule != "" {
+		// The schedule has been validated when loading the configuration.
+		schedule, err := models.ParseSchedule(input.Config.Schedule, input.Config.ScheduleTimezone)
+		if err != nil {
+			input.Log().Errorf("Invalid schedule, not starting input: %v", err)
+			return
 		}
-		defer ticker.Stop()
+		ticker = NewCronTicker(schedule, jitter)
+	} else if a.Config.Agent.RoundInterval {
+		ticker = NewAlignedTicker(startTime, interval, jitter)
+	} else {
+		ticker = NewUnalignedTicker(interval, jitter)
+	}
 
-		acc := NewAccumulator(input, unit.dst)
-		acc.SetPrecision(getPrecision(precision, interval))
+	acc := NewAccumulator(input, unit.dstFor(input))
+	acc.SetPrecision(getPrecision(precision, interval))
 
-		wg.Add(1)
-		go func(input *models.RunningInput) {
-			defer wg.Done()
-			a.gatherLoop(ctx, acc, input, ticker, interval)
-		}(input)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	gatherNow := make(chan struct{}, 1)
+
+	unit.wg.Add(1)
+	go func() {
+		defer unit.wg.Done()
+		defer close(done)
+		defer ticker.Stop()
+		a.gatherLoop(ctx, acc, input, ticker, interval, gatherNow)
+	}()
+
+	unit.stop[input] = func() {
+		cancel()
+		<-done
 	}
+	unit.gather[input] = gatherNow
+}
 
-	wg.Wait()
+// addInput starts an input while the agent is running.
+func (a *Agent) addInput(ctx context.Context, unit *inputUnit, input *models.RunningInput) {
+	unit.Lock()
+	defer unit.Unlock()
 
-	log.Printf("D! [agent] Stopping service inputs")
-	stopServiceInputs(unit.inputs)
+	a.runInput(ctx, time.Now(), unit, input)
+	unit.inputs = append(unit.inputs, input)
+}
 
-	close(unit.dst)
-	log.Printf("D! [agent] Input channel closed")
+// removeInput stops an input while the agent is running, waiting for an
+// ongoing Gather call to complete.
+func (a *Agent) removeInput(unit *inputUnit, input *models.RunningInput) {
+	unit.Lock()
+	defer unit.Unlock()
+
+	for i, ri := range unit.inputs {
+		if ri == input {
+			unit.inputs = append(unit.inputs[:i], unit.inputs[i+1:]...)
+			break
+		}
+	}
+
+	if stop, ok := unit.stop[input]; ok {
+		stop()
+		delete(unit.stop, input)
+	}
+	delete(unit.gather, input)
+	stopServiceInputs([]*models.RunningInput{input})
 }
 
 // testStartInputs is a variation of startInputs for use in --test and --once
@@ -323,12 +648,14 @@ func (a *Agent) runInputs(
 // successfully started.
 func (a *Agent) testStartInputs(
 	dst chan<- telegraf.Metric,
+	routes map[string]chan<- telegraf.Metric,
 	inputs []*models.RunningInput,
 ) *inputUnit {
 	log.Printf("D! [agent] Starting service inputs")
 
 	unit := &inputUnit{
-		dst: dst,
+		dst:    dst,
+		routes: routes,
 	}
 
 	for _, input := range inputs {
@@ -337,7 +664,7 @@ func (a *Agent) testStartInputs(
 			// This only applies to the accumulator passed to Start(), the
 			// Gather() accumulator does apply rounding according to the
 			// precision agent setting.
-			acc := NewAccumulator(input, dst)
+			acc := NewAccumulator(input, unit.dstFor(input))
 			acc.SetPrecision(time.Nanosecond)
 
 			err := si.Start(acc)
@@ -397,7 +724,7 @@ func (a *Agent) testRunInputs(
 				time.Sleep(500 * time.Millisecond)
 			}
 
-			acc := NewAccumulator(input, unit.dst)
+			acc := NewAccumulator(input, unit.dstFor(input))
 			acc.SetPrecision(getPrecision(precision, interval))
 
 			if err := input.Input.Gather(acc); err != nil {
@@ -412,7 +739,7 @@ func (a *Agent) testRunInputs(
 	log.Printf("D! [agent] Stopping service inputs")
 	stopServiceInputs(unit.inputs)
 
-	close(unit.dst)
+	unit.close()
 	log.Printf("D! [agent] Input channel closed")
 }
 
@@ -432,24 +759,59 @@ func stopRunningOutputs(outputs []*models.RunningOutput) {
 	}
 }
 
-// gather runs an input's gather function periodically until the context is
-// done.
+// maxSkippedCollections is the number of collections skipped in a row while an
+// abandoned collection has not completed.  The next collection is started
+// even though the abandoned one is still running.
+const maxSkippedCollections = 3
+
+// gather runs an input's gather function periodically, or when requested on
+// the gatherNow channel, until the context is done.
 func (a *Agent) gatherLoop(
 	ctx context.Context,
 	acc telegraf.Accumulator,
 	input *models.RunningInput,
 	ticker Ticker,
 	interval time.Duration,
+	gatherNow <-chan struct{},
 ) {
 	defer panicRecover(input)
 
+	// The result of a collection abandoned after its timeout.  Collections
+	// are skipped until it completes, but at most maxSkippedCollections in a
+	// row so a hung Gather does not stop the input forever.
+	var abandoned <-chan error
+	var skipped int
+	gather := func() {
+		if abandoned != nil {
+			select {
+			case <-abandoned:
+				abandoned = nil
+			default:
+				if skipped < maxSkippedCollections {
+					skipped++
+					acc.AddError(fmt.Errorf("abandoned collection has not completed; scheduled collection skipped (%d of %d)",
+						skipped, maxSkippedCollections))
+					return
+				}
+				log.Printf("W! [%s] Abandoned collection has not completed after %d skipped collections; starting a new collection",
+					input.LogName(), skipped)
+			}
+		}
+		skipped = 0
+
+		var err error
+		abandoned, err = a.gatherOnce(acc, input, ticker, interval)
+		if err != nil {
+			acc.AddError(err)
+		}
+	}
+
 	for {
 		select {
 		case <-ticker.Elapsed():
-			err := a.gatherOnce(acc, input, ticker, interval)
-			if err != nil {
-				acc.AddError(err)
-			}
+			gather()
+		case <-gatherNow:
+			gather()
 		case <-ctx.Done():
 			return
 		}
@@ -457,14 +819,28 @@ func (a *Agent) gatherLoop(
 }
 
 // gatherOnce runs the input's Gather function once, logging a warning each
-// interval it fails to complete before.
+// interval it fails to complete before.  If the input has a gather timeout and
+// Gather does not complete in time, the collection is abandoned and the channel
+// receiving its result is returned.  Metrics of an abandoned collection are
+// dropped.
 func (a *Agent) gatherOnce(
 	acc telegraf.Accumulator,
 	input *models.RunningInput,
 	ticker Ticker,
 	interval time.Duration,
-) error {
-	done := make(chan error)
+) (<-chan error, error) {
+	var timeout <-chan time.Time
+	var detachable *detachableAccumulator
+	if input.Config.GatherTimeout > 0 {
+		timer := time.NewTimer(input.Config.GatherTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+
+		detachable = newDetachableAccumulator(acc)
+		acc = detachable
+	}
+
+	done := make(chan error, 1)
 	go func() {
 		done <- input.Gather(acc)
 	}()
@@ -478,13 +854,17 @@ func (a *Agent) gatherOnce(
 	for {
 		select {
 		case err := <-done:
-			return err
+			return nil, err
 		case <-slowWarning.C:
 			log.Printf("W! [%s] Collection took longer than expected; not complete after interval of %s",
 				input.LogName(), interval)
 		case <-ticker.Elapsed():
 			log.Printf("D! [%s] Previous collection has not completed; scheduled collection skipped",
 				input.LogName())
+		case <-timeout:
+			detachable.detach()
+			input.GatherTimeouts.Incr(1)
+			return done, fmt.Errorf("collection abandoned after timeout of %s", input.Config.GatherTimeout)
 		}
 	}
 }
@@ -494,6 +874,92 @@ func (a *Agent) gatherOnce(
 func (a *Agent) startProcessors(
 	dst chan<- telegraf.Metric,
 	processors models.RunningProcessors,
+) (chan<- telegraf.Metric, []*processorUnit, error) {
+	// Processors of a branch run in parallel at the position of the lowest
+	// order of all branches, the processors of the main chain with a lower
+	// order run before the branches and all others after them.
+	var main models.RunningProcessors
+	branches := make(map[string]models.RunningProcessors)
+	var names []string
+	var position int64
+	for _, processor := range processors {
+		name := processor.Config.Branch
+		if name == "" {
+			main = append(main, processor)
+			continue
+		}
+		if len(branches) == 0 || processor.Config.Order < position {
+			position = processor.Config.Order
+		}
+		if _, ok := branches[name]; !ok {
+			names = append(names, name)
+		}
+		branches[name] = append(branches[name], processor)
+	}
+	if len(branches) == 0 {
+		return a.startChain(dst, main)
+	}
+	sort.Strings(names)
+
+	var before, after models.RunningProcessors
+	for _, processor := range main {
+		if processor.Config.Order < position {
+			before = append(before, processor)
+		} else {
+			after = append(after, processor)
+		}
+	}
+
+	var units []*processorUnit
+	stop := func() {
+		for _, u := range units {
+			if u.processor != nil {
+				u.processor.Stop()
+			}
+		}
+	}
+
+	next, afterUnits, err := a.startChain(dst, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	units = append(units, afterUnits...)
+
+	fan := &fanUnit{}
+	for _, name := range names {
+		branchDst := make(chan telegraf.Metric, 100)
+		branchSrc, branchUnits, err := a.startChain(branchDst, branches[name])
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		units = append(units, branchUnits...)
+		fan.branches = append(fan.branches, &branchUnit{
+			name:       name,
+			processors: branches[name],
+			src:        branchSrc,
+			dst:        branchDst,
+		})
+	}
+	src := make(chan telegraf.Metric, 100)
+	units = append(units, &processorUnit{src: src, dst: next, fan: fan})
+
+	next, beforeUnits, err := a.startChain(src, before)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	units = append(units, beforeUnits...)
+
+	return next, units, nil
+}
+
+// startChain sets up a linear chain of processors sorted by order writing to
+// dst and calls Start on all processors.  The source channel of the chain is
+// returned, dst itself if there are no processors.
+func (a *Agent) startChain(
+	dst chan<- telegraf.Metric,
+	processors models.RunningProcessors,
 ) (chan<- telegraf.Metric, []*processorUnit, error) {
 	var units []*processorUnit
 
@@ -502,9 +968,10 @@ func (a *Agent) startProcessors(
 		return processors[i].Config.Order > processors[j].Config.Order
 	})
 
-	var src chan telegraf.Metric
+	src := dst
 	for _, processor := range processors {
-		src = make(chan telegraf.Metric, 100)
+		c := make(chan telegraf.Metric, 100)
+		src = c
 		acc := NewAccumulator(processor, dst)
 
 		err := processor.Start(acc)
@@ -517,7 +984,7 @@ func (a *Agent) startProcessors(
 		}
 
 		units = append(units, &processorUnit{
-			src:       src,
+			src:       c,
 			dst:       dst,
 			processor: processor,
 		})
@@ -539,6 +1006,11 @@ func (a *Agent) runProcessors(
 		go func(unit *processorUnit) {
 			defer wg.Done()
 
+			if unit.fan != nil {
+				a.runFan(unit)
+				return
+			}
+
 			acc := NewAccumulator(unit.processor, unit.dst)
 			for m := range unit.src {
 				if err := unit.processor.Add(m, acc); err != nil {
@@ -554,6 +1026,229 @@ func (a *Agent) runProcessors(
 	wg.Wait()
 }
 
+// runFan copies the metrics of the unit to the branches selecting them and
+// merges the metrics leaving the branches until the source channel is closed
+// and all branches are done.
+func (a *Agent) runFan(unit *processorUnit) {
+	var wg sync.WaitGroup
+	for _, branch := range unit.fan.branches {
+		wg.Add(1)
+		go func(branch *branchUnit) {
+			defer wg.Done()
+			for m := range branch.dst {
+				unit.dst <- m
+			}
+		}(branch)
+	}
+
+	selected := make([]*branchUnit, 0, len(unit.fan.branches))
+	for m := range unit.src {
+		selected = selected[:0]
+		for _, branch := range unit.fan.branches {
+			if branch.selects(m) {
+				selected = append(selected, branch)
+			}
+		}
+
+		if len(selected) == 0 {
+			unit.dst <- m
+			continue
+		}
+		for i, branch := range selected {
+			if i == len(selected)-1 {
+				branch.src <- m
+			} else {
+				branch.src <- m.Copy()
+			}
+		}
+	}
+
+	for _, branch := range unit.fan.branches {
+		close(branch.src)
+	}
+	wg.Wait()
+	close(unit.dst)
+	log.Printf("D! [agent] Processor channel closed")
+}
+
+// selects returns true if a processor of the branch selects the metric.
+func (b *branchUnit) selects(m telegraf.Metric) bool {
+	for _, processor := range b.processors {
+		if processor.Selects(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// startPipeline sets up the processor and aggregator chain and calls Start on
+// all processors.  If an error occurs any started processors are Stopped.
+func (a *Agent) startPipeline(
+	processors models.RunningProcessors,
+	aggregators []*models.RunningAggregator,
+	aggProcessors models.RunningProcessors,
+) (*pipelineUnit, error) {
+	dst := make(chan telegraf.Metric, 100)
+	unit := &pipelineUnit{dst: dst}
+
+	next := chan<- telegraf.Metric(dst)
+	var err error
+	if len(aggregators) != 0 {
+		aggC := next
+		if len(aggProcessors) != 0 {
+			aggC, unit.apu, err = a.startProcessors(next, aggProcessors)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		next, unit.au = a.startAggregators(aggC, next, aggregators)
+	}
+
+	if len(processors) != 0 {
+		next, unit.pu, err = a.startProcessors(next, processors)
+		if err != nil {
+			stopPipeline(unit)
+			return nil, err
+		}
+	}
+
+	unit.src = next
+	return unit, nil
+}
+
+// startNamedPipelines connects the outputs and starts the processors of the
+// named pipelines.  If an error occurs the already started pipelines are
+// stopped.
+func (a *Agent) startNamedPipelines(
+	ctx context.Context,
+	pipelines []*config.Pipeline,
+) ([]*namedPipelineUnit, error) {
+	var units []*namedPipelineUnit
+	stop := func() {
+		for _, u := range units {
+			stopPipeline(u.pipeline)
+			stopRunningOutputs(u.outputs.outputs)
+		}
+	}
+
+	for _, p := range pipelines {
+		log.Printf("D! [agent] Connecting outputs of pipeline %q", p.Name)
+		dst, ou, err := a.startOutputs(ctx, p.Outputs)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("pipeline %q: %w", p.Name, err)
+		}
+
+		pu, err := a.startPipeline(p.Processors, p.Aggregators, p.AggProcessors)
+		if err != nil {
+			stopRunningOutputs(ou.outputs)
+			stop()
+			return nil, fmt.Errorf("pipeline %q: %w", p.Name, err)
+		}
+
+		units = append(units, &namedPipelineUnit{
+			name:     p.Name,
+			src:      make(chan telegraf.Metric, 100),
+			dst:      dst,
+			pipeline: pu,
+			outputs:  ou,
+		})
+	}
+	return units, nil
+}
+
+// namedRoutes returns the source channels of the named pipelines by name.
+func namedRoutes(units []*namedPipelineUnit) map[string]chan<- telegraf.Metric {
+	routes := make(map[string]chan<- telegraf.Metric, len(units))
+	for _, u := range units {
+		routes[u.name] = u.src
+	}
+	return routes
+}
+
+// runPipeline forwards metrics from the inputs through the pipeline unit to
+// the outputs until the source channel is closed.  The pipeline unit can be
+// replaced at any time using the swap channel; the previous unit is stopped
+// once all its metrics have been written to the outputs.
+func (a *Agent) runPipeline(
+	startTime time.Time,
+	src <-chan telegraf.Metric,
+	dst chan<- telegraf.Metric,
+	unit *pipelineUnit,
+	swap <-chan *pipelineSwap,
+) {
+	done := a.runPipelineUnit(startTime, unit, dst)
+	for {
+		select {
+		case metric, ok := <-src:
+			if !ok {
+				close(unit.src)
+				<-done
+
+				close(dst)
+				log.Printf("D! [agent] Output channel closed")
+				return
+			}
+			unit.src <- metric
+		case req := <-swap:
+			close(unit.src)
+			<-done
+
+			unit = req.unit
+			done = a.runPipelineUnit(time.Now(), unit, dst)
+			close(req.done)
+		}
+	}
+}
+
+// runPipelineUnit runs the processors and aggregators of the unit and writes
+// the resulting metrics to dst.  The returned channel is closed once the
+// source channel of the unit is closed and all metrics have been written.
+func (a *Agent) runPipelineUnit(
+	startTime time.Time,
+	unit *pipelineUnit,
+	dst chan<- telegraf.Metric,
+) <-chan struct{} {
+	var wg sync.WaitGroup
+	if unit.au != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.runProcessors(unit.apu)
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.runAggregators(startTime, unit.au)
+		}()
+	}
+
+	if unit.pu != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.runProcessors(unit.pu)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for metric := range unit.dst {
+			dst <- metric
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
 // startAggregators sets up the aggregator unit and returns the source channel.
 func (a *Agent) startAggregators(
 	aggC chan<- telegraf.Metric,
@@ -580,7 +1275,10 @@ func (a *Agent) runAggregators(
 
 	// Before calling Add, initialize the aggregation window.  This ensures
 	// that any metric created after start time will be aggregated.
-	for _, agg := range a.Config.Aggregators {
+	for _, agg := range unit.aggregators {
+		if unit.resumed[agg] {
+			continue
+		}
 		since, until := updateWindow(startTime, a.Config.Agent.RoundInterval, agg.Period())
 		agg.UpdateWindow(since, until)
 	}
@@ -591,7 +1289,7 @@ func (a *Agent) runAggregators(
 		defer wg.Done()
 		for metric := range unit.src {
 			var dropOriginal bool
-			for _, agg := range a.Config.Aggregators {
+			for _, agg := range unit.aggregators {
 				if ok := agg.Add(metric); ok {
 					dropOriginal = true
 				}
@@ -606,7 +1304,7 @@ func (a *Agent) runAggregators(
 		cancel()
 	}()
 
-	for _, agg := range a.Config.Aggregators {
+	for _, agg := range unit.aggregators {
 		wg.Add(1)
 		go func(agg *models.RunningAggregator) {
 			defer wg.Done()
@@ -616,7 +1314,7 @@ func (a *Agent) runAggregators(
 
 			acc := NewAccumulator(agg, unit.aggC)
 			acc.SetPrecision(getPrecision(precision, interval))
-			a.push(ctx, agg, acc)
+			a.push(ctx, agg, acc, unit.kept[agg])
 		}(agg)
 	}
 
@@ -645,11 +1343,13 @@ func updateWindow(start time.Time, roundInterval bool, period time.Duration) (ti
 	return since, until
 }
 
-// push runs the push for a single aggregator every period.
+// push runs the push for a single aggregator every period.  When the context
+// is done the aggregator is pushed a final time unless it is kept for reuse.
 func (a *Agent) push(
 	ctx context.Context,
 	aggregator *models.RunningAggregator,
 	acc telegraf.Accumulator,
+	kept bool,
 ) {
 	for {
 		// Ensures that Push will be called for each period, even if it has
@@ -663,7 +1363,9 @@ func (a *Agent) push(
 			aggregator.Push(acc)
 			break
 		case <-ctx.Done():
-			aggregator.Push(acc)
+			if !kept {
+				aggregator.Push(acc)
+			}
 			return
 		}
 	}
@@ -676,7 +1378,11 @@ func (a *Agent) startOutputs(
 	outputs []*models.RunningOutput,
 ) (chan<- telegraf.Metric, *outputUnit, error) {
 	src := make(chan telegraf.Metric, 100)
-	unit := &outputUnit{src: src}
+	unit := &outputUnit{
+		src:   src,
+		stop:  make(map[*models.RunningOutput]func()),
+		flush: make(map[*models.RunningOutput]chan struct{}),
+	}
 	for _, output := range outputs {
 		err := a.connectOutput(ctx, output)
 		if err != nil {
@@ -720,62 +1426,120 @@ func (a *Agent) connectOutput(ctx context.Context, output *models.RunningOutput)
 func (a *Agent) runOutputs(
 	unit *outputUnit,
 ) {
-	var wg sync.WaitGroup
-
-	// Start flush loop
-	interval := time.Duration(a.Config.Agent.FlushInterval)
-	jitter := time.Duration(a.Config.Agent.FlushJitter)
-
 	ctx, cancel := context.WithCancel(context.Background())
 
+	unit.Lock()
+	unit.ctx = ctx
 	for _, output := range unit.outputs {
-		interval := interval
-		// Overwrite agent flush_interval if this plugin has its own.
-		if output.Config.FlushInterval != 0 {
-			interval = output.Config.FlushInterval
-		}
-
-		jitter := jitter
-		// Overwrite agent flush_jitter if this plugin has its own.
-		if output.Config.FlushJitter != 0 {
-			jitter = output.Config.FlushJitter
-		}
-
-		wg.Add(1)
-		go func(output *models.RunningOutput) {
-			defer wg.Done()
-
-			ticker := NewRollingTicker(interval, jitter)
-			defer ticker.Stop()
-
-			a.flushLoop(ctx, output, ticker)
-		}(output)
+		a.runOutput(unit, output)
 	}
+	unit.Unlock()
 
 	for metric := range unit.src {
+		unit.RLock()
+		if len(unit.outputs) == 0 {
+			metric.Drop()
+		}
 		for i, output := range unit.outputs {
-			if i == len(a.Config.Outputs)-1 {
+			if i == len(unit.outputs)-1 {
 				output.AddMetric(metric)
 			} else {
 				output.AddMetric(metric.Copy())
 			}
 		}
+		unit.RUnlock()
 	}
 
 	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
+	unit.RLock()
+	for _, output := range unit.outputs {
+		output.DisableRateLimits()
+	}
+	unit.RUnlock()
 	cancel()
-	wg.Wait()
+	unit.wg.Wait()
 
 	log.Println("I! [agent] Stopping running outputs")
 	stopRunningOutputs(unit.outputs)
 }
 
-// flushLoop runs an output's flush function periodically until the context is
-// done.
+// runOutput starts the flush loop of a single output until the context of the
+// unit is done or the output is stopped.  The unit must be locked by the
+// caller.
+func (a *Agent) runOutput(unit *outputUnit, output *models.RunningOutput) {
+	// Overwrite agent flush_interval if this plugin has its own.
+	interval := time.Duration(a.Config.Agent.FlushInterval)
+	if output.Config.FlushInterval != 0 {
+		interval = output.Config.FlushInterval
+	}
+
+	// Overwrite agent flush_jitter if this plugin has its own.
+	jitter := time.Duration(a.Config.Agent.FlushJitter)
+	if output.Config.FlushJitter != 0 {
+		jitter = output.Config.FlushJitter
+	}
+
+	ctx, cancel := context.WithCancel(unit.ctx)
+	done := make(chan struct{})
+	flushNow := make(chan struct{}, 1)
+
+	unit.wg.Add(1)
+	go func() {
+		defer unit.wg.Done()
+		defer close(done)
+
+		ticker := NewRollingTicker(interval, jitter)
+		defer ticker.Stop()
+
+		a.flushLoop(ctx, output, ticker, flushNow)
+	}()
+
+	unit.stop[output] = func() {
+		cancel()
+		<-done
+	}
+	unit.flush[output] = flushNow
+}
+
+// addOutput starts writing metrics to an already connected output while the
+// agent is running.
+func (a *Agent) addOutput(unit *outputUnit, output *models.RunningOutput) {
+	unit.Lock()
+	defer unit.Unlock()
+
+	a.runOutput(unit, output)
+	unit.outputs = append(unit.outputs, output)
+}
+
+// removeOutput stops writing metrics to an output while the agent is running.
+// The buffered metrics are flushed a last time before the output is closed.
+func (a *Agent) removeOutput(unit *outputUnit, output *models.RunningOutput) {
+	unit.Lock()
+	for i, ro := range unit.outputs {
+		if ro == output {
+			unit.outputs = append(unit.outputs[:i], unit.outputs[i+1:]...)
+			break
+		}
+	}
+	stop, ok := unit.stop[output]
+	delete(unit.stop, output)
+	delete(unit.flush, output)
+	unit.Unlock()
+
+	output.DisableRateLimits()
+	if ok {
+		stop()
+	}
+	output.Close()
+}
+
+// flushLoop runs an output's flush function periodically, or when requested on
+// the flushNow channel, until the context is done.
 func (a *Agent) flushLoop(
 	ctx context.Context,
 	output *models.RunningOutput,
 	ticker Ticker,
+	flushNow <-chan struct{},
 ) {
 	logError := func(err error) {
 		if err != nil {
@@ -788,6 +1552,28 @@ func (a *Agent) flushLoop(
 	watchForFlushSignal(flushRequested)
 	defer stopListeningForFlushSignal(flushRequested)
 
+	// While the output backs off after a failed write the periodic flushes are
+	// skipped and the write is retried when the retry timer fires.
+	retry := time.NewTimer(0)
+	if !retry.Stop() {
+		<-retry.C
+	}
+	defer retry.Stop()
+
+	flush := func(writeFunc func() error) {
+		logError(a.flushOnce(output, ticker, writeFunc))
+
+		if !retry.Stop() {
+			select {
+			case <-retry.C:
+			default:
+			}
+		}
+		if delay := output.RetryDelay(); delay > 0 {
+			retry.Reset(delay)
+		}
+	}
+
 	for {
 		// Favor shutdown over other methods.
 		select {
@@ -802,16 +1588,26 @@ func (a *Agent) flushLoop(
 			logError(a.flushOnce(output, ticker, output.Write))
 			return
 		case <-ticker.Elapsed():
-			logError(a.flushOnce(output, ticker, output.Write))
+			if output.RetryDelay() > 0 {
+				continue
+			}
+			flush(output.Write)
+		case <-retry.C:
+			flush(output.Write)
 		case <-flushRequested:
-			logError(a.flushOnce(output, ticker, output.Write))
+			flush(output.Write)
+		case <-flushNow:
+			flush(output.Write)
 		case <-output.BatchReady:
+			if output.RetryDelay() > 0 {
+				continue
+			}
 			// Favor the ticker over batch ready
 			select {
 			case <-ticker.Elapsed():
-				logError(a.flushOnce(output, ticker, output.Write))
+				flush(output.Write)
 			default:
-				logError(a.flushOnce(output, ticker, output.WriteBatch))
+				flush(output.WriteBatch)
 			}
 		}
 	}
@@ -863,11 +1659,12 @@ func (a *Agent) Test(ctx context.Context, wait time.Duration) error {
 		}
 	}()
 
-	err := a.test(ctx, wait, src)
+	err := a.test(ctx, wait, func(string) chan<- telegraf.Metric { return src })
 	if err != nil {
 		return err
 	}
 
+	close(src)
 	wg.Wait()
 
 	if models.GlobalGatherErrors.Get() != 0 {
@@ -876,11 +1673,102 @@ func (a *Agent) Test(ctx context.Context, wait time.Duration) error {
 	return nil
 }
 
-// Test runs the agent and performs a single gather sending output to the
-// outputF.  After gathering pauses for the wait duration to allow service
+// TestPipeline runs the inputs, processors and aggregators once like Test and
+// prints the metrics each output would receive, serialized with the data
+// format of the output.  Outputs without data format support are shown in
+// influx line protocol.  The outputs are not connected.
+func (a *Agent) TestPipeline(ctx context.Context, wait time.Duration) error {
+	return a.testPipeline(ctx, wait, os.Stdout)
+}
+
+func (a *Agent) testPipeline(ctx context.Context, wait time.Duration, w io.Writer) error {
+	outputs := map[string][]*models.RunningOutput{"": a.Config.Outputs}
+	for _, p := range a.Config.Pipelines {
+		outputs[p.Name] = p.Outputs
+	}
+
+	previews := make(map[*models.RunningOutput]*bytes.Buffer)
+	srcs := make(map[string]chan telegraf.Metric, len(outputs))
+	var wg sync.WaitGroup
+	for name, pipelineOutputs := range outputs {
+		src := make(chan telegraf.Metric, 100)
+		srcs[name] = src
+		for _, output := range pipelineOutputs {
+			previews[output] = &bytes.Buffer{}
+		}
+
+		wg.Add(1)
+		go func(src <-chan telegraf.Metric, pipelineOutputs []*models.RunningOutput) {
+			defer wg.Done()
+			s := influx.NewSerializer()
+			s.SetFieldSortOrder(influx.SortFields)
+
+			for metric := range src {
+				for _, output := range pipelineOutputs {
+					m := output.Preview(metric)
+					if m == nil {
+						continue
+					}
+
+					var serializer serializers.Serializer = s
+					if output.Serializer != nil {
+						serializer = output.Serializer
+					}
+					octets, err := serializer.Serialize(m)
+					if err != nil {
+						log.Printf("E! [agent] Serializing metric for %s: %v", output.LogName(), err)
+						continue
+					}
+					previews[output].Write(octets)
+				}
+				metric.Reject()
+			}
+		}(src, pipelineOutputs)
+	}
+
+	err := a.test(ctx, wait, func(name string) chan<- telegraf.Metric { return srcs[name] })
+	if err != nil {
+		return err
+	}
+
+	for _, src := range srcs {
+		close(src)
+	}
+	wg.Wait()
+
+	for _, output := range a.Config.Outputs {
+		fmt.Fprintf(w, "%s:\n", output.LogName())
+		fmt.Fprint(w, previews[output].String())
+	}
+	for _, p := range a.Config.Pipelines {
+		for _, output := range p.Outputs {
+			fmt.Fprintf(w, "%s (pipeline %s):\n", output.LogName(), p.Name)
+			fmt.Fprint(w, previews[output].String())
+		}
+	}
+
+	if models.GlobalGatherErrors.Get() != 0 {
+		return fmt.Errorf("input plugins recorded %d errors", models.GlobalGatherErrors.Get())
+	}
+	return nil
+}
+
+// Test runs the agent and performs a single gather sending the metrics of the
+// top-level and each named pipeline to the channel returned by outputC for the
+// pipeline name, which is empty for the top-level pipeline.  The channels are
+// not closed.  After gathering pauses for the wait duration to allow service
 // inputs to run.
-func (a *Agent) test(ctx context.Context, wait time.Duration, outputC chan<- telegraf.Metric) error {
+func (a *Agent) test(
+	ctx context.Context,
+	wait time.Duration,
+	outputC func(pipeline string) chan<- telegraf.Metric,
+) error {
+	// The log level overrides must not outlive the plugins, e.g. when the
+	// configuration is loaded again after stopping.
+	defer a.releaseLogLevels()
+
 	log.Printf("D! [agent] Initializing plugins")
+	a.useMemoryBuffers()
 	err := a.initPlugins()
 	if err != nil {
 		return err
@@ -888,63 +1776,39 @@ func (a *Agent) test(ctx context.Context, wait time.Duration, outputC chan<- tel
 
 	startTime := time.Now()
 
-	next := outputC
-
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		procC := next
-		if len(a.Config.AggProcessors) != 0 {
-			procC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au = a.startAggregators(procC, next, a.Config.Aggregators)
+	pu, err := a.startPipeline(a.Config.Processors, a.Config.Aggregators, a.Config.AggProcessors)
+	if err != nil {
+		return err
 	}
 
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
+	units := []*pipelineUnit{pu}
+	dsts := []chan<- telegraf.Metric{outputC("")}
+	routes := make(map[string]chan<- telegraf.Metric, len(a.Config.Pipelines))
+	for _, p := range a.Config.Pipelines {
+		npu, err := a.startPipeline(p.Processors, p.Aggregators, p.AggProcessors)
 		if err != nil {
-			return err
+			for _, u := range units {
+				stopPipeline(u)
+			}
+			return fmt.Errorf("pipeline %q: %w", p.Name, err)
 		}
+		units = append(units, npu)
+		dsts = append(dsts, outputC(p.Name))
+		routes[p.Name] = npu.src
 	}
 
-	iu := a.testStartInputs(next, a.Config.Inputs)
+	iu := a.testStartInputs(pu.src, routes, a.Config.Inputs)
 
-	var wg sync.WaitGroup
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(apu)
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runAggregators(startTime, au)
-		}()
+	done := make([]<-chan struct{}, 0, len(units))
+	for i, u := range units {
+		done = append(done, a.runPipelineUnit(startTime, u, dsts[i]))
 	}
 
-	if pu != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(pu)
-		}()
+	a.testRunInputs(ctx, wait, iu)
+	for _, d := range done {
+		<-d
 	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		a.testRunInputs(ctx, wait, iu)
-	}()
-
-	wg.Wait()
-
 	log.Printf("D! [agent] Stopped Successfully")
 
 	return nil
@@ -962,7 +1826,7 @@ func (a *Agent) Once(ctx context.Context, wait time.Duration) error {
 	}
 
 	unsent := 0
-	for _, output := range a.Config.Outputs {
+	for _, output := range a.Config.AllOutputs() {
 		unsent += output.BufferLength()
 	}
 	if unsent != 0 {
@@ -975,7 +1839,12 @@ func (a *Agent) Once(ctx context.Context, wait time.Duration) error {
 // outputF.  After gathering pauses for the wait duration to allow service
 // inputs to run.
 func (a *Agent) once(ctx context.Context, wait time.Duration) error {
+	// The log level overrides must not outlive the plugins, e.g. when the
+	// configuration is loaded again after stopping.
+	defer a.releaseLogLevels()
+
 	log.Printf("D! [agent] Initializing plugins")
+	a.useMemoryBuffers()
 	err := a.initPlugins()
 	if err != nil {
 		return err
@@ -989,29 +1858,18 @@ func (a *Agent) once(ctx context.Context, wait time.Duration) error {
 		return err
 	}
 
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		procC := next
-		if len(a.Config.AggProcessors) != 0 {
-			procC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au = a.startAggregators(procC, next, a.Config.Aggregators)
+	pu, err := a.startPipeline(a.Config.Processors, a.Config.Aggregators, a.Config.AggProcessors)
+	if err != nil {
+		return err
 	}
 
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
-		if err != nil {
-			return err
-		}
+	named, err := a.startNamedPipelines(ctx, a.Config.Pipelines)
+	if err != nil {
+		return err
 	}
 
-	iu := a.testStartInputs(next, a.Config.Inputs)
+	inputC := make(chan telegraf.Metric, 100)
+	iu := a.testStartInputs(inputC, namedRoutes(named), a.Config.Inputs)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -1020,26 +1878,24 @@ func (a *Agent) once(ctx context.Context, wait time.Duration) error {
 		a.runOutputs(ou)
 	}()
 
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(apu)
-		}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.runPipeline(startTime, inputC, next, pu, nil)
+	}()
 
+	for _, np := range named {
 		wg.Add(1)
-		go func() {
+		go func(np *namedPipelineUnit) {
 			defer wg.Done()
-			a.runAggregators(startTime, au)
-		}()
-	}
+			a.runOutputs(np.outputs)
+		}(np)
 
-	if pu != nil {
 		wg.Add(1)
-		go func() {
+		go func(np *namedPipelineUnit) {
 			defer wg.Done()
-			a.runProcessors(pu)
-		}()
+			a.runPipeline(startTime, np.src, np.dst, np.pipeline, nil)
+		}(np)
 	}
 
 	wg.Add(1)
@@ -1,12 +1,22 @@
 package agent
 
 import (
+	"bytes"
+	"context"
+	"os"
+	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/models"
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/plugins/serializers/json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -166,3 +176,309 @@ func TestWindow(t *testing.T) {
 		})
 	}
 }
+
+func TestAgent_TestPipeline(t *testing.T) {
+	c := newReloadConfig()
+	c.Agent.OmitHostname = true
+	addMockInput(c, "cpu")
+	addMockInput(c, "mem")
+	addMockProcessor(c, "yes")
+
+	addMockOutput(c, "all")
+	filtered := &mockOutput{}
+	conf := &models.OutputConfig{
+		Name:   "mock",
+		Alias:  "json",
+		ID:     "filtered",
+		Filter: models.Filter{NamePass: []string{"mem"}},
+	}
+	require.NoError(t, conf.Filter.Compile())
+	ro := models.NewRunningOutput(filtered, conf, 0, 0)
+	ro.Serializer, _ = json.NewSerializer(time.Second, "")
+	c.Outputs = append(c.Outputs, ro)
+
+	addMockInput(c, "secure")
+	c.Inputs[2].Config.Pipeline = "security"
+	c.Pipelines = append(c.Pipelines, &config.Pipeline{
+		Name: "security",
+		Outputs: []*models.RunningOutput{
+			models.NewRunningOutput(&mockOutput{}, &models.OutputConfig{Name: "mock", ID: "security"}, 0, 0),
+		},
+	})
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, a.testPipeline(context.Background(), 0, &buf))
+
+	out := buf.String()
+	require.Contains(t, out, "outputs.mock:\n")
+	require.Contains(t, out, "cpu,processed=yes value=42i ")
+	require.Contains(t, out, "mem,processed=yes value=42i ")
+	require.Contains(t, out, "outputs.mock::json:\n")
+	require.Contains(t, out, `"name":"mem","tags":{"processed":"yes"}`)
+	require.NotContains(t, out, `"name":"cpu"`)
+	require.Contains(t, out, "outputs.mock (pipeline security):\nsecure value=42i ")
+	require.NotContains(t, out, "secure,processed=yes")
+
+	// Outputs are neither connected nor written to
+	require.Empty(t, filtered.metrics)
+}
+
+func TestAgent_TestModeDiskBuffer(t *testing.T) {
+	dir := t.TempDir()
+	c := newReloadConfig()
+	addMockInput(c, "cpu")
+	output := &mockOutput{}
+	c.Outputs = append(c.Outputs, models.NewRunningOutput(output, &models.OutputConfig{
+		Name:            "mock",
+		ID:              "out",
+		BufferStrategy:  "disk",
+		BufferDirectory: dir,
+	}, 0, 0))
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, a.testPipeline(context.Background(), 0, &buf))
+	require.NoError(t, a.once(context.Background(), 0))
+	require.True(t, output.received("cpu"))
+
+	// The disk buffer of a running agent is neither created nor consumed
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestAgent_NamedPipelines(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	secure := addMockInput(c, "secure")
+	c.Inputs[1].Config.Pipeline = "security"
+	addMockProcessor(c, "default")
+	output := addMockOutput(c, "out")
+
+	securityOutput := &mockOutput{}
+	processor := processors.NewStreamingProcessorFromProcessor(&mockProcessor{tag: "security"})
+	c.Pipelines = append(c.Pipelines, &config.Pipeline{
+		Name: "security",
+		Processors: models.RunningProcessors{
+			models.NewRunningProcessor(processor, &models.ProcessorConfig{Name: "mock", ID: "security"}),
+		},
+		Outputs: []*models.RunningOutput{
+			models.NewRunningOutput(securityOutput, &models.OutputConfig{Name: "mock", ID: "security"}, 0, 0),
+		},
+	})
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return output.received("foo") && securityOutput.received(secure.name)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	// Metrics only pass through the pipeline selected by the input
+	require.False(t, output.received("secure"))
+	require.False(t, output.receivedTag("processed", "security"))
+	require.False(t, securityOutput.received("foo"))
+	require.False(t, securityOutput.receivedTag("processed", "default"))
+	require.True(t, securityOutput.receivedTag("processed", "security"))
+	require.True(t, securityOutput.isClosed())
+}
+
+func TestAgent_UndefinedPipeline(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	c.Inputs[0].Config.Pipeline = "missing"
+	addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+	require.EqualError(t, a.Run(context.Background()), `input inputs.mock: undefined pipeline "missing"`)
+}
+
+// pathProcessor appends its name to the "path" tag of the metrics.
+type pathProcessor struct {
+	name string
+}
+
+func (p *pathProcessor) SampleConfig() string { return "" }
+func (p *pathProcessor) Description() string  { return "" }
+
+func (p *pathProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		path, _ := m.GetTag("path")
+		m.AddTag("path", path+"/"+p.name)
+	}
+	return in
+}
+
+func newPathProcessor(t *testing.T, name string, order int64, branch string, namepass ...string) *models.RunningProcessor {
+	conf := &models.ProcessorConfig{
+		Name:   "path",
+		Alias:  name,
+		Order:  order,
+		Branch: branch,
+		Filter: models.Filter{NamePass: namepass},
+	}
+	require.NoError(t, conf.Filter.Compile())
+	processor := processors.NewStreamingProcessorFromProcessor(&pathProcessor{name: name})
+	return models.NewRunningProcessor(processor, conf)
+}
+
+func TestAgent_ProcessorBranches(t *testing.T) {
+	a, err := NewAgent(config.NewConfig())
+	require.NoError(t, err)
+
+	dst := make(chan telegraf.Metric, 10)
+	src, units, err := a.startProcessors(dst, models.RunningProcessors{
+		newPathProcessor(t, "last", 5, ""),
+		newPathProcessor(t, "double", 3, "y", "cpu"),
+		newPathProcessor(t, "first", 1, ""),
+		newPathProcessor(t, "cpu", 2, "x", "cpu"),
+		newPathProcessor(t, "disk", 4, "x", "disk"),
+	})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.runProcessors(units)
+	}()
+
+	now := time.Now()
+	for _, name := range []string{"cpu", "disk", "mem"} {
+		src <- metric.New(name, map[string]string{}, map[string]interface{}{"value": 42}, now)
+	}
+	close(src)
+	<-done
+
+	paths := make(map[string][]string)
+	for m := range dst {
+		path, _ := m.GetTag("path")
+		paths[m.Name()] = append(paths[m.Name()], path)
+	}
+	sort.Strings(paths["cpu"])
+
+	// Metrics selected by several branches are copied to each of them
+	require.Equal(t, []string{"/first/cpu/last", "/first/double/last"}, paths["cpu"])
+	require.Equal(t, []string{"/first/disk/last"}, paths["disk"])
+	require.Equal(t, []string{"/first/last"}, paths["mem"])
+}
+
+type blockingInput struct {
+	release chan struct{}
+}
+
+func (i *blockingInput) SampleConfig() string { return "" }
+func (i *blockingInput) Description() string  { return "" }
+
+func (i *blockingInput) Gather(acc telegraf.Accumulator) error {
+	<-i.release
+	acc.AddFields("late", map[string]interface{}{"value": 42}, nil)
+	return nil
+}
+
+func TestAgent_GatherTimeout(t *testing.T) {
+	a, err := NewAgent(config.NewConfig())
+	require.NoError(t, err)
+
+	input := &blockingInput{release: make(chan struct{})}
+	ri := models.NewRunningInput(input, &models.InputConfig{
+		Name:          "gather_timeout",
+		GatherTimeout: 10 * time.Millisecond,
+	})
+
+	metrics := make(chan telegraf.Metric, 10)
+	acc := NewAccumulator(ri, metrics)
+	ticker := NewUnalignedTicker(time.Hour, 0)
+	defer ticker.Stop()
+
+	abandoned, err := a.gatherOnce(acc, ri, ticker, time.Hour)
+	require.EqualError(t, err, "collection abandoned after timeout of 10ms")
+	require.NotNil(t, abandoned)
+	require.Equal(t, int64(1), ri.GatherTimeouts.Get())
+
+	// Metrics of the abandoned collection are dropped
+	close(input.release)
+	require.NoError(t, <-abandoned)
+	require.Empty(t, metrics)
+
+	// Collections completing in time are not abandoned
+	abandoned, err = a.gatherOnce(acc, ri, ticker, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, abandoned)
+	require.Len(t, metrics, 1)
+}
+
+type hangingInput struct {
+	calls    int64
+	gathered chan struct{}
+	hang     chan struct{}
+}
+
+func (i *hangingInput) SampleConfig() string { return "" }
+func (i *hangingInput) Description() string  { return "" }
+
+// Gather hangs on the first call until hang is closed.
+func (i *hangingInput) Gather(telegraf.Accumulator) error {
+	first := atomic.AddInt64(&i.calls, 1) == 1
+	i.gathered <- struct{}{}
+	if first {
+		<-i.hang
+	}
+	return nil
+}
+
+func TestAgent_GatherLoopHungCollection(t *testing.T) {
+	a, err := NewAgent(config.NewConfig())
+	require.NoError(t, err)
+
+	input := &hangingInput{gathered: make(chan struct{}, 10), hang: make(chan struct{})}
+	defer close(input.hang)
+	ri := models.NewRunningInput(input, &models.InputConfig{
+		Name:          "hung",
+		GatherTimeout: 10 * time.Millisecond,
+	})
+	var skipped int64
+	ri.Log().(*models.Logger).OnErr(func() { atomic.AddInt64(&skipped, 1) })
+
+	acc := NewAccumulator(ri, make(chan telegraf.Metric, 10))
+	ticker := NewUnalignedTicker(time.Hour, 0)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gatherNow := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.gatherLoop(ctx, acc, ri, ticker, time.Hour, gatherNow)
+	}()
+
+	// The first collection is abandoned and the next ones are skipped, until
+	// a new collection is started after too many skips.
+	gatherNow <- struct{}{}
+	<-input.gathered
+	for i := 0; i < maxSkippedCollections; i++ {
+		gatherNow <- struct{}{}
+	}
+	require.Empty(t, input.gathered)
+	gatherNow <- struct{}{}
+	<-input.gathered
+
+	cancel()
+	<-done
+	// One error for the abandoned collection and one for each skipped one
+	require.Equal(t, int64(maxSkippedCollections+1), atomic.LoadInt64(&skipped))
+}
@@ -0,0 +1,313 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+var errNotRunning = errors.New("agent is not running")
+
+// apiServer is a local HTTP server of the agent, like the control API.
+type apiServer struct {
+	name   string
+	server *http.Server
+	done   chan struct{}
+}
+
+// apiPlugin describes a loaded plugin in the control API.
+type apiPlugin struct {
+	ID    string                      `json:"id"`
+	Name  string                      `json:"name"`
+	Alias string                      `json:"alias,omitempty"`
+	Stats map[string]map[string]int64 `json:"stats"`
+
+	// Pipeline is the named pipeline of the plugin, or the pipeline an input
+	// feeds.
+	Pipeline string `json:"pipeline,omitempty"`
+
+	// BufferLength is the number of metrics buffered by an output.
+	BufferLength *int `json:"buffer_length,omitempty"`
+}
+
+// apiPlugins lists the loaded plugins by type.
+type apiPlugins struct {
+	Inputs      []apiPlugin `json:"inputs"`
+	Processors  []apiPlugin `json:"processors"`
+	Aggregators []apiPlugin `json:"aggregators"`
+	Outputs     []apiPlugin `json:"outputs"`
+}
+
+// startAPI starts the control API listening on the given address.
+func (a *Agent) startAPI(address string) (*apiServer, error) {
+	return startServer("control API", address, a.apiHandler())
+}
+
+// startServer starts serving the handler on the given address.
+func startServer(name, address string, handler http.Handler) (*apiServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("starting %s: %w", name, err)
+	}
+
+	api := &apiServer{
+		name: name,
+		server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(api.done)
+		err := api.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("E! [agent] The %s stopped: %v", name, err)
+		}
+	}()
+
+	log.Printf("I! [agent] The %s is listening on %s", name, listener.Addr())
+	return api, nil
+}
+
+// stop shuts down the server waiting for pending requests to complete.
+func (api *apiServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := api.server.Shutdown(ctx); err != nil {
+		log.Printf("E! [agent] Stopping %s: %v", api.name, err)
+	}
+	<-api.done
+}
+
+// apiHandler returns the handler serving the endpoints of the control API.
+func (a *Agent) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/plugins", apiMethod(http.MethodGet, a.servePlugins))
+	mux.HandleFunc("/api/v1/gather", apiMethod(http.MethodPost, a.serveGather))
+	mux.HandleFunc("/api/v1/flush", apiMethod(http.MethodPost, a.serveFlush))
+	mux.HandleFunc("/api/v1/reload", apiMethod(http.MethodPost, a.serveReload))
+	return mux
+}
+
+// apiMethod only passes requests with the given method to the handler.
+// Requests triggering an action must not be cross-origin, so web pages opened
+// in a local browser cannot operate the agent.
+func apiMethod(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if method != http.MethodGet && !sameOrigin(req) {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		handler(w, req)
+	}
+}
+
+// sameOrigin reports whether the request was not sent cross-origin by a
+// browser.  Browsers set the Origin header for POST requests, other clients
+// like curl usually don't set it at all.
+func sameOrigin(req *http.Request) bool {
+	switch req.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == req.Host
+}
+
+func (a *Agent) servePlugins(w http.ResponseWriter, _ *http.Request) {
+	plugins := a.plugins()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plugins); err != nil {
+		log.Printf("E! [agent] Writing control API response: %v", err)
+	}
+}
+
+func (a *Agent) serveGather(w http.ResponseWriter, req *http.Request) {
+	n, err := a.gatherNow(req.URL.Query().Get("id"))
+	serveTriggered(w, n, err)
+}
+
+func (a *Agent) serveFlush(w http.ResponseWriter, req *http.Request) {
+	n, err := a.flushNow(req.URL.Query().Get("id"))
+	serveTriggered(w, n, err)
+}
+
+func serveTriggered(w http.ResponseWriter, n int, err error) {
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case n == 0:
+		http.Error(w, "no matching plugin", http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (a *Agent) serveReload(w http.ResponseWriter, _ *http.Request) {
+	if a.RequestReload == nil {
+		http.Error(w, "reloading is not supported", http.StatusNotImplemented)
+		return
+	}
+	a.RequestReload()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// plugins returns the currently loaded plugins with their statistics.
+func (a *Agent) plugins() *apiPlugins {
+	a.runningMu.Lock()
+	defer a.runningMu.Unlock()
+
+	plugins := &apiPlugins{
+		Inputs:      make([]apiPlugin, 0, len(a.Config.Inputs)),
+		Processors:  make([]apiPlugin, 0, len(a.Config.Processors)),
+		Aggregators: make([]apiPlugin, 0, len(a.Config.Aggregators)),
+		Outputs:     make([]apiPlugin, 0, len(a.Config.Outputs)),
+	}
+	for _, input := range a.Config.Inputs {
+		c := input.Config
+		plugin := newAPIPlugin("input", c.ID, c.Name, c.Alias)
+		plugin.Pipeline = c.Pipeline
+		plugins.Inputs = append(plugins.Inputs, plugin)
+	}
+	plugins.add("", a.Config.Processors, a.Config.Aggregators, a.Config.Outputs)
+	for _, p := range a.Config.Pipelines {
+		plugins.add(p.Name, p.Processors, p.Aggregators, p.Outputs)
+	}
+	return plugins
+}
+
+// add adds the processors, aggregators and outputs of a pipeline.
+func (plugins *apiPlugins) add(
+	pipeline string,
+	processors []*models.RunningProcessor,
+	aggregators []*models.RunningAggregator,
+	outputs []*models.RunningOutput,
+) {
+	for _, processor := range processors {
+		c := processor.Config
+		plugin := newAPIPlugin("processor", c.ID, c.Name, c.Alias)
+		plugin.Pipeline = pipeline
+		plugins.Processors = append(plugins.Processors, plugin)
+	}
+	for _, aggregator := range aggregators {
+		c := aggregator.Config
+		plugin := newAPIPlugin("aggregator", c.ID, c.Name, c.Alias)
+		plugin.Pipeline = pipeline
+		plugins.Aggregators = append(plugins.Aggregators, plugin)
+	}
+	for _, output := range outputs {
+		c := output.Config
+		plugin := newAPIPlugin("output", c.ID, c.Name, c.Alias)
+		plugin.Pipeline = pipeline
+		length := output.BufferLength()
+		plugin.BufferLength = &length
+		plugins.Outputs = append(plugins.Outputs, plugin)
+	}
+}
+
+func newAPIPlugin(kind, id, name, alias string) apiPlugin {
+	tags := map[string]string{kind: name}
+	if alias != "" {
+		tags["alias"] = alias
+	}
+	return apiPlugin{
+		ID:    id,
+		Name:  name,
+		Alias: alias,
+		Stats: selfstat.Values(tags),
+	}
+}
+
+// gatherNow requests an immediate gather of the input with the given ID, or
+// of all inputs if the ID is empty, and returns the number of inputs
+// triggered.
+func (a *Agent) gatherNow(id string) (int, error) {
+	a.runningMu.Lock()
+	defer a.runningMu.Unlock()
+	if a.running == nil {
+		return 0, errNotRunning
+	}
+
+	unit := a.running.inputs
+	unit.Lock()
+	defer unit.Unlock()
+
+	var n int
+	for _, input := range unit.inputs {
+		gatherNow, ok := unit.gather[input]
+		if !ok || (id != "" && input.Config.ID != id) {
+			continue
+		}
+		select {
+		case gatherNow <- struct{}{}:
+		default:
+		}
+		n++
+	}
+	return n, nil
+}
+
+// flushNow requests an immediate flush of the output with the given ID, or of
+// all outputs if the ID is empty, and returns the number of outputs
+// triggered.
+func (a *Agent) flushNow(id string) (int, error) {
+	a.runningMu.Lock()
+	defer a.runningMu.Unlock()
+	if a.running == nil {
+		return 0, errNotRunning
+	}
+
+	n := flushUnit(a.running.outputs, id)
+	for _, np := range a.running.named {
+		n += flushUnit(np.outputs, id)
+	}
+	return n, nil
+}
+
+// flushUnit requests an immediate flush of the outputs of the unit with the
+// given ID, or of all its outputs if the ID is empty.
+func flushUnit(unit *outputUnit, id string) int {
+	unit.RLock()
+	defer unit.RUnlock()
+
+	var n int
+	for _, output := range unit.outputs {
+		flushNow, ok := unit.flush[output]
+		if !ok || (id != "" && output.Config.ID != id) {
+			continue
+		}
+		select {
+		case flushNow <- struct{}{}:
+		default:
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIPlugins(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	addMockProcessor(c, "tag")
+	addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(a.apiHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/plugins")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var plugins apiPlugins
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&plugins))
+
+	require.Len(t, plugins.Inputs, 1)
+	require.Equal(t, "foo", plugins.Inputs[0].ID)
+	require.Equal(t, "mock", plugins.Inputs[0].Name)
+	require.Contains(t, plugins.Inputs[0].Stats["internal_gather"], "metrics_gathered")
+	require.Nil(t, plugins.Inputs[0].BufferLength)
+
+	require.Len(t, plugins.Processors, 1)
+	require.Equal(t, "tag", plugins.Processors[0].ID)
+	require.Empty(t, plugins.Aggregators)
+
+	require.Len(t, plugins.Outputs, 1)
+	require.Equal(t, "out", plugins.Outputs[0].ID)
+	require.Contains(t, plugins.Outputs[0].Stats["internal_write"], "metrics_written")
+	require.NotNil(t, plugins.Outputs[0].BufferLength)
+	require.Equal(t, 0, *plugins.Outputs[0].BufferLength)
+
+	resp, err = http.Post(server.URL+"/api/v1/plugins", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestAPIGatherAndFlush(t *testing.T) {
+	c := newReloadConfig()
+	c.Agent.Interval = config.Duration(time.Hour)
+	c.Agent.FlushInterval = config.Duration(time.Hour)
+	addMockInput(c, "foo")
+	addMockInput(c, "bar")
+	output := addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(a.apiHandler())
+	defer server.Close()
+
+	post := func(path string) int {
+		resp, err := http.Post(server.URL+path, "", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Nothing can be triggered before the agent runs
+	require.Equal(t, http.StatusServiceUnavailable, post("/api/v1/gather"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	bufferLength := func() int {
+		a.runningMu.Lock()
+		defer a.runningMu.Unlock()
+		return a.Config.Outputs[0].BufferLength()
+	}
+
+	// Wait for the initial gather of both inputs
+	require.Eventually(t, func() bool {
+		return bufferLength() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, http.StatusAccepted, post("/api/v1/gather?id=foo"))
+	require.Equal(t, http.StatusNotFound, post("/api/v1/gather?id=unknown"))
+	require.Eventually(t, func() bool {
+		return bufferLength() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.False(t, output.received("foo"))
+
+	require.Equal(t, http.StatusAccepted, post("/api/v1/flush?id=out"))
+	require.Eventually(t, func() bool {
+		return bufferLength() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, output.received("foo"))
+	require.True(t, output.received("bar"))
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestAPIReload(t *testing.T) {
+	a, err := NewAgent(newReloadConfig())
+	require.NoError(t, err)
+
+	server := httptest.NewServer(a.apiHandler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/api/v1/reload", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+
+	requested := make(chan struct{}, 1)
+	a.RequestReload = func() {
+		requested <- struct{}{}
+	}
+
+	resp, err = http.Post(server.URL+"/api/v1/reload", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Len(t, requested, 1)
+}
+
+func TestAPIRejectCrossOrigin(t *testing.T) {
+	a, err := NewAgent(newReloadConfig())
+	require.NoError(t, err)
+	a.RequestReload = func() {}
+
+	server := httptest.NewServer(a.apiHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected int
+	}{
+		{
+			name:     "no origin",
+			expected: http.StatusAccepted,
+		},
+		{
+			name:     "same origin",
+			headers:  map[string]string{"Origin": server.URL},
+			expected: http.StatusAccepted,
+		},
+		{
+			name:     "cross origin",
+			headers:  map[string]string{"Origin": "http://example.com"},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "cross site fetch",
+			headers:  map[string]string{"Sec-Fetch-Site": "cross-site"},
+			expected: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/reload", nil)
+			require.NoError(t, err)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, tt.expected, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var invalidPrometheusChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// startMetrics starts serving the internal statistics of the agent in the
+// Prometheus exposition format on the given address.
+func (a *Agent) startMetrics(address string) (*apiServer, error) {
+	return startServer("metrics endpoint", address, metricsHandler())
+}
+
+// metricsHandler returns the handler serving the internal statistics and the
+// Go runtime and process statistics on /metrics.
+func metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(selfstatCollector{})
+	registry.MustRegister(prometheus.NewGoCollector())
+	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// selfstatCollector collects the statistics registered with selfstat.  The
+// field "gather_time_ns" of the measurement "internal_gather" is exposed as
+// "telegraf_gather_gather_time_ns".  The statistics are untyped, as selfstat
+// does not distinguish counters and gauges.
+type selfstatCollector struct{}
+
+// Describe sends no descriptions, the statistics are only known when
+// collecting them.
+func (selfstatCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (selfstatCollector) Collect(ch chan<- prometheus.Metric) {
+	type sample struct {
+		labels map[string]string
+		value  float64
+	}
+
+	// All samples of a metric family must have the same label names, stats
+	// registered with and without an alias are therefore merged.
+	samples := make(map[string][]sample)
+	labelNames := make(map[string]map[string]bool)
+	for _, m := range selfstat.Snapshot() {
+		measurement := strings.TrimPrefix(m.Name(), "internal_")
+		labels := make(map[string]string, len(m.TagList()))
+		for _, tag := range m.TagList() {
+			labels[sanitizePrometheusName(tag.Key)] = tag.Value
+		}
+		for _, field := range m.FieldList() {
+			value, ok := field.Value.(int64)
+			if !ok {
+				continue
+			}
+
+			name := sanitizePrometheusName("telegraf_" + measurement + "_" + field.Key)
+			samples[name] = append(samples[name], sample{labels: labels, value: float64(value)})
+			if labelNames[name] == nil {
+				labelNames[name] = make(map[string]bool)
+			}
+			for label := range labels {
+				labelNames[name][label] = true
+			}
+		}
+	}
+
+	for name, family := range samples {
+		keys := make([]string, 0, len(labelNames[name]))
+		for label := range labelNames[name] {
+			keys = append(keys, label)
+		}
+		sort.Strings(keys)
+
+		help := fmt.Sprintf("Telegraf internal statistic %s.", name)
+		desc := prometheus.NewDesc(name, help, keys, nil)
+		for _, s := range family {
+			values := make([]string, 0, len(keys))
+			for _, key := range keys {
+				values = append(values, s.labels[key])
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.UntypedValue, s.value, values...)
+		}
+	}
+}
+
+func sanitizePrometheusName(name string) string {
+	return invalidPrometheusChars.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	selfstat.Register("write", "buffer_size", map[string]string{"output": "metrics_test"}).Set(3)
+	selfstat.Register("write", "buffer_size", map[string]string{"output": "metrics_test", "alias": "a-1"}).Set(5)
+
+	server := httptest.NewServer(metricsHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "# TYPE telegraf_write_buffer_size untyped\n")
+	require.Contains(t, string(body), `telegraf_write_buffer_size{alias="",output="metrics_test"} 3`+"\n")
+	require.Contains(t, string(body), `telegraf_write_buffer_size{alias="a-1",output="metrics_test"} 5`+"\n")
+	require.Contains(t, string(body), "go_goroutines ")
+}
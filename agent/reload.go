@@ -0,0 +1,339 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+)
+
+// ErrRestartRequired is returned by Reload if the configuration change cannot
+// be applied to the running agent.
+var ErrRestartRequired = errors.New("configuration change requires a restart of the agent")
+
+// Reload applies the configuration to the running agent.  Only plugins whose
+// configuration changed are stopped and started; unaffected inputs and outputs
+// keep running and the outputs retain their buffered metrics.  If any
+// processor or aggregator changed, the processor and aggregator chain is
+// replaced, while unchanged aggregators keep their current aggregation
+// window.
+//
+// Changes to the agent settings, global tags, named pipelines or outputs
+// using the same disk buffer cannot be applied and result in
+// ErrRestartRequired.  On error the running configuration is not modified.
+func (a *Agent) Reload(c *config.Config) error {
+	a.runningMu.Lock()
+	defer a.runningMu.Unlock()
+
+	r := a.running
+	if r == nil {
+		return errNotRunning
+	}
+
+	if !reflect.DeepEqual(a.Config.Agent, c.Agent) {
+		return fmt.Errorf("agent settings changed: %w", ErrRestartRequired)
+	}
+	if !reflect.DeepEqual(a.Config.Tags, c.Tags) {
+		return fmt.Errorf("global tags changed: %w", ErrRestartRequired)
+	}
+	if !reflect.DeepEqual(pipelineIDs(a.Config.Pipelines), pipelineIDs(c.Pipelines)) {
+		return fmt.Errorf("named pipelines changed: %w", ErrRestartRequired)
+	}
+	if err := c.CheckPipelines(); err != nil {
+		return err
+	}
+	if err := c.ResolveSecrets(); err != nil {
+		return err
+	}
+
+	// Match the plugins of the new configuration with the running ones.
+	inputs := make([]*models.RunningInput, len(c.Inputs))
+	var addedInputs, removedInputs []*models.RunningInput
+	keep, stop := diffIDs(inputIDs(a.Config.Inputs), inputIDs(c.Inputs))
+	for i, k := range keep {
+		if k < 0 {
+			inputs[i] = c.Inputs[i]
+			addedInputs = append(addedInputs, c.Inputs[i])
+		} else {
+			inputs[i] = a.Config.Inputs[k]
+		}
+	}
+	for _, k := range stop {
+		removedInputs = append(removedInputs, a.Config.Inputs[k])
+	}
+
+	outputs := make([]*models.RunningOutput, len(c.Outputs))
+	var addedOutputs, removedOutputs []*models.RunningOutput
+	keep, stop = diffIDs(outputIDs(a.Config.Outputs), outputIDs(c.Outputs))
+	for i, k := range keep {
+		if k < 0 {
+			outputs[i] = c.Outputs[i]
+			addedOutputs = append(addedOutputs, c.Outputs[i])
+		} else {
+			outputs[i] = a.Config.Outputs[k]
+		}
+	}
+	for _, k := range stop {
+		removedOutputs = append(removedOutputs, a.Config.Outputs[k])
+	}
+	// The buffer of a changed output is only closed after the new instance
+	// started, so both would write to the same disk buffer.
+	removedBuffers := make(map[string]bool, len(removedOutputs))
+	for _, output := range removedOutputs {
+		if path := output.BufferPath(); path != "" {
+			removedBuffers[path] = true
+		}
+	}
+	for _, output := range addedOutputs {
+		if removedBuffers[output.BufferPath()] {
+			return fmt.Errorf("disk buffered output %s changed: %w", output.LogName(), ErrRestartRequired)
+		}
+	}
+	deadLetters, err := models.ResolveDeadLetters(outputs)
+	if err != nil {
+		return err
+	}
+
+	// Processors are restarted whenever the chain changes, as they are wired
+	// to the channels of the chain.  Unchanged aggregators are carried over
+	// to keep their aggregation window.
+	aggregators := make([]*models.RunningAggregator, len(c.Aggregators))
+	var addedAggregators, removedAggregators []*models.RunningAggregator
+	keptAggregators := make(map[*models.RunningAggregator]bool)
+	keep, stop = diffIDs(aggregatorIDs(a.Config.Aggregators), aggregatorIDs(c.Aggregators))
+	for i, k := range keep {
+		if k < 0 {
+			aggregators[i] = c.Aggregators[i]
+			addedAggregators = append(addedAggregators, c.Aggregators[i])
+		} else {
+			aggregators[i] = a.Config.Aggregators[k]
+			keptAggregators[aggregators[i]] = true
+		}
+	}
+	for _, k := range stop {
+		removedAggregators = append(removedAggregators, a.Config.Aggregators[k])
+	}
+	pipelineChanged := len(addedAggregators) > 0 || len(removedAggregators) > 0
+
+	// Processors are order dependent, so reordering them changes the chain
+	// as well.  This applies to the processors before and after the
+	// aggregators alike.
+	if !reflect.DeepEqual(processorChain(a.Config.Processors), processorChain(c.Processors)) ||
+		!reflect.DeepEqual(processorChain(a.Config.AggProcessors), processorChain(c.AggProcessors)) {
+		pipelineChanged = true
+	}
+
+	// Initialize and start the new plugins, stopping them again on error.
+	for _, input := range addedInputs {
+		if err := input.Init(); err != nil {
+			return fmt.Errorf("could not initialize input %s: %v", input.LogName(), err)
+		}
+	}
+	if pipelineChanged {
+		for _, processor := range c.Processors {
+			if err := processor.Init(); err != nil {
+				return fmt.Errorf("could not initialize processor %s: %v", processor.Config.Name, err)
+			}
+		}
+		for _, aggregator := range addedAggregators {
+			if err := aggregator.Init(); err != nil {
+				return fmt.Errorf("could not initialize aggregator %s: %v", aggregator.Config.Name, err)
+			}
+		}
+		for _, processor := range c.AggProcessors {
+			if err := processor.Init(); err != nil {
+				return fmt.Errorf("could not initialize processor %s: %v", processor.Config.Name, err)
+			}
+		}
+	}
+	for _, output := range addedOutputs {
+		if err := output.Init(); err != nil {
+			return fmt.Errorf("could not initialize output %s: %v", output.Config.Name, err)
+		}
+	}
+
+	for i, output := range addedOutputs {
+		if err := a.connectOutput(r.ctx, output); err != nil {
+			stopRunningOutputs(addedOutputs[:i])
+			return fmt.Errorf("connecting output %s: %w", output.LogName(), err)
+		}
+	}
+
+	var pu *pipelineUnit
+	if pipelineChanged {
+		pu, err = a.startPipeline(c.Processors, aggregators, c.AggProcessors)
+		if err != nil {
+			stopRunningOutputs(addedOutputs)
+			return err
+		}
+		if pu.au != nil {
+			pu.au.resumed = keptAggregators
+		}
+	}
+
+	for i, input := range addedInputs {
+		if err := startServiceInput(r.inputs.dstFor(input), input); err != nil {
+			stopServiceInputs(addedInputs[:i])
+			if pu != nil {
+				stopPipeline(pu)
+			}
+			stopRunningOutputs(addedOutputs)
+			return err
+		}
+	}
+
+	// Everything is set up, apply the changes to the running agent.
+	for i, output := range outputs {
+		output.SetDeadLetter(deadLetters[i])
+	}
+	for _, output := range addedOutputs {
+		a.addOutput(r.outputs, output)
+	}
+
+	if pu != nil {
+		if r.pipeline.au != nil {
+			r.pipeline.au.kept = keptAggregators
+		}
+		req := &pipelineSwap{unit: pu, done: make(chan struct{})}
+		r.swapUnits <- req
+		<-req.done
+		r.pipeline = pu
+	}
+
+	for _, input := range removedInputs {
+		a.removeInput(r.inputs, input)
+	}
+	for _, input := range addedInputs {
+		a.addInput(r.ctx, r.inputs, input)
+	}
+
+	for _, output := range removedOutputs {
+		a.removeOutput(r.outputs, output)
+	}
+
+	// The log level overrides of the stopped plugins do not apply anymore.
+	for _, input := range removedInputs {
+		releaseLogLevel(input.Log())
+	}
+	for _, output := range removedOutputs {
+		releaseLogLevel(output.Log())
+	}
+	if pipelineChanged {
+		releasePipelineLogLevels(a.Config.Processors, removedAggregators, a.Config.AggProcessors, nil)
+	}
+
+	a.Config.Inputs = inputs
+	a.Config.Outputs = outputs
+	if pipelineChanged {
+		a.Config.Processors = c.Processors
+		a.Config.AggProcessors = c.AggProcessors
+		a.Config.Aggregators = aggregators
+	}
+
+	log.Printf("I! [agent] Reloaded config: %d inputs started, %d inputs stopped, "+
+		"%d outputs started, %d outputs stopped, processors and aggregators restarted: %t",
+		len(addedInputs), len(removedInputs), len(addedOutputs), len(removedOutputs), pipelineChanged)
+	return nil
+}
+
+// stopPipeline stops the processors of a pipeline unit that was never run.
+func stopPipeline(unit *pipelineUnit) {
+	for _, u := range append(unit.pu, unit.apu...) {
+		if u.processor != nil {
+			u.processor.Stop()
+		}
+	}
+}
+
+// diffIDs matches the plugin identifiers of the running configuration with the
+// identifiers of a new configuration.  For each plugin of the new
+// configuration keep contains the index of the running plugin to reuse, or -1
+// if the plugin needs to be started.  The indices of running plugins without a
+// match are returned in stop.
+func diffIDs(current, next []string) (keep []int, stop []int) {
+	available := make(map[string][]int, len(current))
+	for i, id := range current {
+		available[id] = append(available[id], i)
+	}
+
+	keep = make([]int, len(next))
+	used := make([]bool, len(current))
+	for i, id := range next {
+		idx := available[id]
+		if len(idx) == 0 {
+			keep[i] = -1
+			continue
+		}
+		keep[i] = idx[0]
+		used[idx[0]] = true
+		available[id] = idx[1:]
+	}
+
+	for i, u := range used {
+		if !u {
+			stop = append(stop, i)
+		}
+	}
+	return keep, stop
+}
+
+// pipelineIDs returns the plugin identifiers of the named pipelines by
+// pipeline name.
+func pipelineIDs(pipelines []*config.Pipeline) map[string][]string {
+	ids := make(map[string][]string, len(pipelines))
+	for _, p := range pipelines {
+		ids[p.Name] = append(ids[p.Name], processorChain(p.Processors)...)
+		ids[p.Name] = append(ids[p.Name], aggregatorIDs(p.Aggregators)...)
+		ids[p.Name] = append(ids[p.Name], processorChain(p.AggProcessors)...)
+		ids[p.Name] = append(ids[p.Name], outputIDs(p.Outputs)...)
+	}
+	return ids
+}
+
+func inputIDs(inputs []*models.RunningInput) []string {
+	ids := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		ids = append(ids, input.Config.ID)
+	}
+	return ids
+}
+
+func outputIDs(outputs []*models.RunningOutput) []string {
+	ids := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		ids = append(ids, output.Config.ID)
+	}
+	return ids
+}
+
+func processorIDs(processors []*models.RunningProcessor) []string {
+	ids := make([]string, 0, len(processors))
+	for _, processor := range processors {
+		ids = append(ids, processor.Config.ID)
+	}
+	return ids
+}
+
+// processorChain returns the processor identifiers in the order the processors
+// are chained by startChain.  The running processors are sorted in place when
+// the chain is started, so the order is compared on sorted copies.
+func processorChain(processors []*models.RunningProcessor) []string {
+	sorted := make([]*models.RunningProcessor, len(processors))
+	copy(sorted, processors)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Config.Order > sorted[j].Config.Order
+	})
+	return processorIDs(sorted)
+}
+
+func aggregatorIDs(aggregators []*models.RunningAggregator) []string {
+	ids := make([]string, 0, len(aggregators))
+	for _, aggregator := range aggregators {
+		ids = append(ids, aggregator.Config.ID)
+	}
+	return ids
+}
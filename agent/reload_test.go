@@ -0,0 +1,458 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/stretchr/testify/require"
+)
+
+type mockInput struct {
+	name string
+}
+
+func (i *mockInput) SampleConfig() string { return "" }
+func (i *mockInput) Description() string  { return "" }
+
+func (i *mockInput) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields(i.name, map[string]interface{}{"value": 42}, nil)
+	return nil
+}
+
+type mockOutput struct {
+	sync.Mutex
+	metrics []telegraf.Metric
+	closed  bool
+}
+
+func (o *mockOutput) SampleConfig() string { return "" }
+func (o *mockOutput) Description() string  { return "" }
+func (o *mockOutput) Connect() error       { return nil }
+
+func (o *mockOutput) Close() error {
+	o.Lock()
+	defer o.Unlock()
+	o.closed = true
+	return nil
+}
+
+func (o *mockOutput) Write(metrics []telegraf.Metric) error {
+	o.Lock()
+	defer o.Unlock()
+	o.metrics = append(o.metrics, metrics...)
+	return nil
+}
+
+func (o *mockOutput) received(name string) bool {
+	o.Lock()
+	defer o.Unlock()
+	for _, m := range o.metrics {
+		if m.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *mockOutput) receivedTag(key, value string) bool {
+	o.Lock()
+	defer o.Unlock()
+	for _, m := range o.metrics {
+		if v, ok := m.GetTag(key); ok && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *mockOutput) isClosed() bool {
+	o.Lock()
+	defer o.Unlock()
+	return o.closed
+}
+
+type mockProcessor struct {
+	tag string
+}
+
+func (p *mockProcessor) SampleConfig() string { return "" }
+func (p *mockProcessor) Description() string  { return "" }
+
+func (p *mockProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		m.AddTag("processed", p.tag)
+	}
+	return in
+}
+
+func newReloadConfig() *config.Config {
+	c := config.NewConfig()
+	c.Agent.Interval = config.Duration(10 * time.Millisecond)
+	c.Agent.FlushInterval = config.Duration(10 * time.Millisecond)
+	c.Agent.RoundInterval = false
+	return c
+}
+
+func addMockInput(c *config.Config, name string) *mockInput {
+	input := &mockInput{name: name}
+	c.Inputs = append(c.Inputs, models.NewRunningInput(input, &models.InputConfig{Name: "mock", ID: name}))
+	return input
+}
+
+func addMockOutput(c *config.Config, id string) *mockOutput {
+	output := &mockOutput{}
+	c.Outputs = append(c.Outputs, models.NewRunningOutput(output, &models.OutputConfig{Name: "mock", ID: id}, 0, 0))
+	return output
+}
+
+func TestReload(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	kept := addMockOutput(c, "kept")
+	removed := addMockOutput(c, "removed")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return kept.received("foo") && removed.received("foo")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	next := newReloadConfig()
+	addMockInput(next, "foo")
+	addMockInput(next, "bar")
+	replaced := addMockOutput(next, "kept")
+	added := addMockOutput(next, "added")
+
+	require.NoError(t, a.Reload(next))
+
+	// Unchanged output keeps running with its original instance
+	require.Len(t, a.Config.Outputs, 2)
+	require.Same(t, kept, a.Config.Outputs[0].Output)
+	require.Same(t, added, a.Config.Outputs[1].Output)
+	require.False(t, kept.isClosed())
+	require.True(t, removed.isClosed())
+
+	require.Eventually(t, func() bool {
+		return kept.received("bar") && added.received("foo") && added.received("bar")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.False(t, replaced.received("foo"))
+
+	cancel()
+	require.NoError(t, <-done)
+	require.True(t, kept.isClosed())
+	require.True(t, added.isClosed())
+}
+
+func addMockProcessor(c *config.Config, tag string) {
+	processor := processors.NewStreamingProcessorFromProcessor(&mockProcessor{tag: tag})
+	c.Processors = append(c.Processors, models.NewRunningProcessor(processor, &models.ProcessorConfig{Name: "mock", ID: tag}))
+}
+
+func TestReloadProcessors(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	addMockProcessor(c, "before")
+	output := addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return output.receivedTag("processed", "before")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	next := newReloadConfig()
+	addMockInput(next, "foo")
+	addMockProcessor(next, "after")
+	addMockOutput(next, "out")
+	require.NoError(t, a.Reload(next))
+	require.Same(t, output, a.Config.Outputs[0].Output)
+
+	require.Eventually(t, func() bool {
+		return output.receivedTag("processed", "after")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+type mockAggregator struct {
+	sync.Mutex
+	count int
+}
+
+func (m *mockAggregator) SampleConfig() string { return "" }
+func (m *mockAggregator) Description() string  { return "" }
+
+func (m *mockAggregator) Add(telegraf.Metric) {
+	m.Lock()
+	defer m.Unlock()
+	m.count++
+}
+
+func (m *mockAggregator) Push(acc telegraf.Accumulator) {
+	m.Lock()
+	defer m.Unlock()
+	acc.AddFields("aggregate", map[string]interface{}{"count": m.count}, nil)
+}
+
+func (m *mockAggregator) Reset() {
+	m.Lock()
+	defer m.Unlock()
+	m.count = 0
+}
+
+func addMockAggregator(c *config.Config, id string) {
+	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(&mockAggregator{}, &models.AggregatorConfig{
+		Name:   "mock",
+		ID:     id,
+		Period: 20 * time.Millisecond,
+	}))
+}
+
+func addMockAggProcessor(c *config.Config, tag string) {
+	processor := processors.NewStreamingProcessorFromProcessor(&mockProcessor{tag: tag})
+	c.AggProcessors = append(c.AggProcessors, models.NewRunningProcessor(processor, &models.ProcessorConfig{Name: "mock", ID: tag}))
+}
+
+func TestReloadAggProcessors(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	addMockAggregator(c, "agg")
+	addMockAggProcessor(c, "before")
+	output := addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return output.receivedTag("processed", "before")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	next := newReloadConfig()
+	addMockInput(next, "foo")
+	addMockAggregator(next, "agg")
+	addMockAggProcessor(next, "after")
+	addMockOutput(next, "out")
+	require.NoError(t, a.Reload(next))
+	require.Same(t, next.AggProcessors[0], a.Config.AggProcessors[0])
+
+	require.Eventually(t, func() bool {
+		return output.receivedTag("processed", "after")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestReloadProcessorOrder(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	addMockProcessor(c, "first")
+	addMockProcessor(c, "second")
+	output := addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return output.receivedTag("processed", "first")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	next := newReloadConfig()
+	addMockInput(next, "foo")
+	addMockProcessor(next, "second")
+	addMockProcessor(next, "first")
+	addMockOutput(next, "out")
+	require.NoError(t, a.Reload(next))
+	require.Same(t, output, a.Config.Outputs[0].Output)
+
+	require.Eventually(t, func() bool {
+		return output.receivedTag("processed", "second")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestReloadDiskBufferedOutputChanged(t *testing.T) {
+	dir := t.TempDir()
+	addDiskOutput := func(c *config.Config, id string) {
+		c.Outputs = append(c.Outputs, models.NewRunningOutput(&mockOutput{}, &models.OutputConfig{
+			Name:            "mock",
+			ID:              id,
+			BufferStrategy:  "disk",
+			BufferDirectory: dir,
+		}, 0, 0))
+	}
+
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	addDiskOutput(c, "before")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	next := newReloadConfig()
+	addMockInput(next, "foo")
+	addDiskOutput(next, "after")
+
+	require.Eventually(t, func() bool {
+		a.runningMu.Lock()
+		defer a.runningMu.Unlock()
+		return a.running != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, a.Reload(next), ErrRestartRequired)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestReloadAgentSettingsChanged(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	next := newReloadConfig()
+	next.Agent.Interval = config.Duration(time.Second)
+	addMockInput(next, "foo")
+	addMockOutput(next, "out")
+
+	require.Eventually(t, func() bool {
+		a.runningMu.Lock()
+		defer a.runningMu.Unlock()
+		return a.running != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, a.Reload(next), ErrRestartRequired)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestReloadPipelinesChanged(t *testing.T) {
+	c := newReloadConfig()
+	addMockInput(c, "foo")
+	addMockOutput(c, "out")
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	next := newReloadConfig()
+	addMockInput(next, "foo")
+	addMockOutput(next, "out")
+	next.Pipelines = append(next.Pipelines, &config.Pipeline{Name: "security"})
+
+	require.Eventually(t, func() bool {
+		a.runningMu.Lock()
+		defer a.runningMu.Unlock()
+		return a.running != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, a.Reload(next), ErrRestartRequired)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestPipelineIDsAggProcessors(t *testing.T) {
+	newPipeline := func(tag string) *config.Pipeline {
+		processor := processors.NewStreamingProcessorFromProcessor(&mockProcessor{tag: tag})
+		return &config.Pipeline{
+			Name:          "security",
+			AggProcessors: models.RunningProcessors{models.NewRunningProcessor(processor, &models.ProcessorConfig{Name: "mock", ID: tag})},
+		}
+	}
+
+	before := pipelineIDs([]*config.Pipeline{newPipeline("before")})
+	after := pipelineIDs([]*config.Pipeline{newPipeline("after")})
+	require.NotEqual(t, before, after)
+}
+
+func TestDiffIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []string
+		next    []string
+		keep    []int
+		stop    []int
+	}{
+		{
+			name:    "unchanged",
+			current: []string{"a", "b"},
+			next:    []string{"b", "a"},
+			keep:    []int{1, 0},
+		},
+		{
+			name:    "added and removed",
+			current: []string{"a", "b"},
+			next:    []string{"a", "c"},
+			keep:    []int{0, -1},
+			stop:    []int{1},
+		},
+		{
+			name:    "duplicates",
+			current: []string{"a", "a", "a"},
+			next:    []string{"a", "a"},
+			keep:    []int{0, 1},
+			stop:    []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, stop := diffIDs(tt.current, tt.next)
+			require.Equal(t, tt.keep, keep)
+			require.Equal(t, tt.stop, stop)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/robfig/cron/v3"
 )
 
 type Ticker interface {
@@ -271,3 +272,85 @@ func (t *RollingTicker) Stop() {
 	t.cancel()
 	t.wg.Wait()
 }
+
+// CronTicker delivers ticks at the activation times of a cron schedule plus an
+// optional jitter.  Each tick is scheduled from the previous activation to
+// avoid skipping activations due to the jitter, missed activations are not
+// caught up.
+//
+// The first tick is emitted at the next activation.
+//
+// Ticks are dropped for slow consumers.
+type CronTicker struct {
+	schedule cron.Schedule
+	jitter   time.Duration
+	next     time.Time
+	ch       chan time.Time
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewCronTicker(schedule cron.Schedule, jitter time.Duration) *CronTicker {
+	return newCronTicker(schedule, jitter, clock.New())
+}
+
+func newCronTicker(schedule cron.Schedule, jitter time.Duration, clock clock.Clock) *CronTicker {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &CronTicker{
+		schedule: schedule,
+		jitter:   jitter,
+		ch:       make(chan time.Time, 1),
+		cancel:   cancel,
+	}
+
+	now := clock.Now()
+	t.next = schedule.Next(now)
+	timer := clock.Timer(t.delay(now))
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.run(ctx, timer)
+	}()
+
+	return t
+}
+
+// delay returns the duration from now until the next activation plus jitter.
+func (t *CronTicker) delay(now time.Time) time.Duration {
+	d := t.next.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d + internal.RandomDuration(t.jitter)
+}
+
+func (t *CronTicker) run(ctx context.Context, timer *clock.Timer) {
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			select {
+			case t.ch <- now:
+			default:
+			}
+
+			t.next = t.schedule.Next(t.next)
+			if !t.next.After(now) {
+				t.next = t.schedule.Next(now)
+			}
+			timer.Reset(t.delay(now))
+		}
+	}
+}
+
+func (t *CronTicker) Elapsed() <-chan time.Time {
+	return t.ch
+}
+
+func (t *CronTicker) Stop() {
+	t.cancel()
+	t.wg.Wait()
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/influxdata/telegraf/models"
 	"github.com/stretchr/testify/require"
 )
 
@@ -158,6 +159,77 @@ func TestRollingTicker(t *testing.T) {
 	require.Equal(t, expected, actual)
 }
 
+func TestCronTicker(t *testing.T) {
+	schedule, err := models.ParseSchedule("0 */6 * * *", "UTC")
+	require.NoError(t, err)
+
+	clock := clock.NewMock()
+	since := clock.Now()
+	until := since.Add(24 * time.Hour)
+
+	ticker := newCronTicker(schedule, 0, clock)
+	defer ticker.Stop()
+
+	expected := []time.Time{
+		time.Unix(6*3600, 0).UTC(),
+		time.Unix(12*3600, 0).UTC(),
+		time.Unix(18*3600, 0).UTC(),
+		time.Unix(24*3600, 0).UTC(),
+	}
+
+	actual := []time.Time{}
+	for !clock.Now().After(until) {
+		select {
+		case tm := <-ticker.Elapsed():
+			actual = append(actual, tm.UTC())
+		default:
+		}
+		clock.Add(time.Hour)
+	}
+
+	require.Equal(t, expected, actual)
+}
+
+func TestCronTickerTimezone(t *testing.T) {
+	schedule, err := models.ParseSchedule("30 2 * * *", "America/New_York")
+	require.NoError(t, err)
+
+	// Midnight UTC is 19:00 of the previous day in New York, the next
+	// activation is at 02:30 EST or 07:30 UTC.
+	clock := clock.NewMock()
+	ticker := newCronTicker(schedule, 0, clock)
+	defer ticker.Stop()
+
+	clock.Add(7*time.Hour + 30*time.Minute)
+	tm := <-ticker.Elapsed()
+	require.Equal(t, time.Unix(7*3600+30*60, 0).UTC(), tm.UTC())
+}
+
+func TestCronTickerJitter(t *testing.T) {
+	schedule, err := models.ParseSchedule("@hourly", "")
+	require.NoError(t, err)
+
+	clock := clock.NewMock()
+	ticker := newCronTicker(schedule, 10*time.Minute, clock)
+	defer ticker.Stop()
+
+	// The activations stay on the schedule, the jitter only delays them.
+	for i := 1; i <= 5; i++ {
+		start := time.Unix(int64(i)*3600, 0)
+		clock.Set(start)
+		select {
+		case <-ticker.Elapsed():
+			t.Fatal("tick before activation")
+		default:
+		}
+
+		clock.Add(10 * time.Minute)
+		tm := <-ticker.Elapsed()
+		require.False(t, tm.Before(start))
+		require.False(t, tm.After(start.Add(10*time.Minute)))
+	}
+}
+
 // Simulates running the Ticker for an hour and displays stats about the
 // operation.
 func TestAlignedTickerDistribution(t *testing.T) {
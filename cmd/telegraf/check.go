@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+// runCheck validates the configuration given on the command line without
+// running it, printing all problems found.  An error is returned if the
+// configuration is invalid.
+func runCheck(inputFilters []string, outputFilters []string) error {
+	c := config.NewConfig()
+	c.OutputFilters = outputFilters
+	c.InputFilters = inputFilters
+	c.Validating = true
+	if err := loadConfigFiles(c); err != nil {
+		return err
+	}
+
+	problems := c.Validate()
+	if err := checkConfig(c); err != nil {
+		problems = append(problems, &config.Problem{Err: err})
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s) in the configuration", len(problems))
+	}
+	fmt.Println("Configuration is valid")
+	return nil
+}
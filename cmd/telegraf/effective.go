@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// runPrintEffectiveConfig loads the configuration given on the command line,
+// initializes the plugins without starting them and prints the resolved
+// configuration with the secrets masked.
+func runPrintEffectiveConfig(inputFilters []string, outputFilters []string) error {
+	c, err := loadConfig(inputFilters, outputFilters)
+	if err != nil {
+		return err
+	}
+
+	// Initializing the plugins fills in the defaults set by the plugins.
+	problems := c.Validate()
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s) in the configuration", len(problems))
+	}
+
+	return c.PrintEffectiveConfig(os.Stdout)
+}
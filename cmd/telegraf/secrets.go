@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"golang.org/x/term"
+)
+
+// runSecrets implements the secrets command managing the secrets of the secret
+// stores in the configuration.
+func runSecrets(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing secrets subcommand, use one of 'list', 'get' or 'set'")
+	}
+
+	c, err := loadSecretStores()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) > 2 {
+			return errors.New("usage: telegraf secrets list [id]")
+		}
+		ids := make([]string, 0, len(c.SecretStores))
+		for id := range c.SecretStores {
+			if len(args) == 1 || args[1] == id {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 && len(args) == 2 {
+			return fmt.Errorf("unknown secret store %q", args[1])
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			keys, err := c.SecretStores[id].List()
+			if err != nil {
+				return fmt.Errorf("listing secrets of %q failed: %w", id, err)
+			}
+			fmt.Printf("%s:\n", id)
+			for _, key := range keys {
+				fmt.Printf("  %s\n", key)
+			}
+		}
+	case "get":
+		if len(args) != 3 {
+			return errors.New("usage: telegraf secrets get <id> <key>")
+		}
+		store, err := secretStore(c, args[1])
+		if err != nil {
+			return err
+		}
+		value, err := store.Get(args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(value))
+	case "set":
+		if len(args) != 3 && len(args) != 4 {
+			return errors.New("usage: telegraf secrets set <id> <key> [value]")
+		}
+		store, err := secretStore(c, args[1])
+		if err != nil {
+			return err
+		}
+		var value []byte
+		if len(args) == 4 {
+			value = []byte(args[3])
+		} else if value, err = readSecret(args[2]); err != nil {
+			return err
+		}
+		return store.Set(args[2], value)
+	default:
+		return fmt.Errorf("unknown secrets subcommand %q, use one of 'list', 'get' or 'set'", args[0])
+	}
+	return nil
+}
+
+// loadSecretStores loads the secret stores from the configuration files given
+// on the command line, ignoring all other plugins.
+func loadSecretStores() (*config.Config, error) {
+	c := config.NewConfig()
+	c.SecretStoresOnly = true
+	if err := loadConfigFiles(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func secretStore(c *config.Config, id string) (telegraf.SecretStore, error) {
+	store, ok := c.SecretStores[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret store %q", id)
+	}
+	return store, nil
+}
+
+// readSecret reads the secret from stdin, prompting for it without echo if
+// stdin is a terminal.
+func readSecret(key string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		value, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimSuffix(bytes.TrimSuffix(value, []byte("\n")), []byte("\r")), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter secret for %q: ", key)
+	value, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return value, err
+}
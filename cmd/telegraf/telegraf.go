@@ -12,6 +12,7 @@ import (
 	"os/signal"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/outputs"
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
 	_ "github.com/influxdata/telegraf/plugins/processors/all"
+	_ "github.com/influxdata/telegraf/plugins/secretstores/all"
 	"gopkg.in/tomb.v1"
 )
 
@@ -52,9 +54,12 @@ var fQuiet = flag.Bool("quiet", false,
 	"run in quiet mode")
 var fTest = flag.Bool("test", false, "enable test mode: gather metrics, print them out, and exit. Note: Test mode only runs inputs, not processors, aggregators, or outputs")
 var fTestWait = flag.Int("test-wait", 0, "wait up to this many seconds for service inputs to complete in test mode")
+var fTestPipeline = flag.Bool("test-pipeline", false, "enable pipeline test mode: gather metrics, run processors and aggregators, print the serialized metrics of each output, and exit. Note: Outputs are not connected")
 
 var fConfigs sliceFlags
 var fConfigDirs sliceFlags
+var fConfigDirFormats = flag.String("config-directory-formats", "",
+	"formats besides TOML of the files loaded from config directories, separator is : [yaml, json]")
 var fWatchConfig = flag.String("watch-config", "", "Monitoring config changes [notify, poll]")
 var fVersion = flag.Bool("version", false, "display the version and exit")
 var fSampleConfig = flag.Bool("sample-config", false,
@@ -95,6 +100,8 @@ var fRunAsConsole = flag.Bool("console", false,
 var fPlugins = flag.String("plugin-directory", "",
 	"path to directory containing external plugins")
 var fRunOnce = flag.Bool("once", false, "run one gather and exit")
+var fValidate = flag.Bool("validate", false, "validate the configuration, report all problems and exit")
+var fPrintEffectiveConfig = flag.Bool("print-effective-config", false, "print the configuration as loaded, including the defaults of the plugins, with secrets masked and exit")
 
 var (
 	version string
@@ -104,6 +111,13 @@ var (
 
 var stop chan struct{}
 
+// runningAgent is the agent currently started by runAgent, it is used to apply
+// configuration changes without restarting the agent.
+var runningAgent struct {
+	sync.Mutex
+	agent *agent.Agent
+}
+
 func reloadLoop(
 	inputFilters []string,
 	outputFilters []string,
@@ -117,114 +131,173 @@ func reloadLoop(
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt, syscall.SIGHUP,
 			syscall.SIGTERM, syscall.SIGINT)
-		if *fWatchConfig != "" {
-			for _, fConfig := range fConfigs {
-				if _, err := os.Stat(fConfig); err == nil {
-					go watchLocalConfig(signals, fConfig)
-				} else {
-					log.Printf("W! Cannot watch config %s: %s", fConfig, err)
-				}
-			}
-		}
+		watchConfigs(ctx, signals)
 		go func() {
-			select {
-			case sig := <-signals:
-				if sig == syscall.SIGHUP {
-					log.Printf("I! Reloading Telegraf config")
-					<-reload
-					reload <- true
+			for {
+				select {
+				case sig := <-signals:
+					if sig == syscall.SIGHUP {
+						log.Printf("I! Reloading Telegraf config")
+						err := reloadAgent(inputFilters, outputFilters)
+						if err == nil {
+							continue
+						}
+						log.Printf("I! Restarting Telegraf: %v", err)
+						<-reload
+						reload <- true
+					}
+					cancel()
+				case <-stop:
+					cancel()
 				}
-				cancel()
-			case <-stop:
-				cancel()
+				return
 			}
 		}()
 
-		err := runAgent(ctx, inputFilters, outputFilters)
+		err := runAgent(ctx, inputFilters, outputFilters, signals)
 		if err != nil && err != context.Canceled {
 			log.Fatalf("E! [telegraf] Error running agent: %v", err)
 		}
 	}
 }
 
-func watchLocalConfig(signals chan os.Signal, fConfig string) {
-	var mytomb tomb.Tomb
-	var watcher watch.FileWatcher
-	if *fWatchConfig == "poll" {
-		watcher = watch.NewPollingFileWatcher(fConfig)
-	} else {
-		watcher = watch.NewInotifyFileWatcher(fConfig)
+// reloadAgent applies the configuration to the running agent, only restarting
+// the plugins that changed.
+func reloadAgent(inputFilters []string, outputFilters []string) error {
+	runningAgent.Lock()
+	ag := runningAgent.agent
+	runningAgent.Unlock()
+	if ag == nil {
+		return errors.New("agent is not running")
 	}
-	changes, err := watcher.ChangeEvents(&mytomb, 0)
+
+	c, err := loadConfig(inputFilters, outputFilters)
 	if err != nil {
-		log.Printf("E! Error watching config: %s\n", err)
+		return err
+	}
+	return ag.Reload(c)
+}
+
+func watchConfigs(ctx context.Context, signals chan os.Signal) {
+	if *fWatchConfig == "" {
 		return
 	}
-	log.Println("I! Config watcher started")
-	select {
-	case <-changes.Modified:
-		log.Println("I! Config file modified")
-	case <-changes.Deleted:
-		// deleted can mean moved. wait a bit a check existence
-		<-time.After(time.Second)
+	for _, fConfig := range fConfigs {
 		if _, err := os.Stat(fConfig); err == nil {
-			log.Println("I! Config file overwritten")
+			go watchLocalConfig(ctx, signals, fConfig)
 		} else {
-			log.Println("W! Config file deleted")
-			if err := watcher.BlockUntilExists(&mytomb); err != nil {
-				log.Printf("E! Cannot watch for config: %s\n", err.Error())
-				return
-			}
-			log.Println("I! Config file appeared")
+			log.Printf("W! Cannot watch config %s: %s", fConfig, err)
 		}
-	case <-changes.Truncated:
-		log.Println("I! Config file truncated")
-	case <-mytomb.Dying():
-		log.Println("I! Config watcher ended")
-		return
 	}
-	mytomb.Done()
-	signals <- syscall.SIGHUP
 }
 
-func runAgent(ctx context.Context,
-	inputFilters []string,
-	outputFilters []string,
-) error {
-	log.Printf("I! Starting Telegraf %s", version)
+// watchLocalConfig sends SIGHUP whenever the config file changes until the
+// context is done.
+func watchLocalConfig(ctx context.Context, signals chan os.Signal, fConfig string) {
+	for {
+		var mytomb tomb.Tomb
+		var watcher watch.FileWatcher
+		if *fWatchConfig == "poll" {
+			watcher = watch.NewPollingFileWatcher(fConfig)
+		} else {
+			watcher = watch.NewInotifyFileWatcher(fConfig)
+		}
+		changes, err := watcher.ChangeEvents(&mytomb, 0)
+		if err != nil {
+			log.Printf("E! Error watching config: %s\n", err)
+			return
+		}
+		log.Println("I! Config watcher started")
+		select {
+		case <-changes.Modified:
+			log.Println("I! Config file modified")
+		case <-changes.Deleted:
+			// deleted can mean moved. wait a bit a check existence
+			<-time.After(time.Second)
+			if _, err := os.Stat(fConfig); err == nil {
+				log.Println("I! Config file overwritten")
+			} else {
+				log.Println("W! Config file deleted")
+				if err := watcher.BlockUntilExists(&mytomb); err != nil {
+					log.Printf("E! Cannot watch for config: %s\n", err.Error())
+					return
+				}
+				log.Println("I! Config file appeared")
+			}
+		case <-changes.Truncated:
+			log.Println("I! Config file truncated")
+		case <-mytomb.Dying():
+			log.Println("I! Config watcher ended")
+			return
+		case <-ctx.Done():
+			mytomb.Kill(nil)
+			return
+		}
+		mytomb.Done()
 
-	// If no other options are specified, load the config file and run.
+		select {
+		case signals <- syscall.SIGHUP:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadConfig loads the configuration from the files and directories given on
+// the command line.
+func loadConfig(inputFilters []string, outputFilters []string) (*config.Config, error) {
 	c := config.NewConfig()
 	c.OutputFilters = outputFilters
 	c.InputFilters = inputFilters
-	var err error
+	if err := loadConfigFiles(c); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadConfigFiles loads the files and directories given on the command line
+// into c.
+func loadConfigFiles(c *config.Config) error {
 	// providing no "config" flag should load default config
 	if len(fConfigs) == 0 {
-		err = c.LoadConfig("")
-		if err != nil {
+		if err := c.LoadConfig(""); err != nil {
 			return err
 		}
 	}
 	for _, fConfig := range fConfigs {
-		err = c.LoadConfig(fConfig)
-		if err != nil {
+		if err := c.LoadConfig(fConfig); err != nil {
 			return err
 		}
 	}
 
+	if *fConfigDirFormats != "" {
+		c.DirectoryFormats = strings.Split(*fConfigDirFormats, ":")
+	}
 	for _, fConfigDirectory := range fConfigDirs {
-		err = c.LoadDirectory(fConfigDirectory)
-		if err != nil {
+		if err := c.LoadDirectory(fConfigDirectory); err != nil {
 			return err
 		}
 	}
 
-	if !*fTest && len(c.Outputs) == 0 {
+	// Secret stores may be defined in any of the files, so the secrets are
+	// resolved once everything is loaded.
+	return c.ResolveSecrets()
+}
+
+// checkConfig checks that the loaded configuration can be run.
+func checkConfig(c *config.Config) error {
+	if !*fTest && len(c.AllOutputs()) == 0 {
 		return errors.New("Error: no outputs found, did you provide a valid config file?")
 	}
 	if *fPlugins == "" && len(c.Inputs) == 0 {
 		return errors.New("Error: no inputs found, did you provide a valid config file?")
 	}
+	if err := c.CheckPipelines(); err != nil {
+		return err
+	}
 
 	if int64(c.Agent.Interval) <= 0 {
 		return fmt.Errorf("Agent interval must be positive, found %v", c.Agent.Interval)
@@ -233,18 +306,42 @@ func runAgent(ctx context.Context,
 	if int64(c.Agent.FlushInterval) <= 0 {
 		return fmt.Errorf("Agent flush_interval must be positive; found %v", c.Agent.Interval)
 	}
+	return nil
+}
+
+func runAgent(ctx context.Context,
+	inputFilters []string,
+	outputFilters []string,
+	signals chan os.Signal,
+) error {
+	log.Printf("I! Starting Telegraf %s", version)
+
+	// If no other options are specified, load the config file and run.
+	c, err := loadConfig(inputFilters, outputFilters)
+	if err != nil {
+		return err
+	}
 
 	ag, err := agent.NewAgent(c)
 	if err != nil {
 		return err
 	}
 
+	// Reloads requested via the control API are handled like SIGHUP.
+	ag.RequestReload = func() {
+		select {
+		case signals <- syscall.SIGHUP:
+		default:
+		}
+	}
+
 	// Setup logging as configured.
 	telegraf.Debug = ag.Config.Agent.Debug || *fDebug
 	logConfig := logger.LogConfig{
 		Debug:               telegraf.Debug,
 		Quiet:               ag.Config.Agent.Quiet || *fQuiet,
 		LogTarget:           ag.Config.Agent.LogTarget,
+		LogFormat:           ag.Config.Agent.LogFormat,
 		Logfile:             ag.Config.Agent.Logfile,
 		RotationInterval:    ag.Config.Agent.LogfileRotationInterval,
 		RotationMaxSize:     ag.Config.Agent.LogfileRotationMaxSize,
@@ -259,6 +356,11 @@ func runAgent(ctx context.Context,
 		return ag.Once(ctx, wait)
 	}
 
+	if *fTestPipeline {
+		wait := time.Duration(*fTestWait) * time.Second
+		return ag.TestPipeline(ctx, wait)
+	}
+
 	if *fTest || *fTestWait != 0 {
 		wait := time.Duration(*fTestWait) * time.Second
 		return ag.Test(ctx, wait)
@@ -288,6 +390,15 @@ func runAgent(ctx context.Context,
 		}
 	}
 
+	runningAgent.Lock()
+	runningAgent.agent = ag
+	runningAgent.Unlock()
+	defer func() {
+		runningAgent.Lock()
+		runningAgent.agent = nil
+		runningAgent.Unlock()
+	}()
+
 	return ag.Run(ctx)
 }
 
@@ -379,6 +490,12 @@ func main() {
 			fmt.Println(formatFullVersion())
 			return
 		case "config":
+			if len(args) > 1 && args[1] == "check" {
+				if err := runCheck(inputFilters, outputFilters); err != nil {
+					log.Fatal("E! " + err.Error())
+				}
+				return
+			}
 			config.PrintSampleConfig(
 				sectionFilters,
 				inputFilters,
@@ -387,6 +504,11 @@ func main() {
 				processorFilters,
 			)
 			return
+		case "secrets":
+			if err := runSecrets(args[1:]); err != nil {
+				log.Fatal("E! " + err.Error())
+			}
+			return
 		}
 	}
 
@@ -426,6 +548,16 @@ func main() {
 			processorFilters,
 		)
 		return
+	case *fValidate:
+		if err := runCheck(inputFilters, outputFilters); err != nil {
+			log.Fatal("E! " + err.Error())
+		}
+		return
+	case *fPrintEffectiveConfig:
+		if err := runPrintEffectiveConfig(inputFilters, outputFilters); err != nil {
+			log.Fatal("E! " + err.Error())
+		}
+		return
 	case *fUsage != "":
 		err := config.PrintInputConfig(*fUsage)
 		err2 := config.PrintOutputConfig(*fUsage)
@@ -85,6 +85,9 @@ func runAsWindowsService(inputFilters, outputFilters []string) {
 		for _, fConfigDirectory := range fConfigDirs {
 			svcConfig.Arguments = append(svcConfig.Arguments, "--config-directory", fConfigDirectory)
 		}
+		if *fConfigDirFormats != "" {
+			svcConfig.Arguments = append(svcConfig.Arguments, "--config-directory-formats", *fConfigDirFormats)
+		}
 
 		//set servicename to service cmd line, to have a custom name after relaunch as a service
 		svcConfig.Arguments = append(svcConfig.Arguments, "--service-name", *fServiceName)
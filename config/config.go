@@ -2,6 +2,9 @@ package config
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,6 +18,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -25,6 +29,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/parsers/binary"
 	"github.com/influxdata/telegraf/plugins/parsers/json_v2"
 	"github.com/influxdata/telegraf/plugins/processors"
 	"github.com/influxdata/telegraf/plugins/serializers"
@@ -70,6 +75,33 @@ type Config struct {
 	InputFilters  []string
 	OutputFilters []string
 
+	// SecretStoresOnly restricts loading the configuration to the secret
+	// stores, all other plugins are skipped.
+	SecretStoresOnly bool
+
+	// DirectoryFormats are the formats besides TOML of the files loaded from
+	// configuration directories, "yaml" or "json".  By default only TOML files
+	// are loaded, as the directories might contain other data files.
+	DirectoryFormats []string
+
+	SecretStores map[string]telegraf.SecretStore
+
+	// Validating makes loading continue after errors in the configuration,
+	// recording them in Problems instead of stopping at the first one.
+	Validating bool
+	Problems   []*Problem
+
+	file         string                   // file currently loaded
+	locations    map[interface{}]location // positions of the tables by plugin instance
+	commonFields map[string]bool          // common fields set for the plugin when validating
+
+	templates    map[string]*template.Template // templates defined by [templates] tables
+	includeDepth int                           // nesting of the includes currently loaded
+
+	tables         map[string]*ast.Table  // tables of the plugins by ID
+	secretRefs     map[secretField]string // references of the settings holding resolved secrets
+	pendingSecrets []pendingSecrets        // plugins whose secrets are not resolved yet
+
 	Agent       *AgentConfig
 	Inputs      []*models.RunningInput
 	Outputs     []*models.RunningOutput
@@ -77,6 +109,10 @@ type Config struct {
 	// Processors have a slice wrapper type because they need to be sorted
 	Processors    models.RunningProcessors
 	AggProcessors models.RunningProcessors
+
+	// Pipelines are the named pipelines, fed only by the inputs selecting
+	// them.
+	Pipelines []*Pipeline
 }
 
 // NewConfig creates a new struct to hold the Telegraf config.
@@ -85,6 +121,11 @@ type Config struct {
 func NewConfig() *Config {
 	c := &Config{
 		UnusedFields: map[string]bool{},
+		locations:    map[interface{}]location{},
+		commonFields: map[string]bool{},
+		templates:    map[string]*template.Template{},
+		tables:       map[string]*ast.Table{},
+		secretRefs:   map[secretField]string{},
 
 		// Agent defaults:
 		Agent: &AgentConfig{
@@ -100,6 +141,7 @@ func NewConfig() *Config {
 		Outputs:       make([]*models.RunningOutput, 0),
 		Processors:    make([]*models.RunningProcessor, 0),
 		AggProcessors: make([]*models.RunningProcessor, 0),
+		SecretStores:  make(map[string]telegraf.SecretStore),
 		InputFilters:  make([]string, 0),
 		OutputFilters: make([]string, 0),
 	}
@@ -157,6 +199,14 @@ type AgentConfig struct {
 	// not be less than 2 times MetricBatchSize.
 	MetricBufferLimit int
 
+	// BufferStrategy is the default type of buffer used by the outputs to
+	// hold unwritten metrics, either "memory" or "disk".
+	BufferStrategy string `toml:"buffer_strategy"`
+
+	// BufferDirectory is the directory the "disk" buffer strategy stores the
+	// write-ahead-logs of the outputs in.
+	BufferDirectory string `toml:"buffer_directory"`
+
 	// FlushBufferWhenFull tells Telegraf to flush the metric buffer whenever
 	// it fills up, regardless of FlushInterval. Setting this option to true
 	// does _not_ deactivate FlushInterval.
@@ -174,10 +224,14 @@ type AgentConfig struct {
 	Quiet bool `toml:"quiet"`
 
 	// Log target controls the destination for logs and can be one of "file",
-	// "stderr" or, on Windows, "eventlog".  When set to "file", the output file
-	// is determined by the "logfile" setting.
+	// "stderr", "syslog" or, on Windows, "eventlog".  When set to "file", the
+	// output file is determined by the "logfile" setting.
 	LogTarget string `toml:"logtarget"`
 
+	// Log format controls the format of the log lines and can be "text" or
+	// "json".
+	LogFormat string `toml:"log_format"`
+
 	// Name of the file to be logged to when using the "file" logtarget.  If set to
 	// the empty string then logs are written to stderr.
 	Logfile string `toml:"logfile"`
@@ -199,6 +253,14 @@ type AgentConfig struct {
 
 	Hostname     string
 	OmitHostname bool
+
+	// APIAddress is the address the local HTTP control API listens on.  The
+	// API is disabled when empty.
+	APIAddress string `toml:"api_address"`
+
+	// MetricsAddress is the address serving the internal statistics of the
+	// agent in the Prometheus exposition format.  Disabled when empty.
+	MetricsAddress string `toml:"metrics_address"`
 }
 
 // InputNames returns a list of strings of the configured inputs.
@@ -216,6 +278,11 @@ func (c *Config) AggregatorNames() []string {
 	for _, aggregator := range c.Aggregators {
 		name = append(name, aggregator.Config.Name)
 	}
+	for _, p := range c.Pipelines {
+		for _, aggregator := range p.Aggregators {
+			name = append(name, aggregator.Config.Name)
+		}
+	}
 	return PluginNameCounts(name)
 }
 
@@ -225,13 +292,18 @@ func (c *Config) ProcessorNames() []string {
 	for _, processor := range c.Processors {
 		name = append(name, processor.Config.Name)
 	}
+	for _, p := range c.Pipelines {
+		for _, processor := range p.Processors {
+			name = append(name, processor.Config.Name)
+		}
+	}
 	return PluginNameCounts(name)
 }
 
 // OutputNames returns a list of strings of the configured outputs.
 func (c *Config) OutputNames() []string {
 	var name []string
-	for _, output := range c.Outputs {
+	for _, output := range c.AllOutputs() {
 		name = append(name, output.Config.Name)
 	}
 	return PluginNameCounts(name)
@@ -315,6 +387,17 @@ var agentConfig = `
   ## cost of higher maximum memory usage.
   metric_buffer_limit = 10000
 
+  ## Type of buffer used by the outputs to hold unwritten metrics, can be
+  ## overridden per output.  Available strategies are:
+  ##   memory -- keep up to metric_buffer_limit metrics in memory (default)
+  ##   disk   -- persist up to metric_buffer_limit metrics in a write-ahead-log
+  ##             in buffer_directory; the buffer survives restarts of the agent
+  # buffer_strategy = "memory"
+
+  ## Directory used by the disk buffer strategy, each output uses its own
+  ## sub-directory named after the output and its alias.
+  # buffer_directory = ""
+
   ## Collection jitter is used to jitter the collection by a random amount.
   ## Each plugin will sleep for a random time within jitter before collecting.
   ## This can be used to avoid many plugins querying things like sysfs at the
@@ -344,10 +427,16 @@ var agentConfig = `
   # quiet = false
 
   ## Log target controls the destination for logs and can be one of "file",
-  ## "stderr" or, on Windows, "eventlog".  When set to "file", the output file
-  ## is determined by the "logfile" setting.
+  ## "stderr", "syslog" or, on Windows, "eventlog".  When set to "file", the
+  ## output file is determined by the "logfile" setting.  The "syslog" target
+  ## logs to the local syslog daemon, or journald on systems running systemd.
   # logtarget = "file"
 
+  ## Log format controls the format of the log lines and can be "text" or
+  ## "json".  The "json" format logs the level, plugin type, plugin name,
+  ## alias and message as separate keys.
+  # log_format = "text"
+
   ## Name of the file to be logged to when using the "file" logtarget.  If set to
   ## the empty string then logs are written to stderr.
   # logfile = ""
@@ -373,6 +462,19 @@ var agentConfig = `
   hostname = ""
   ## If set to true, do no set the "host" tag in the telegraf agent.
   omit_hostname = false
+
+  ## Address of the local HTTP control API, e.g. "localhost:9275".  The API
+  ## lists the loaded plugins and their statistics and allows to trigger a
+  ## gather, a flush or a reload of the configuration.  The API is not
+  ## authenticated, only listen on trusted interfaces.  Cross-origin requests
+  ## triggering an action are rejected.  Disabled if empty.
+  # api_address = ""
+
+  ## Address serving the internal statistics of the agent and the Go runtime
+  ## statistics in the Prometheus exposition format on /metrics, e.g.
+  ## "localhost:9274".  The statistics are available even if the outputs are
+  ## blocked.  Disabled if empty.
+  # metrics_address = ""
 `
 
 var outputHeader = `
@@ -668,8 +770,17 @@ func PrintOutputConfig(name string) error {
 	return nil
 }
 
-// LoadDirectory loads all toml config files found in the specified path, recursively.
+// LoadDirectory loads all config files found in the specified path, recursively.
+// TOML files must have the extension ".conf".  YAML files with the extension
+// ".yaml" or ".yml" and JSON files with the extension ".json" are only loaded
+// if their format is enabled in DirectoryFormats.
 func (c *Config) LoadDirectory(path string) error {
+	for _, format := range c.DirectoryFormats {
+		if format != FormatYAML && format != FormatJSON {
+			return fmt.Errorf("unsupported configuration directory format %q", format)
+		}
+	}
+
 	walkfn := func(thispath string, info os.FileInfo, _ error) error {
 		if info == nil {
 			log.Printf("W! Telegraf is not permitted to read %s", thispath)
@@ -684,8 +795,13 @@ func (c *Config) LoadDirectory(path string) error {
 
 			return nil
 		}
-		name := info.Name()
-		if len(name) < 6 || name[len(name)-5:] != ".conf" {
+		switch filepath.Ext(info.Name()) {
+		case ".conf":
+		case ".yaml", ".yml", ".json":
+			if !choice.Contains(formatFromPath(thispath), c.DirectoryFormats) {
+				return nil
+			}
+		default:
 			return nil
 		}
 		err := c.LoadConfig(thispath)
@@ -743,12 +859,23 @@ func (c *Config) LoadConfig(path string) error {
 			return err
 		}
 	}
-	data, err := loadConfig(path)
-	if err != nil {
-		return fmt.Errorf("Error loading config file %s: %w", path, err)
-	}
+	c.file = path
+	defer func() { c.file = "" }()
 
-	if err = c.LoadConfigData(data); err != nil {
+	problems := len(c.Problems)
+	data, format, err := loadConfig(path)
+	if err == nil {
+		err = c.LoadConfigDataFormat(data, format)
+	}
+	// Tables are loaded in random order, report the problems ordered by line.
+	sort.SliceStable(c.Problems[problems:], func(i, j int) bool {
+		return c.Problems[problems+i].Line < c.Problems[problems+j].Line
+	})
+	if err != nil && c.Validating {
+		c.addProblem("", nil, err)
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("Error loading config file %s: %w", path, err)
 	}
 	return nil
@@ -756,7 +883,13 @@ func (c *Config) LoadConfig(path string) error {
 
 // LoadConfigData loads TOML-formatted config data
 func (c *Config) LoadConfigData(data []byte) error {
-	tbl, err := parseConfig(data)
+	return c.LoadConfigDataFormat(data, FormatTOML)
+}
+
+// LoadConfigDataFormat loads config data in the given format, one of
+// FormatTOML, FormatYAML or FormatJSON.
+func (c *Config) LoadConfigDataFormat(data []byte, format string) error {
+	tbl, err := parseFormat(data, format)
 	if err != nil {
 		return fmt.Errorf("Error parsing data: %s", err)
 	}
@@ -780,7 +913,10 @@ func (c *Config) LoadConfigData(data []byte) error {
 		if !ok {
 			return fmt.Errorf("invalid configuration, error parsing agent table")
 		}
-		if err = c.toml.UnmarshalTable(subTable, c.Agent); err != nil {
+		err = c.toml.UnmarshalTable(subTable, c.Agent)
+		c.checkDurations(subTable, c.Agent)
+		err = c.check("agent", subTable, err)
+		if err != nil {
 			return fmt.Errorf("error parsing [agent]: %w", err)
 		}
 	}
@@ -802,26 +938,72 @@ func (c *Config) LoadConfigData(data []byte) error {
 		return fmt.Errorf("line %d: configuration specified the fields %q, but they weren't used", tbl.Line, keys(c.UnusedFields))
 	}
 
+	// Parse templates before the includes instantiating them:
+	if val, ok := tbl.Fields["templates"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing templates table")
+		}
+		if err = c.addTemplates(subTable); err != nil {
+			return err
+		}
+	}
+
+	// Parse secret stores before the plugins referencing them:
+	if val, ok := tbl.Fields["secretstores"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing secretstores table")
+		}
+		for pluginName, pluginVal := range subTable.Fields {
+			switch pluginSubTable := pluginVal.(type) {
+			case []*ast.Table:
+				for _, t := range pluginSubTable {
+					err = c.check("secretstores."+pluginName, t, c.addSecretStore(pluginName, t))
+					if err != nil {
+						return fmt.Errorf("error parsing %s, %w", pluginName, err)
+					}
+				}
+			default:
+				return fmt.Errorf("Unsupported config format: %s",
+					pluginName)
+			}
+			if len(c.UnusedFields) > 0 {
+				return fmt.Errorf("plugin secretstores.%s: line %d: configuration specified the fields %q, but they weren't used", pluginName, subTable.Line, keys(c.UnusedFields))
+			}
+		}
+	}
+
+	if c.SecretStoresOnly {
+		// Included configurations might define secret stores as well
+		return c.loadIncludes(tbl)
+	}
+
 	// Parse all the rest of the plugins:
 	for name, val := range tbl.Fields {
+		if name == "include" {
+			continue
+		}
 		subTable, ok := val.(*ast.Table)
 		if !ok {
 			return fmt.Errorf("invalid configuration, error parsing field %q as table", name)
 		}
 
 		switch name {
-		case "agent", "global_tags", "tags":
+		case "agent", "global_tags", "tags", "secretstores", "templates":
 		case "outputs":
 			for pluginName, pluginVal := range subTable.Fields {
 				switch pluginSubTable := pluginVal.(type) {
 				// legacy [outputs.influxdb] support
 				case *ast.Table:
-					if err = c.addOutput(pluginName, pluginSubTable); err != nil {
+					err = c.check(name+"."+pluginName, pluginSubTable, c.addOutput(pluginName, pluginSubTable))
+					if err != nil {
 						return fmt.Errorf("error parsing %s, %w", pluginName, err)
 					}
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addOutput(pluginName, t); err != nil {
+						err = c.check(name+"."+pluginName, t, c.addOutput(pluginName, t))
+						if err != nil {
 							return fmt.Errorf("error parsing %s array, %w", pluginName, err)
 						}
 					}
@@ -838,12 +1020,14 @@ func (c *Config) LoadConfigData(data []byte) error {
 				switch pluginSubTable := pluginVal.(type) {
 				// legacy [inputs.cpu] support
 				case *ast.Table:
-					if err = c.addInput(pluginName, pluginSubTable); err != nil {
+					err = c.check("inputs."+pluginName, pluginSubTable, c.addInput(pluginName, pluginSubTable))
+					if err != nil {
 						return fmt.Errorf("error parsing %s, %w", pluginName, err)
 					}
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addInput(pluginName, t); err != nil {
+						err = c.check("inputs."+pluginName, t, c.addInput(pluginName, t))
+						if err != nil {
 							return fmt.Errorf("error parsing %s, %w", pluginName, err)
 						}
 					}
@@ -860,7 +1044,8 @@ func (c *Config) LoadConfigData(data []byte) error {
 				switch pluginSubTable := pluginVal.(type) {
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addProcessor(pluginName, t); err != nil {
+						err = c.check(name+"."+pluginName, t, c.addProcessor(pluginName, t))
+						if err != nil {
 							return fmt.Errorf("error parsing %s, %w", pluginName, err)
 						}
 					}
@@ -877,7 +1062,8 @@ func (c *Config) LoadConfigData(data []byte) error {
 				switch pluginSubTable := pluginVal.(type) {
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addAggregator(pluginName, t); err != nil {
+						err = c.check(name+"."+pluginName, t, c.addAggregator(pluginName, t))
+						if err != nil {
 							return fmt.Errorf("Error parsing %s, %s", pluginName, err)
 						}
 					}
@@ -889,15 +1075,39 @@ func (c *Config) LoadConfigData(data []byte) error {
 					return fmt.Errorf("plugin %s.%s: line %d: configuration specified the fields %q, but they weren't used", name, pluginName, subTable.Line, keys(c.UnusedFields))
 				}
 			}
+		case "pipelines":
+			for pipelineName, pipelineVal := range subTable.Fields {
+				switch pipelineSubTable := pipelineVal.(type) {
+				case *ast.Table:
+					if err = c.addPipeline(pipelineName, pipelineSubTable); err != nil {
+						return err
+					}
+				case []*ast.Table:
+					for _, t := range pipelineSubTable {
+						if err = c.addPipeline(pipelineName, t); err != nil {
+							return err
+						}
+					}
+				default:
+					return fmt.Errorf("unsupported config format: pipelines.%s", pipelineName)
+				}
+			}
 		// Assume it's an input input for legacy config file support if no other
 		// identifiers are present
 		default:
-			if err = c.addInput(name, subTable); err != nil {
+			err = c.check("inputs."+name, subTable, c.addInput(name, subTable))
+			if err != nil {
 				return fmt.Errorf("Error parsing %s, %s", name, err)
 			}
 		}
 	}
 
+	// Load the includes after the plugins of this configuration, so included
+	// plugins are added after them as if loaded from a later file:
+	if err = c.loadIncludes(tbl); err != nil {
+		return err
+	}
+
 	if len(c.Processors) > 1 {
 		sort.Sort(c.Processors)
 	}
@@ -917,42 +1127,54 @@ func escapeEnv(value string) string {
 	return envVarEscaper.Replace(value)
 }
 
-func loadConfig(config string) ([]byte, error) {
+// loadConfig returns the configuration data of the file or URL and its
+// format.  The format is selected by the content type of remote
+// configurations, falling back to the extension.
+func loadConfig(config string) ([]byte, string, error) {
 	if fetchURLRe.MatchString(config) {
 		u, err := url.Parse(config)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		switch u.Scheme {
 		case "https", "http":
-			return fetchConfig(u)
+			data, contentType, err := fetchConfig(u)
+			if err != nil {
+				return nil, "", err
+			}
+			format := formatFromContentType(contentType)
+			if format == "" {
+				format = formatFromPath(config)
+			}
+			return data, format, nil
 		default:
-			return nil, fmt.Errorf("scheme %q not supported", u.Scheme)
+			return nil, "", fmt.Errorf("scheme %q not supported", u.Scheme)
 		}
 	}
 
 	// If it isn't a https scheme, try it as a file
-	return os.ReadFile(config)
+	data, err := os.ReadFile(config)
+	return data, formatFromPath(config), err
 }
 
-func fetchConfig(u *url.URL) ([]byte, error) {
+func fetchConfig(u *url.URL) ([]byte, string, error) {
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if v, exists := os.LookupEnv("INFLUX_TOKEN"); exists {
 		req.Header.Add("Authorization", "Token "+v)
 	}
-	req.Header.Add("Accept", "application/toml")
+	req.Header.Add("Accept", "application/toml, application/yaml;q=0.9, application/json;q=0.8")
 	req.Header.Set("User-Agent", internal.ProductToken())
 
 	retries := 3
 	for i := 0; i <= retries; i++ {
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			return nil, fmt.Errorf("Retry %d of %d failed connecting to HTTP config server %s", i, retries, err)
+			return nil, "", fmt.Errorf("Retry %d of %d failed connecting to HTTP config server %s", i, retries, err)
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -961,21 +1183,26 @@ func fetchConfig(u *url.URL) ([]byte, error) {
 				time.Sleep(httpLoadConfigRetryInterval)
 				continue
 			}
-			return nil, fmt.Errorf("Retry %d of %d failed to retrieve remote config: %s", i, retries, resp.Status)
+			return nil, "", fmt.Errorf("Retry %d of %d failed to retrieve remote config: %s", i, retries, resp.Status)
 		}
 		defer resp.Body.Close()
-		return io.ReadAll(resp.Body)
+		data, err := io.ReadAll(resp.Body)
+		return data, resp.Header.Get("Content-Type"), err
 	}
 
-	return nil, nil
+	return nil, "", nil
 }
 
 // parseConfig loads a TOML configuration from a provided path and
 // returns the AST produced from the TOML parser. When loading the file, it
 // will find environment variables and replace them.
 func parseConfig(contents []byte) (*ast.Table, error) {
-	contents = trimBOM(contents)
+	return toml.Parse(replaceEnvVars(trimBOM(contents)))
+}
 
+// replaceEnvVars replaces the references to environment variables in the
+// configuration by their values, escaped for inserting into a string.
+func replaceEnvVars(contents []byte) []byte {
 	parameters := envVarRe.FindAllSubmatch(contents, -1)
 	for _, parameter := range parameters {
 		if len(parameter) != 3 {
@@ -997,55 +1224,148 @@ func parseConfig(contents []byte) (*ast.Table, error) {
 			contents = bytes.Replace(contents, parameter[0], []byte(envVal), 1)
 		}
 	}
+	return contents
+}
+
+// pluginID returns an identifier derived from the plugin name and its
+// configuration table.  Plugins with identical configurations share the same
+// identifier, which allows detecting changed plugins when reloading.
+func pluginID(name string, tbl *ast.Table) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	writeTable(&sb, tbl)
+
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeTable writes a canonical representation of the table, independent of
+// the order of keys, whitespace and comments.
+func writeTable(sb *strings.Builder, tbl *ast.Table) {
+	keys := make([]string, 0, len(tbl.Fields))
+	for k := range tbl.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sb.WriteString("{")
+	for _, k := range keys {
+		sb.WriteString(strconv.Quote(k) + "=")
+		writeValue(sb, tbl.Fields[k])
+		sb.WriteString(";")
+	}
+	sb.WriteString("}")
+}
 
-	return toml.Parse(contents)
+func writeValue(sb *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case *ast.KeyValue:
+		writeValue(sb, v.Value)
+	case *ast.Table:
+		writeTable(sb, v)
+	case []*ast.Table:
+		sb.WriteString("[")
+		for _, t := range v {
+			writeTable(sb, t)
+		}
+		sb.WriteString("]")
+	case *ast.Array:
+		sb.WriteString("[")
+		for _, e := range v.Value {
+			writeValue(sb, e)
+			sb.WriteString(",")
+		}
+		sb.WriteString("]")
+	case ast.Value:
+		sb.WriteString(v.Source())
+	}
 }
 
 func (c *Config) addAggregator(name string, table *ast.Table) error {
+	ra, err := c.loadAggregator(name, table)
+	if err != nil {
+		return err
+	}
+	c.Aggregators = append(c.Aggregators, ra)
+	return nil
+}
+
+// loadAggregator creates the aggregator defined in the table.
+func (c *Config) loadAggregator(name string, table *ast.Table) (*models.RunningAggregator, error) {
 	creator, ok := aggregators.Aggregators[name]
 	if !ok {
-		return fmt.Errorf("Undefined but requested aggregator: %s", name)
+		return nil, fmt.Errorf("Undefined but requested aggregator: %s", name)
 	}
 	aggregator := creator()
 
 	conf, err := c.buildAggregator(name, table)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	conf.ID = pluginID("aggregators."+name, table)
+	c.tables[conf.ID] = table
+	c.addLocation(aggregator, table)
 
 	if err := c.toml.UnmarshalTable(table, aggregator); err != nil {
-		return err
+		return nil, err
 	}
+	c.checkDurations(table, aggregator)
+	c.checkDataFormat("aggregators."+name, table, false)
+	c.deferSecrets("aggregators."+name, table, aggregator)
 
-	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf))
-	return nil
+	return models.NewRunningAggregator(aggregator, conf), nil
 }
 
 func (c *Config) addProcessor(name string, table *ast.Table) error {
+	rf, aggRf, err := c.loadProcessor(name, table)
+	if err != nil {
+		return err
+	}
+	if rf != nil {
+		c.Processors = append(c.Processors, rf)
+	}
+	if aggRf != nil {
+		c.AggProcessors = append(c.AggProcessors, aggRf)
+	}
+	return nil
+}
+
+// loadProcessor creates the processor defined in the table twice, once for
+// processing the metrics of the inputs and once for the metrics of the
+// aggregators.  The processor for a stage is nil if the processor is
+// restricted to the other aggregation stage.
+func (c *Config) loadProcessor(name string, table *ast.Table) (*models.RunningProcessor, *models.RunningProcessor, error) {
 	creator, ok := processors.Processors[name]
 	if !ok {
-		return fmt.Errorf("Undefined but requested processor: %s", name)
+		return nil, nil, fmt.Errorf("Undefined but requested processor: %s", name)
 	}
 
 	processorConfig, err := c.buildProcessor(name, table)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	processorConfig.ID = pluginID("processors."+name, table)
+	c.tables[processorConfig.ID] = table
 
-	rf, err := c.newRunningProcessor(creator, processorConfig, table)
-	if err != nil {
-		return err
+	c.checkDataFormat("processors."+name, table, false)
+
+	var rf, aggRf *models.RunningProcessor
+	if processorConfig.AggregationStage != models.AggregationStageAfter {
+		rf, err = c.newRunningProcessor(creator, processorConfig, table)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
-	c.Processors = append(c.Processors, rf)
 
 	// save a copy for the aggregator
-	rf, err = c.newRunningProcessor(creator, processorConfig, table)
-	if err != nil {
-		return err
+	if processorConfig.AggregationStage != models.AggregationStageBefore {
+		aggRf, err = c.newRunningProcessor(creator, processorConfig, table)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
-	c.AggProcessors = append(c.AggProcessors, rf)
 
-	return nil
+	return rf, aggRf, nil
 }
 
 func (c *Config) newRunningProcessor(
@@ -1059,49 +1379,82 @@ func (c *Config) newRunningProcessor(
 		if err := c.toml.UnmarshalTable(table, p.Unwrap()); err != nil {
 			return nil, err
 		}
+		c.checkDurations(table, p.Unwrap())
+		c.deferSecrets("processors."+processorConfig.Name, table, p.Unwrap())
 	} else {
 		if err := c.toml.UnmarshalTable(table, processor); err != nil {
 			return nil, err
 		}
+		c.checkDurations(table, processor)
+		c.deferSecrets("processors."+processorConfig.Name, table, processor)
 	}
 
+	c.addLocation(processor, table)
 	rf := models.NewRunningProcessor(processor, processorConfig)
 	return rf, nil
 }
 
 func (c *Config) addOutput(name string, table *ast.Table) error {
+	ro, err := c.loadOutput(name, table)
+	if err != nil || ro == nil {
+		return err
+	}
+	c.Outputs = append(c.Outputs, ro)
+	return nil
+}
+
+// loadOutput creates the output defined in the table, or returns nil if the
+// output is excluded by the output filters.
+func (c *Config) loadOutput(name string, table *ast.Table) (*models.RunningOutput, error) {
 	if len(c.OutputFilters) > 0 && !sliceContains(name, c.OutputFilters) {
-		return nil
+		return nil, nil
 	}
 	creator, ok := outputs.Outputs[name]
 	if !ok {
-		return fmt.Errorf("Undefined but requested output: %s", name)
+		return nil, fmt.Errorf("Undefined but requested output: %s", name)
 	}
 	output := creator()
 
 	// If the output has a SetSerializer function, then this means it can write
 	// arbitrary types of output, so build the serializer and set it.
+	var serializer serializers.Serializer
 	switch t := output.(type) {
 	case serializers.SerializerOutput:
-		serializer, err := c.buildSerializer(table)
+		var err error
+		serializer, err = c.buildSerializer(table)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		t.SetSerializer(serializer)
 	}
 
 	outputConfig, err := c.buildOutput(name, table)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	outputConfig.ID = pluginID("outputs."+name, table)
+	c.tables[outputConfig.ID] = table
+	c.addLocation(output, table)
 
 	if err := c.toml.UnmarshalTable(table, output); err != nil {
-		return err
+		return nil, err
 	}
+	c.checkDurations(table, output)
+	_, supported := output.(serializers.SerializerOutput)
+	c.checkDataFormat("outputs."+name, table, supported)
+	c.deferSecrets("outputs."+name, table, output)
 
 	ro := models.NewRunningOutput(output, outputConfig, c.Agent.MetricBatchSize, c.Agent.MetricBufferLimit)
-	c.Outputs = append(c.Outputs, ro)
-	return nil
+	ro.Serializer = serializer
+	if serializer != nil {
+		// Use another instance to check the metrics before writing them, as
+		// serializing may change the state of the serializer, e.g. csv headers.
+		ro.CheckSerializer, err = c.buildSerializer(table)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ro, nil
 }
 
 func (c *Config) addInput(name string, table *ast.Table) error {
@@ -1137,16 +1490,31 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 		t.SetParserFunc(func() (parsers.Parser, error) {
 			return parsers.NewParser(config)
 		})
+
+		// The parser is only created when running, so check it now.
+		if c.Validating {
+			if _, err := parsers.NewParser(config); err != nil {
+				return err
+			}
+		}
 	}
 
 	pluginConfig, err := c.buildInput(name, table)
 	if err != nil {
 		return err
 	}
+	pluginConfig.ID = pluginID("inputs."+name, table)
+	c.tables[pluginConfig.ID] = table
+	c.addLocation(input, table)
 
 	if err := c.toml.UnmarshalTable(table, input); err != nil {
 		return err
 	}
+	c.checkDurations(table, input)
+	_, parserInput := input.(parsers.ParserInput)
+	_, parserFuncInput := input.(parsers.ParserFuncInput)
+	c.checkDataFormat("inputs."+name, table, parserInput || parserFuncInput)
+	c.deferSecrets("inputs."+name, table, input)
 
 	rp := models.NewRunningInput(input, pluginConfig)
 	rp.SetDefaultTags(c.Tags)
@@ -1173,6 +1541,7 @@ func (c *Config) buildAggregator(name string, tbl *ast.Table) (*models.Aggregato
 	c.getFieldString(tbl, "name_suffix", &conf.MeasurementSuffix)
 	c.getFieldString(tbl, "name_override", &conf.NameOverride)
 	c.getFieldString(tbl, "alias", &conf.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &conf.LogLevel)
 
 	conf.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1203,6 +1572,15 @@ func (c *Config) buildProcessor(name string, tbl *ast.Table) (*models.ProcessorC
 
 	c.getFieldInt64(tbl, "order", &conf.Order)
 	c.getFieldString(tbl, "alias", &conf.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &conf.LogLevel)
+	c.getFieldString(tbl, "aggregation_stage", &conf.AggregationStage)
+	c.getFieldString(tbl, "branch", &conf.Branch)
+	switch conf.AggregationStage {
+	case "", models.AggregationStageBefore, models.AggregationStageAfter:
+	default:
+		c.addError(tbl, fmt.Errorf("invalid aggregation_stage %q, expected %q or %q",
+			conf.AggregationStage, models.AggregationStageBefore, models.AggregationStageAfter))
+	}
 
 	if c.hasErrs() {
 		return nil, c.firstErr()
@@ -1238,6 +1616,8 @@ func (c *Config) buildFilter(tbl *ast.Table) (models.Filter, error) {
 	c.getFieldStringSlice(tbl, "tagexclude", &f.TagExclude)
 	c.getFieldStringSlice(tbl, "taginclude", &f.TagInclude)
 
+	c.getFieldString(tbl, "metricpass", &f.MetricPass)
+
 	if c.hasErrs() {
 		return f, c.firstErr()
 	}
@@ -1261,6 +1641,18 @@ func (c *Config) buildInput(name string, tbl *ast.Table) (*models.InputConfig, e
 	c.getFieldString(tbl, "name_suffix", &cp.MeasurementSuffix)
 	c.getFieldString(tbl, "name_override", &cp.NameOverride)
 	c.getFieldString(tbl, "alias", &cp.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &cp.LogLevel)
+	c.getFieldDuration(tbl, "gather_timeout", &cp.GatherTimeout)
+	c.getFieldString(tbl, "schedule", &cp.Schedule)
+	c.getFieldString(tbl, "schedule_timezone", &cp.ScheduleTimezone)
+	c.getFieldString(tbl, "pipeline", &cp.Pipeline)
+	if cp.Schedule != "" {
+		if _, err := models.ParseSchedule(cp.Schedule, cp.ScheduleTimezone); err != nil {
+			c.addError(tbl, err)
+		}
+	} else if cp.ScheduleTimezone != "" {
+		c.addError(tbl, errors.New("schedule_timezone requires a schedule"))
+	}
 
 	cp.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1368,6 +1760,42 @@ func (c *Config) getParserConfig(name string, tbl *ast.Table) (*parsers.Config,
 	c.getFieldBool(tbl, "csv_trim_space", &pc.CSVTrimSpace)
 	c.getFieldStringSlice(tbl, "csv_skip_values", &pc.CSVSkipValues)
 
+	//for avro parser
+	c.getFieldString(tbl, "avro_schema_registry", &pc.AvroSchemaRegistry)
+	c.getFieldString(tbl, "avro_schema_file", &pc.AvroSchemaFile)
+	c.getFieldString(tbl, "avro_measurement", &pc.AvroMeasurement)
+	c.getFieldString(tbl, "avro_measurement_field", &pc.AvroMeasurementField)
+	c.getFieldStringSlice(tbl, "avro_tags", &pc.AvroTags)
+	c.getFieldStringSlice(tbl, "avro_fields", &pc.AvroFields)
+	c.getFieldString(tbl, "avro_field_separator", &pc.AvroFieldSeparator)
+	c.getFieldString(tbl, "avro_timestamp", &pc.AvroTimestamp)
+	c.getFieldString(tbl, "avro_timestamp_format", &pc.AvroTimestampFormat)
+
+	//for binary parser
+	if pc.DataFormat == "binary" {
+		c.getFieldString(tbl, "binary_endianness", &pc.BinaryEndianness)
+		if node, ok := tbl.Fields["binary"]; ok {
+			if subtbls, ok := node.([]*ast.Table); ok {
+				pc.BinaryConfig = make([]binary.Config, 0, len(subtbls))
+				for _, subtbl := range subtbls {
+					pc.BinaryConfig = append(pc.BinaryConfig, getBinaryConfig(c, subtbl))
+				}
+			}
+		}
+	}
+
+	//for protobuf parser
+	c.getFieldStringSlice(tbl, "protobuf_files", &pc.ProtobufFiles)
+	c.getFieldStringSlice(tbl, "protobuf_import_paths", &pc.ProtobufImportPaths)
+	c.getFieldString(tbl, "protobuf_message_type", &pc.ProtobufMessageType)
+	c.getFieldBool(tbl, "protobuf_length_delimited", &pc.ProtobufLengthDelimited)
+	c.getFieldString(tbl, "protobuf_measurement_field", &pc.ProtobufMeasurementField)
+	c.getFieldStringSlice(tbl, "protobuf_tags", &pc.ProtobufTags)
+	c.getFieldStringSlice(tbl, "protobuf_fields", &pc.ProtobufFields)
+	c.getFieldString(tbl, "protobuf_field_separator", &pc.ProtobufFieldSeparator)
+	c.getFieldString(tbl, "protobuf_timestamp", &pc.ProtobufTimestamp)
+	c.getFieldString(tbl, "protobuf_timestamp_format", &pc.ProtobufTimestampFormat)
+
 	c.getFieldStringSlice(tbl, "form_urlencoded_tag_keys", &pc.FormUrlencodedTagKeys)
 
 	c.getFieldString(tbl, "value_field_name", &pc.ValueFieldName)
@@ -1497,6 +1925,65 @@ func getTagSubtable(c *Config, metricConfig *ast.Table) []json_v2.DataSet {
 	return tags
 }
 
+func getBinaryConfig(c *Config, tbl *ast.Table) binary.Config {
+	var cfg binary.Config
+	c.checkSubtableFields("binary", tbl, "metric_name", "timestamp_format", "filter", "entry")
+	c.getFieldString(tbl, "metric_name", &cfg.MetricName)
+	c.getFieldString(tbl, "timestamp_format", &cfg.TimestampFormat)
+
+	if node, ok := tbl.Fields["filter"]; ok {
+		if filterTbl, ok := node.(*ast.Table); ok {
+			cfg.Filter = &binary.Filter{}
+			c.checkSubtableFields("binary.filter", filterTbl, "length", "selection")
+			c.getFieldInt(filterTbl, "length", &cfg.Filter.Length)
+			if node, ok := filterTbl.Fields["selection"]; ok {
+				if selectionTbls, ok := node.([]*ast.Table); ok {
+					for _, selectionTbl := range selectionTbls {
+						var s binary.Selection
+						c.checkSubtableFields("binary.filter.selection", selectionTbl, "offset", "match")
+						c.getFieldInt(selectionTbl, "offset", &s.Offset)
+						c.getFieldString(selectionTbl, "match", &s.Match)
+						cfg.Filter.Selections = append(cfg.Filter.Selections, s)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["entry"]; ok {
+		if entryTbls, ok := node.([]*ast.Table); ok {
+			for _, entryTbl := range entryTbls {
+				var e binary.Entry
+				c.checkSubtableFields("binary.entry", entryTbl, "name", "type", "offset", "bits", "length", "assignment", "omit")
+				c.getFieldString(entryTbl, "name", &e.Name)
+				c.getFieldString(entryTbl, "type", &e.Type)
+				if _, ok := entryTbl.Fields["offset"]; ok {
+					var offset int
+					c.getFieldInt(entryTbl, "offset", &offset)
+					e.Offset = &offset
+				}
+				c.getFieldInt(entryTbl, "bits", &e.Bits)
+				c.getFieldInt(entryTbl, "length", &e.Length)
+				c.getFieldString(entryTbl, "assignment", &e.Assignment)
+				c.getFieldBool(entryTbl, "omit", &e.Omit)
+				cfg.Entries = append(cfg.Entries, e)
+			}
+		}
+	}
+
+	return cfg
+}
+
+// checkSubtableFields marks the fields of a sub-table read by hand that are
+// not among the known ones as unused, so misspelled options are reported.
+func (c *Config) checkSubtableFields(prefix string, tbl *ast.Table, known ...string) {
+	for key := range tbl.Fields {
+		if !sliceContains(key, known) {
+			c.UnusedFields[prefix+"."+key] = true
+		}
+	}
+}
+
 // buildSerializer grabs the necessary entries from the ast.Table for creating
 // a serializers.Serializer object, and creates it, which can then be added onto
 // an Output object.
@@ -1511,9 +1998,14 @@ func (c *Config) buildSerializer(tbl *ast.Table) (serializers.Serializer, error)
 
 	c.getFieldString(tbl, "prefix", &sc.Prefix)
 	c.getFieldString(tbl, "template", &sc.Template)
+	c.getFieldString(tbl, "batch_template", &sc.BatchTemplate)
 	c.getFieldStringSlice(tbl, "templates", &sc.Templates)
 	c.getFieldString(tbl, "carbon2_format", &sc.Carbon2Format)
 	c.getFieldString(tbl, "carbon2_sanitize_replace_char", &sc.Carbon2SanitizeReplaceChar)
+	c.getFieldStringSlice(tbl, "csv_columns", &sc.CSVColumns)
+	c.getFieldBool(tbl, "csv_header", &sc.CSVHeader)
+	c.getFieldString(tbl, "csv_separator", &sc.CSVSeparator)
+	c.getFieldString(tbl, "csv_timestamp_format", &sc.CSVTimestampFormat)
 	c.getFieldInt(tbl, "influx_max_line_bytes", &sc.InfluxMaxLineBytes)
 
 	c.getFieldBool(tbl, "influx_sort_fields", &sc.InfluxSortFields)
@@ -1532,6 +2024,15 @@ func (c *Config) buildSerializer(tbl *ast.Table) (serializers.Serializer, error)
 	c.getFieldStringSlice(tbl, "wavefront_source_override", &sc.WavefrontSourceOverride)
 	c.getFieldBool(tbl, "wavefront_use_strict", &sc.WavefrontUseStrict)
 
+	c.getFieldStringSlice(tbl, "protobuf_files", &sc.ProtobufFiles)
+	c.getFieldStringSlice(tbl, "protobuf_import_paths", &sc.ProtobufImportPaths)
+	c.getFieldString(tbl, "protobuf_message_type", &sc.ProtobufMessageType)
+	c.getFieldBool(tbl, "protobuf_length_delimited", &sc.ProtobufLengthDelimited)
+	c.getFieldString(tbl, "protobuf_measurement_field", &sc.ProtobufMeasurementField)
+	c.getFieldString(tbl, "protobuf_timestamp", &sc.ProtobufTimestamp)
+	c.getFieldString(tbl, "protobuf_timestamp_format", &sc.ProtobufTimestampFormat)
+	c.getFieldString(tbl, "protobuf_field_separator", &sc.ProtobufFieldSeparator)
+
 	c.getFieldBool(tbl, "prometheus_export_timestamp", &sc.PrometheusExportTimestamp)
 	c.getFieldBool(tbl, "prometheus_sort_metrics", &sc.PrometheusSortMetrics)
 	c.getFieldBool(tbl, "prometheus_string_as_label", &sc.PrometheusStringAsLabel)
@@ -1564,7 +2065,17 @@ func (c *Config) buildOutput(name string, tbl *ast.Table) (*models.OutputConfig,
 
 	c.getFieldInt(tbl, "metric_buffer_limit", &oc.MetricBufferLimit)
 	c.getFieldInt(tbl, "metric_batch_size", &oc.MetricBatchSize)
+	c.getFieldString(tbl, "buffer_strategy", &oc.BufferStrategy)
+	c.getFieldString(tbl, "buffer_directory", &oc.BufferDirectory)
+	c.getFieldInt(tbl, "max_metrics_per_second", &oc.MaxMetricsPerSecond)
+	c.getFieldSize(tbl, "max_bytes_per_second", &oc.MaxBytesPerSecond)
+	c.getFieldDuration(tbl, "retry_initial_delay", &oc.RetryInitialDelay)
+	c.getFieldDuration(tbl, "retry_max_delay", &oc.RetryMaxDelay)
+	c.getFieldDuration(tbl, "retry_jitter", &oc.RetryJitter)
+	c.getFieldInt(tbl, "retry_max_attempts", &oc.RetryMaxAttempts)
+	c.getFieldString(tbl, "dead_letter", &oc.DeadLetter)
 	c.getFieldString(tbl, "alias", &oc.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &oc.LogLevel)
 	c.getFieldString(tbl, "name_override", &oc.NameOverride)
 	c.getFieldString(tbl, "name_suffix", &oc.NameSuffix)
 	c.getFieldString(tbl, "name_prefix", &oc.NamePrefix)
@@ -1573,27 +2084,52 @@ func (c *Config) buildOutput(name string, tbl *ast.Table) (*models.OutputConfig,
 		return nil, c.firstErr()
 	}
 
+	if oc.BufferStrategy == "" {
+		oc.BufferStrategy = c.Agent.BufferStrategy
+	}
+	if oc.BufferDirectory == "" {
+		oc.BufferDirectory = c.Agent.BufferDirectory
+	}
+
+	// Outputs using a disk buffer must not share the same directory
+	if oc.BufferStrategy == "disk" {
+		for _, ro := range c.AllOutputs() {
+			if ro.Config.BufferStrategy == "disk" && ro.Config.BufferDirectory == oc.BufferDirectory &&
+				ro.Config.Name == oc.Name && ro.Config.Alias == oc.Alias {
+				return nil, fmt.Errorf("outputs.%s: a unique alias is required for multiple outputs using the disk buffer", name)
+			}
+		}
+	}
+
 	return oc, nil
 }
 
 func (c *Config) missingTomlField(_ reflect.Type, key string) error {
 	switch key {
-	case "alias", "carbon2_format", "carbon2_sanitize_replace_char", "collectd_auth_file",
-		"collectd_parse_multivalue", "collectd_security_level", "collectd_typesdb", "collection_jitter",
-		"csv_column_names", "csv_column_types", "csv_comment", "csv_delimiter", "csv_header_row_count",
-		"csv_measurement_column", "csv_skip_columns", "csv_skip_rows", "csv_tag_columns",
+	case "aggregation_stage", "alias", "avro_field_separator", "avro_fields", "avro_measurement",
+		"avro_measurement_field", "avro_schema_file", "avro_schema_registry", "avro_tags",
+		"avro_timestamp", "avro_timestamp_format", "batch_template", "binary", "binary_endianness", "branch", "buffer_directory", "buffer_strategy",
+		"carbon2_format", "carbon2_sanitize_replace_char",
+		"collectd_auth_file", "collectd_parse_multivalue", "collectd_security_level", "collectd_typesdb",
+		"collection_jitter",
+		"csv_column_names", "csv_column_types", "csv_columns", "csv_comment", "csv_delimiter", "csv_header",
+		"csv_header_row_count", "csv_measurement_column", "csv_separator", "csv_skip_columns", "csv_skip_rows", "csv_tag_columns",
 		"csv_timestamp_column", "csv_timestamp_format", "csv_timezone", "csv_trim_space", "csv_skip_values",
-		"data_format", "data_type", "delay", "drop", "drop_original", "dropwizard_metric_registry_path",
+		"data_format", "data_type", "dead_letter", "delay", "drop", "drop_original", "dropwizard_metric_registry_path",
 		"dropwizard_tag_paths", "dropwizard_tags_path", "dropwizard_time_format", "dropwizard_time_path",
-		"fielddrop", "fieldpass", "flush_interval", "flush_jitter", "form_urlencoded_tag_keys",
+		"fielddrop", "fieldpass", "flush_interval", "flush_jitter", "form_urlencoded_tag_keys", "gather_timeout",
 		"grace", "graphite_separator", "graphite_tag_sanitize_mode", "graphite_tag_support",
 		"grok_custom_pattern_files", "grok_custom_patterns", "grok_named_patterns", "grok_patterns",
 		"grok_timezone", "grok_unique_timestamp", "influx_max_line_bytes", "influx_sort_fields",
 		"influx_uint_support", "interval", "json_name_key", "json_query", "json_strict",
 		"json_string_fields", "json_time_format", "json_time_key", "json_timestamp_format", "json_timestamp_units", "json_timezone", "json_v2",
-		"lvm", "metric_batch_size", "metric_buffer_limit", "name_override", "name_prefix",
-		"name_suffix", "namedrop", "namepass", "order", "pass", "period", "precision",
+		"log_level", "lvm", "max_bytes_per_second", "max_metrics_per_second", "metric_batch_size", "metric_buffer_limit",
+		"metricpass", "name_override", "name_prefix",
+		"name_suffix", "namedrop", "namepass", "order", "pass", "period", "pipeline", "precision",
 		"prefix", "prometheus_export_timestamp", "prometheus_ignore_timestamp", "prometheus_sort_metrics", "prometheus_string_as_label",
+		"protobuf_field_separator", "protobuf_fields", "protobuf_files", "protobuf_import_paths", "protobuf_length_delimited",
+		"protobuf_measurement_field", "protobuf_message_type", "protobuf_tags", "protobuf_timestamp", "protobuf_timestamp_format",
+		"retry_initial_delay", "retry_jitter", "retry_max_attempts", "retry_max_delay", "schedule", "schedule_timezone",
 		"separator", "splunkmetric_hec_routing", "splunkmetric_multimetric", "tag_keys",
 		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "template", "templates",
 		"value_field_name", "wavefront_source_override", "wavefront_use_strict",
@@ -1601,6 +2137,9 @@ func (c *Config) missingTomlField(_ reflect.Type, key string) error {
 		"xpath_protobuf_file", "xpath_protobuf_type":
 
 		// ignore fields that are common to all plugins.
+		if c.Validating {
+			c.commonFields[key] = true
+		}
 	default:
 		c.UnusedFields[key] = true
 	}
@@ -1688,6 +2227,29 @@ func (c *Config) getFieldInt64(tbl *ast.Table, fieldName string, target *int64)
 	}
 }
 
+func (c *Config) getFieldSize(tbl *ast.Table, fieldName string, target *int64) {
+	if node, ok := tbl.Fields[fieldName]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			var size Size
+			if err := size.UnmarshalTOML([]byte(kv.Value.Source())); err != nil {
+				c.addError(tbl, fmt.Errorf("error parsing size: %w", err))
+				return
+			}
+			*target = int64(size)
+		}
+	}
+}
+
+func (c *Config) getFieldLogLevel(tbl *ast.Table, fieldName string, target *string) {
+	c.getFieldString(tbl, fieldName, target)
+	if *target == "" {
+		return
+	}
+	if _, err := models.ParseLogLevel(*target); err != nil {
+		c.addError(tbl, err)
+	}
+}
+
 func (c *Config) getFieldStringSlice(tbl *ast.Table, fieldName string, target *[]string) {
 	if node, ok := tbl.Fields[fieldName]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
@@ -54,9 +54,11 @@ func TestConfig_LoadSingleInputWithEnvVars(t *testing.T) {
 	}
 	inputConfig.Tags = make(map[string]string)
 
-	// Ignore Log and Parser
+	// Ignore Log, Parser and ID
 	c.Inputs[0].Input.(*MockupInputPlugin).Log = nil
 	c.Inputs[0].Input.(*MockupInputPlugin).parser = nil
+	require.NotEmpty(t, c.Inputs[0].Config.ID)
+	c.Inputs[0].Config.ID = ""
 	require.Equal(t, input, c.Inputs[0].Input, "Testdata did not produce a correct mockup struct.")
 	require.Equal(t, inputConfig, c.Inputs[0].Config, "Testdata did not produce correct input metadata.")
 }
@@ -94,9 +96,11 @@ func TestConfig_LoadSingleInput(t *testing.T) {
 	}
 	inputConfig.Tags = make(map[string]string)
 
-	// Ignore Log and Parser
+	// Ignore Log, Parser and ID
 	c.Inputs[0].Input.(*MockupInputPlugin).Log = nil
 	c.Inputs[0].Input.(*MockupInputPlugin).parser = nil
+	require.NotEmpty(t, c.Inputs[0].Config.ID)
+	c.Inputs[0].Config.ID = ""
 	require.Equal(t, input, c.Inputs[0].Input, "Testdata did not produce a correct memcached struct.")
 	require.Equal(t, inputConfig, c.Inputs[0].Config, "Testdata did not produce correct memcached metadata.")
 }
@@ -202,6 +206,10 @@ func TestConfig_LoadDirectory(t *testing.T) {
 			input.parser = nil
 		}
 
+		// Check the plugin ID and ignore it for comparison
+		require.NotEmpty(t, plugin.Config.ID)
+		plugin.Config.ID = ""
+
 		require.Equalf(t, expectedPlugins[i], plugin.Input, "Plugin %d: incorrect struct produced", i)
 		require.Equalf(t, expectedConfigs[i], plugin.Config, "Plugin %d: incorrect config produced", i)
 	}
@@ -288,6 +296,127 @@ func TestConfig_AzureMonitorNamespacePrefix(t *testing.T) {
 	}
 }
 
+func TestConfig_Formats(t *testing.T) {
+	expected := NewConfig()
+	require.NoError(t, expected.LoadConfig("./testdata/single_plugin.toml"))
+	require.Len(t, expected.Inputs, 1)
+
+	for _, file := range []string{"./testdata/single_plugin.yaml", "./testdata/single_plugin.json"} {
+		t.Run(file, func(t *testing.T) {
+			c := NewConfig()
+			require.NoError(t, c.LoadConfig(file))
+			require.Len(t, c.Inputs, 1)
+			require.Equal(t, expected.Inputs[0].Config, c.Inputs[0].Config)
+			require.Equal(t, []string{"localhost"}, c.Inputs[0].Input.(*MockupInputPlugin).Servers)
+		})
+	}
+}
+
+func TestConfig_FormatErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		err    string
+	}{
+		{
+			name:   "yaml syntax",
+			format: FormatYAML,
+			data:   "inputs:\n  memcached:\n  - servers: [\n",
+			err:    "yaml: line 3",
+		},
+		{
+			name:   "yaml unused field",
+			format: FormatYAML,
+			data:   "inputs:\n  memcached:\n    - servers: []\n      unknown: 1\n",
+			err:    `plugin inputs.memcached: line 1: configuration specified the fields ["unknown"], but they weren't used`,
+		},
+		{
+			name:   "yaml null",
+			format: FormatYAML,
+			data:   "inputs:\n  memcached:\n    - servers:\n",
+			err:    "line 3: null values are not supported",
+		},
+		{
+			name:   "json syntax",
+			format: FormatJSON,
+			data:   "inputs:\n  memcached: {}\n",
+			err:    "invalid JSON",
+		},
+		{
+			name:   "json wrong type",
+			format: FormatJSON,
+			data:   `{"inputs": {"memcached": [{"port": "11211"}]}}`,
+			err:    "error parsing memcached",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			err := c.LoadConfigDataFormat([]byte(tt.data), tt.format)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestConfig_YAMLEnvVars(t *testing.T) {
+	t.Setenv("MY_TEST_COMMAND", `it's "quoted" \ text`)
+	t.Setenv("MY_TEST_PORT", "11211")
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigDataFormat([]byte(`
+inputs:
+  memcached:
+    - servers: [$MY_TEST_COMMAND, '${MY_TEST_COMMAND}', "${MY_TEST_COMMAND}"]
+      command: plain ${MY_TEST_COMMAND}
+      port: ${MY_TEST_PORT}
+`), FormatYAML))
+	require.Len(t, c.Inputs, 1)
+	input := c.Inputs[0].Input.(*MockupInputPlugin)
+	value := `it's "quoted" \ text`
+	require.Equal(t, []string{value, value, value}, input.Servers)
+	require.Equal(t, "plain "+value, input.Command)
+	require.Equal(t, 11211, input.Port)
+}
+
+func TestConfig_LoadDirectoryFormats(t *testing.T) {
+	servers := func(c *Config) []string {
+		var servers []string
+		for _, input := range c.Inputs {
+			servers = append(servers, input.Input.(*MockupInputPlugin).Servers...)
+		}
+		return servers
+	}
+
+	// Only TOML files are loaded by default
+	c := NewConfig()
+	require.NoError(t, c.LoadDirectory("./testdata/subconfig_formats"))
+	require.Equal(t, []string{"toml"}, servers(c))
+
+	c = NewConfig()
+	c.DirectoryFormats = []string{FormatYAML, FormatJSON}
+	require.NoError(t, c.LoadDirectory("./testdata/subconfig_formats"))
+	require.ElementsMatch(t, []string{"toml", "yaml", "json"}, servers(c))
+
+	c = NewConfig()
+	c.DirectoryFormats = []string{"xml"}
+	require.EqualError(t, c.LoadDirectory("./testdata/subconfig_formats"), `unsupported configuration directory format "xml"`)
+}
+
+func TestConfig_URLContentType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte("inputs:\n  memcached:\n    - servers: [\"localhost\"]\n"))
+	}))
+	defer ts.Close()
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(ts.URL))
+	require.Len(t, c.Inputs, 1)
+	require.Equal(t, []string{"localhost"}, c.Inputs[0].Input.(*MockupInputPlugin).Servers)
+}
+
 func TestConfig_URLRetries3Fails(t *testing.T) {
 	httpLoadConfigRetryInterval = 0 * time.Second
 	responseCounter := 0
@@ -353,6 +482,476 @@ func TestConfig_URLLikeFileName(t *testing.T) {
 	}
 }
 
+func TestConfig_PluginID(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  port = 80
+  [inputs.memcached.tags]
+    region = "eu"
+
+[[inputs.memcached]]
+  # same configuration with different formatting
+  port    = 80
+  servers = [ "localhost" ]
+  [inputs.memcached.tags]
+    region = "eu"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  port = 80
+  [inputs.memcached.tags]
+    region = "us"
+
+[[outputs.http]]
+  url = "http://localhost"
+`)))
+	require.Len(t, c.Inputs, 3)
+	require.Len(t, c.Outputs, 1)
+
+	require.NotEmpty(t, c.Inputs[0].Config.ID)
+	require.Equal(t, c.Inputs[0].Config.ID, c.Inputs[1].Config.ID)
+	require.NotEqual(t, c.Inputs[0].Config.ID, c.Inputs[2].Config.ID)
+	require.NotEqual(t, c.Inputs[0].Config.ID, c.Outputs[0].Config.ID)
+}
+
+func TestConfig_OutputRateLimit(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  url = "http://localhost"
+  max_metrics_per_second = 5000
+  max_bytes_per_second = "1MiB"
+
+[[outputs.http]]
+  url = "http://localhost"
+  max_bytes_per_second = 2048
+`)))
+	require.Len(t, c.Outputs, 2)
+	require.Equal(t, 5000, c.Outputs[0].Config.MaxMetricsPerSecond)
+	require.Equal(t, int64(1024*1024), c.Outputs[0].Config.MaxBytesPerSecond)
+	require.Equal(t, 0, c.Outputs[1].Config.MaxMetricsPerSecond)
+	require.Equal(t, int64(2048), c.Outputs[1].Config.MaxBytesPerSecond)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[outputs.http]]
+  url = "http://localhost"
+  max_bytes_per_second = "1 lot"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error parsing size")
+}
+
+func TestConfig_OutputRetry(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  url = "http://localhost"
+  retry_initial_delay = "5s"
+  retry_max_delay = "10m"
+  retry_jitter = "1s"
+  retry_max_attempts = 10
+`)))
+	require.Len(t, c.Outputs, 1)
+	oc := c.Outputs[0].Config
+	require.Equal(t, 5*time.Second, oc.RetryInitialDelay)
+	require.Equal(t, 10*time.Minute, oc.RetryMaxDelay)
+	require.Equal(t, time.Second, oc.RetryJitter)
+	require.Equal(t, 10, oc.RetryMaxAttempts)
+}
+
+func TestConfig_LogLevel(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  log_level = "debug"
+
+[[processors.validate_init]]
+  log_level = "warn"
+
+[[outputs.http]]
+  url = "http://localhost"
+  log_level = "error"
+`)))
+	require.Equal(t, "debug", c.Inputs[0].Config.LogLevel)
+	require.Equal(t, "warn", c.Processors[0].Config.LogLevel)
+	require.Equal(t, "error", c.Outputs[0].Config.LogLevel)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  log_level = "trace"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid log level "trace"`)
+}
+
+func TestConfig_Schedule(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  schedule = "0 */6 * * *"
+  schedule_timezone = "Europe/Berlin"
+`)))
+	require.Equal(t, "0 */6 * * *", c.Inputs[0].Config.Schedule)
+	require.Equal(t, "Europe/Berlin", c.Inputs[0].Config.ScheduleTimezone)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  schedule = "0 */6 * *"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid schedule "0 */6 * *"`)
+
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  schedule = "@daily"
+  schedule_timezone = "Mars/Olympus_Mons"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid schedule timezone "Mars/Olympus_Mons"`)
+}
+
+func TestConfig_GatherTimeout(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  timeout = "1s"
+  gather_timeout = "30s"
+`)))
+	require.Equal(t, 30*time.Second, c.Inputs[0].Config.GatherTimeout)
+	require.Equal(t, Duration(time.Second), c.Inputs[0].Input.(*MockupInputPlugin).Timeout)
+}
+
+func TestConfig_MetricPass(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  metricpass = 'fields.value > 100 && tags.region == "eu"'
+`)))
+	require.Len(t, c.Inputs, 1)
+
+	filter := c.Inputs[0].Config.Filter
+	require.Equal(t, `fields.value > 100 && tags.region == "eu"`, filter.MetricPass)
+	require.True(t, filter.IsActive())
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  metricpass = "fields.value >"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error compiling 'metricpass'")
+}
+
+func TestConfig_ProcessorAggregationStage(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[processors.validate_init]]
+  alias = "both"
+
+[[processors.validate_init]]
+  alias = "before"
+  aggregation_stage = "before"
+  branch = "x"
+
+[[processors.validate_init]]
+  alias = "after"
+  aggregation_stage = "after"
+`)))
+	require.Len(t, c.Processors, 2)
+	require.Equal(t, "both", c.Processors[0].Config.Alias)
+	require.Equal(t, "before", c.Processors[1].Config.Alias)
+	require.Equal(t, "x", c.Processors[1].Config.Branch)
+	require.Len(t, c.AggProcessors, 2)
+	require.Equal(t, "both", c.AggProcessors[0].Config.Alias)
+	require.Equal(t, "after", c.AggProcessors[1].Config.Alias)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[processors.validate_init]]
+  aggregation_stage = "during"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid aggregation_stage "during", expected "before" or "after"`)
+}
+
+func TestConfig_Pipelines(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  servers = ["default"]
+
+[[inputs.memcached]]
+  servers = ["security"]
+  pipeline = "security"
+
+[[outputs.http]]
+  url = "http://localhost"
+
+[[pipelines.security]]
+  [[pipelines.security.outputs.http]]
+    url = "http://localhost"
+`)))
+	require.NoError(t, c.CheckPipelines())
+	require.Len(t, c.Inputs, 2)
+	require.Equal(t, "", c.Inputs[0].Config.Pipeline)
+	require.Equal(t, "security", c.Inputs[1].Config.Pipeline)
+	require.Len(t, c.Outputs, 1)
+	require.Len(t, c.AllOutputs(), 2)
+
+	p := c.Pipeline("security")
+	require.NotNil(t, p)
+	require.Len(t, p.Outputs, 1)
+	require.Equal(t, "http", p.Outputs[0].Config.Name)
+	require.NotEqual(t, c.Outputs[0].Config.ID, p.Outputs[0].Config.ID)
+	require.Nil(t, c.Pipeline("missing"))
+
+	c = NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  pipeline = "missing"
+`)))
+	require.EqualError(t, c.CheckPipelines(), `input inputs.memcached: undefined pipeline "missing"`)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[pipelines.security]]
+  [[pipelines.security.inputs.memcached]]
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unsupported section "inputs" in pipeline "security"`)
+}
+
+func TestConfig_Templates(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[templates]
+  memcached = '''
+[[inputs.memcached]]
+  servers = {{ toml .servers }}
+  port = {{ .port }}
+  [inputs.memcached.tags]
+    site = {{ toml .site }}
+'''
+
+[[include]]
+  template = "memcached"
+  [include.parameters]
+    servers = ["10.0.0.1", "10.0.0.2"]
+    port = 11211
+    site = "paris \"north\""
+
+[[include]]
+  template = "memcached"
+  [include.parameters]
+    servers = []
+    port = 11212
+    site = "berlin"
+`)))
+	require.Len(t, c.Inputs, 2)
+	require.ElementsMatch(t, []string{"paris \"north\"", "berlin"},
+		[]string{c.Inputs[0].Config.Tags["site"], c.Inputs[1].Config.Tags["site"]})
+	for _, input := range c.Inputs {
+		plugin := input.Input.(*MockupInputPlugin)
+		if input.Config.Tags["site"] == "berlin" {
+			require.Empty(t, plugin.Servers)
+			require.Equal(t, 11212, plugin.Port)
+		} else {
+			require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, plugin.Servers)
+			require.Equal(t, 11211, plugin.Port)
+		}
+	}
+
+	// Templates remain defined for the configuration loaded afterwards.
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[include]]
+  template = "memcached"
+  [include.parameters]
+    servers = ["10.0.0.3"]
+    port = 11213
+    site = "rome"
+`)))
+	require.Len(t, c.Inputs, 3)
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "missing parameter",
+			data: `
+[[include]]
+  template = "memcached"
+  [include.parameters]
+    servers = []
+`,
+			err: `line 2: instantiating template "memcached"`,
+		},
+		{
+			name: "undefined template",
+			data: `
+[[include]]
+  template = "snmp"
+`,
+			err: `line 2: undefined template "snmp"`,
+		},
+		{
+			name: "redefined template",
+			data: `
+[templates]
+  memcached = "[[inputs.memcached]]"
+`,
+			err: `template "memcached" already defined`,
+		},
+		{
+			name: "template and file",
+			data: `
+[[include]]
+  template = "memcached"
+  file = "memcached.conf"
+`,
+			err: `line 2: include must set either 'template' or 'file'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.LoadConfigData([]byte(tt.data))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestConfig_Include(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig("./testdata/include.toml"))
+	require.Len(t, c.Inputs, 2)
+
+	servers := make([]string, 0, len(c.Inputs))
+	for _, input := range c.Inputs {
+		servers = append(servers, input.Input.(*MockupInputPlugin).Servers...)
+	}
+	require.ElementsMatch(t, []string{"192.168.1.1", "192.168.1.2"}, servers)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[templates]
+  loop = '''
+[[include]]
+  template = "loop"
+'''
+
+[[include]]
+  template = "loop"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "possibly an include cycle")
+}
+
+func TestConfig_IncludeOrder(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[templates]
+  memcached = '''
+[[inputs.memcached]]
+  servers = [{{ toml .server }}]
+'''
+
+[[include]]
+  template = "memcached"
+  [include.parameters]
+    server = "included"
+
+[[inputs.memcached]]
+  servers = ["own"]
+`)))
+	require.Len(t, c.Inputs, 2)
+
+	// Included plugins are added after the plugins of the including file
+	require.Equal(t, []string{"own"}, c.Inputs[0].Input.(*MockupInputPlugin).Servers)
+	require.Equal(t, []string{"included"}, c.Inputs[1].Input.(*MockupInputPlugin).Servers)
+
+	// Templates are only available to the files loaded after their definition
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[include]]
+  template = "memcached"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `undefined template "memcached"`)
+}
+
+func TestConfig_BinaryParser(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.exec]]
+  data_format = "binary"
+  binary_endianness = "le"
+
+  [[inputs.exec.binary]]
+    metric_name = "status"
+    [inputs.exec.binary.filter]
+      length = 4
+      [[inputs.exec.binary.filter.selection]]
+        offset = 0
+        match = "0x01"
+    [[inputs.exec.binary.entry]]
+      name = "device"
+      type = "uint8"
+      assignment = "tag"
+      offset = 1
+    [[inputs.exec.binary.entry]]
+      name = "value"
+      type = "int16"
+`)))
+	require.Len(t, c.Inputs, 1)
+
+	parser := c.Inputs[0].Input.(*MockupInputPlugin).parser
+	require.NotNil(t, parser)
+	metrics, err := parser.Parse([]byte{0x01, 0x07, 0xfe, 0xff})
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "status", metrics[0].Name())
+	require.Equal(t, map[string]string{"device": "7"}, metrics[0].Tags())
+	require.Equal(t, map[string]interface{}{"value": int64(-2)}, metrics[0].Fields())
+
+	metrics, err = parser.Parse([]byte{0x02, 0x07, 0xfe, 0xff})
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+}
+
+func TestConfig_BinaryParserUnusedFields(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.exec]]
+  data_format = "binary"
+
+  [[inputs.exec.binary]]
+    metric_name = "status"
+    [inputs.exec.binary.filter]
+      length = 4
+      [[inputs.exec.binary.filter.selection]]
+        offset = 0
+        match = "0x01"
+        bit_order = "msb"
+    [[inputs.exec.binary.entry]]
+      name = "value"
+      type = "int16"
+      asignment = "tag"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "binary.entry.asignment")
+	require.Contains(t, err.Error(), "binary.filter.selection.bit_order")
+}
+
 /*** Mockup INPUT plugin for testing to avoid cyclic dependencies ***/
 type MockupInputPlugin struct {
 	Servers      []string `toml:"servers"`
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/toml/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the configuration data.  YAML and JSON documents are mapped onto
+// the same tables as the equivalent TOML document.
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// formatFromPath returns the format of the configuration file or URL
+// according to its extension, TOML unless it is a YAML or JSON file.
+func formatFromPath(path string) string {
+	if fetchURLRe.MatchString(path) {
+		if u, err := url.Parse(path); err == nil {
+			path = u.Path
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatTOML
+	}
+}
+
+// formatFromContentType returns the format of the configuration for the
+// content type of a remote configuration, or an empty string if the content
+// type does not determine the format.
+func formatFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case "application/toml":
+		return FormatTOML
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return FormatYAML
+	case "application/json":
+		return FormatJSON
+	default:
+		return ""
+	}
+}
+
+// parseFormat parses the configuration in the given format, replacing the
+// environment variables, and returns the AST of the equivalent TOML
+// configuration.  For YAML and JSON the environment variables are replaced in
+// the parsed values, as escaping them for TOML would corrupt YAML scalars.
+func parseFormat(contents []byte, format string) (*ast.Table, error) {
+	switch format {
+	case FormatTOML, "":
+		return parseConfig(contents)
+	case FormatYAML, FormatJSON:
+		contents = trimBOM(contents)
+		if format == FormatJSON {
+			// Parse as JSON first, YAML would also accept YAML syntax.
+			var v interface{}
+			if err := json.Unmarshal(contents, &v); err != nil {
+				return nil, fmt.Errorf("invalid JSON: %w", err)
+			}
+		}
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(contents, &doc); err != nil {
+			return nil, err
+		}
+		root := &ast.Table{Line: 1, Fields: map[string]interface{}{}}
+		if len(doc.Content) == 0 {
+			return root, nil
+		}
+		node := resolveAlias(doc.Content[0])
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("line %d: expected a mapping of tables", node.Line)
+		}
+		if err := addYAMLFields(root, node); err != nil {
+			return nil, err
+		}
+		return root, nil
+	default:
+		return nil, fmt.Errorf("unsupported configuration format %q", format)
+	}
+}
+
+// addYAMLFields adds the keys of the mapping node to the table.  Mappings are
+// tables, sequences of mappings are arrays of tables, and all other values are
+// key-value pairs.
+func addYAMLFields(tbl *ast.Table, node *yaml.Node) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valNode := node.Content[i], resolveAlias(node.Content[i+1])
+		if keyNode.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: keys must be strings", keyNode.Line)
+		}
+		key := keyNode.Value
+		if _, ok := tbl.Fields[key]; ok {
+			return fmt.Errorf("line %d: key %q already defined", keyNode.Line, key)
+		}
+
+		switch {
+		case valNode.Kind == yaml.MappingNode:
+			sub, err := yamlTable(key, keyNode.Line, ast.TableTypeNormal, valNode)
+			if err != nil {
+				return err
+			}
+			tbl.Fields[key] = sub
+		case valNode.Kind == yaml.SequenceNode && isTableSequence(valNode):
+			tables := make([]*ast.Table, 0, len(valNode.Content))
+			for _, item := range valNode.Content {
+				item = resolveAlias(item)
+				if item.Kind != yaml.MappingNode {
+					return fmt.Errorf("line %d: array of tables %q contains a value", item.Line, key)
+				}
+				sub, err := yamlTable(key, item.Line, ast.TableTypeArray, item)
+				if err != nil {
+					return err
+				}
+				tables = append(tables, sub)
+			}
+			tbl.Fields[key] = tables
+		default:
+			val, err := yamlValue(valNode)
+			if err != nil {
+				return err
+			}
+			tbl.Fields[key] = &ast.KeyValue{Key: key, Value: val, Line: keyNode.Line}
+		}
+	}
+	return nil
+}
+
+func yamlTable(name string, line int, typ ast.TableType, node *yaml.Node) (*ast.Table, error) {
+	tbl := &ast.Table{
+		Line:   line,
+		Name:   name,
+		Type:   typ,
+		Fields: make(map[string]interface{}, len(node.Content)/2),
+	}
+	if err := addYAMLFields(tbl, node); err != nil {
+		return nil, err
+	}
+	return tbl, nil
+}
+
+// yamlValue returns the TOML value of a scalar or sequence node.  The source
+// of the values is their TOML representation, as used by the decoders
+// processing the raw TOML.
+func yamlValue(node *yaml.Node) (ast.Value, error) {
+	if node.Kind == yaml.SequenceNode {
+		values := make([]ast.Value, 0, len(node.Content))
+		sources := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			item = resolveAlias(item)
+			if item.Kind == yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: array contains tables and values", item.Line)
+			}
+			v, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			sources = append(sources, v.Source())
+		}
+		return &ast.Array{Value: values, Data: []rune("[" + strings.Join(sources, ", ") + "]")}, nil
+	}
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("line %d: unsupported value", node.Line)
+	}
+	node = replaceYAMLEnvVars(node)
+
+	switch node.ShortTag() {
+	case "!!str", "!!timestamp", "!!binary":
+		return &ast.String{Value: node.Value, Data: []rune(tomlValue(node.Value))}, nil
+	case "!!int":
+		var v int64
+		if err := node.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		s := strconv.FormatInt(v, 10)
+		return &ast.Integer{Value: s, Data: []rune(s)}, nil
+	case "!!float":
+		var v float64
+		if err := node.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		return &ast.Float{Value: s, Data: []rune(s)}, nil
+	case "!!bool":
+		var v bool
+		if err := node.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		s := strconv.FormatBool(v)
+		return &ast.Boolean{Value: s, Data: []rune(s)}, nil
+	case "!!null":
+		return nil, fmt.Errorf("line %d: null values are not supported", node.Line)
+	default:
+		return nil, fmt.Errorf("line %d: unsupported value of type %s", node.Line, node.ShortTag())
+	}
+}
+
+// replaceYAMLEnvVars returns the scalar node with the references to
+// environment variables in its value replaced by their values.  The type of
+// plain scalars is resolved from the replaced value, so a variable can provide
+// a number or boolean like in TOML, but never a mapping or sequence.
+func replaceYAMLEnvVars(node *yaml.Node) *yaml.Node {
+	value := envVarRe.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		match := envVarRe.FindStringSubmatch(ref)
+		name := match[1]
+		if name == "" {
+			name = match[2]
+		}
+		if envVal, ok := os.LookupEnv(name); ok {
+			return envVal
+		}
+		return ref
+	})
+	if value == node.Value {
+		return node
+	}
+
+	replaced := *node
+	replaced.Value = value
+	if node.Style == 0 && node.Tag == "!!str" {
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &doc); err == nil && len(doc.Content) > 0 &&
+			doc.Content[0].Kind == yaml.ScalarNode && doc.Content[0].Value == value {
+			replaced.Tag = doc.Content[0].Tag
+		}
+	}
+	return &replaced
+}
+
+// isTableSequence returns true if the sequence is an array of tables, which
+// is the case if its first item is a mapping.
+func isTableSequence(node *yaml.Node) bool {
+	return len(node.Content) > 0 && resolveAlias(node.Content[0]).Kind == yaml.MappingNode
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
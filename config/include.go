@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
+)
+
+// maxIncludeDepth limits nested includes to detect include cycles.
+const maxIncludeDepth = 10
+
+var tomlStringEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// templateFuncs are the functions available in configuration templates.
+var templateFuncs = template.FuncMap{
+	"toml": tomlValue,
+}
+
+// include is an [[include]] table, instantiating a template with parameters
+// or including a file.
+type include struct {
+	Template   string                 `toml:"template"`
+	File       string                 `toml:"file"`
+	Parameters map[string]interface{} `toml:"parameters"`
+}
+
+// loadIncludes loads the configuration of each [[include]] table of the
+// configuration, either an instantiated template or the contents of a file, as
+// if it was an additional configuration file.  Templates must be defined by
+// the configuration itself or one loaded before.
+func (c *Config) loadIncludes(tbl *ast.Table) error {
+	val, ok := tbl.Fields["include"]
+	if !ok {
+		return nil
+	}
+	tables, ok := val.([]*ast.Table)
+	if !ok {
+		return fmt.Errorf("invalid configuration, use [[include]] to include templates or files")
+	}
+
+	if c.includeDepth >= maxIncludeDepth {
+		return fmt.Errorf("includes nested deeper than %d levels, possibly an include cycle", maxIncludeDepth)
+	}
+	c.includeDepth++
+	defer func() { c.includeDepth-- }()
+
+	for _, t := range tables {
+		inc := &include{}
+		if err := toml.UnmarshalTable(t, inc); err != nil {
+			return fmt.Errorf("line %d: error parsing include: %w", t.Line, err)
+		}
+		if err := c.loadInclude(inc); err != nil {
+			return fmt.Errorf("line %d: %w", t.Line, err)
+		}
+	}
+	return nil
+}
+
+// addTemplates defines the templates of the table, mapping template names to
+// the template text.
+func (c *Config) addTemplates(tbl *ast.Table) error {
+	names := make([]string, 0, len(tbl.Fields))
+	for name := range tbl.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var text string
+		if kv, ok := tbl.Fields[name].(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				text = str.Value
+			}
+		}
+		if text == "" {
+			return fmt.Errorf("template %q must be a non-empty string", name)
+		}
+		if _, ok := c.templates[name]; ok {
+			return fmt.Errorf("template %q already defined", name)
+		}
+
+		tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("parsing template %q: %w", name, err)
+		}
+		c.templates[name] = tmpl
+	}
+	return nil
+}
+
+// loadInclude loads the configuration of the include.  Relative file names are
+// resolved from the directory of the configuration file being loaded.
+func (c *Config) loadInclude(inc *include) error {
+	switch {
+	case inc.Template != "" && inc.File != "":
+		return errors.New("include must set either 'template' or 'file'")
+	case inc.Template != "":
+		tmpl, ok := c.templates[inc.Template]
+		if !ok {
+			return fmt.Errorf("undefined template %q", inc.Template)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, inc.Parameters); err != nil {
+			return fmt.Errorf("instantiating template %q: %w", inc.Template, err)
+		}
+		if err := c.LoadConfigData(buf.Bytes()); err != nil {
+			return fmt.Errorf("template %q: %w", inc.Template, err)
+		}
+		return nil
+	case inc.File != "":
+		if len(inc.Parameters) > 0 {
+			return errors.New("parameters are only supported for templates")
+		}
+		path := inc.File
+		if !filepath.IsAbs(path) && c.file != "" && !fetchURLRe.MatchString(c.file) {
+			path = filepath.Join(filepath.Dir(c.file), path)
+		}
+		data, format, err := loadConfig(path)
+		if err != nil {
+			return fmt.Errorf("including %q: %w", inc.File, err)
+		}
+
+		file := c.file
+		c.file = path
+		defer func() { c.file = file }()
+		if err := c.LoadConfigDataFormat(data, format); err != nil {
+			return fmt.Errorf("file %q: %w", inc.File, err)
+		}
+		return nil
+	}
+	return errors.New("include must set either 'template' or 'file'")
+}
+
+// tomlValue formats a template parameter as TOML value, quoting strings.
+func tomlValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return `"` + tomlStringEscaper.Replace(v) + `"`
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			values = append(values, tomlValue(e))
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case []string:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			values = append(values, tomlValue(e))
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
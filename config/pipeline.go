@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/toml/ast"
+)
+
+// Pipeline is a named chain of processors, aggregators and outputs.  Only the
+// metrics of the inputs selecting the pipeline with the "pipeline" setting
+// pass through it, the metrics of all other inputs pass through the top-level
+// processors, aggregators and outputs.
+type Pipeline struct {
+	Name        string
+	Outputs     []*models.RunningOutput
+	Aggregators []*models.RunningAggregator
+	// Processors have a slice wrapper type because they need to be sorted
+	Processors    models.RunningProcessors
+	AggProcessors models.RunningProcessors
+}
+
+// Pipeline returns the named pipeline, or nil if it is not defined.
+func (c *Config) Pipeline(name string) *Pipeline {
+	for _, p := range c.Pipelines {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// AllOutputs returns the top-level outputs followed by the outputs of the
+// named pipelines.
+func (c *Config) AllOutputs() []*models.RunningOutput {
+	outputs := append([]*models.RunningOutput(nil), c.Outputs...)
+	for _, p := range c.Pipelines {
+		outputs = append(outputs, p.Outputs...)
+	}
+	return outputs
+}
+
+// CheckPipelines returns an error if an input selects a pipeline that is not
+// defined.  Pipelines may be defined in any configuration file, so this is
+// only checked once all files are loaded.
+func (c *Config) CheckPipelines() error {
+	for _, input := range c.Inputs {
+		if input.Config.Pipeline != "" && c.Pipeline(input.Config.Pipeline) == nil {
+			return fmt.Errorf("input %s: undefined pipeline %q", input.LogName(), input.Config.Pipeline)
+		}
+	}
+	return nil
+}
+
+// addPipeline adds the processors, aggregators and outputs defined in the
+// table to the named pipeline.  A pipeline may be defined in several tables,
+// their plugins are combined.
+func (c *Config) addPipeline(name string, table *ast.Table) error {
+	p := c.Pipeline(name)
+	if p == nil {
+		p = &Pipeline{Name: name}
+		c.Pipelines = append(c.Pipelines, p)
+	}
+
+	for section, val := range table.Fields {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing field %q of pipeline %q as table", section, name)
+		}
+
+		for pluginName, pluginVal := range subTable.Fields {
+			plugin := "pipelines." + name + "." + section + "." + pluginName
+			pluginSubTables, ok := pluginVal.([]*ast.Table)
+			if !ok {
+				return fmt.Errorf("unsupported config format: %s", plugin)
+			}
+			for _, t := range pluginSubTables {
+				err := c.check(plugin, t, p.addPlugin(c, section, pluginName, pluginID(plugin, t), t))
+				if err != nil {
+					return fmt.Errorf("error parsing %s, %w", plugin, err)
+				}
+			}
+			if len(c.UnusedFields) > 0 {
+				return fmt.Errorf("plugin %s: line %d: configuration specified the fields %q, but they weren't used", plugin, subTable.Line, keys(c.UnusedFields))
+			}
+		}
+	}
+
+	if len(p.Processors) > 1 {
+		sort.Sort(p.Processors)
+	}
+	return nil
+}
+
+// addPlugin adds the plugin of the given section to the pipeline.  The ID of
+// the plugin includes the pipeline, so the same plugin in different pipelines
+// is distinguished.
+func (p *Pipeline) addPlugin(c *Config, section, name, id string, table *ast.Table) error {
+	c.tables[id] = table
+	switch section {
+	case "outputs":
+		ro, err := c.loadOutput(name, table)
+		if err != nil || ro == nil {
+			return err
+		}
+		ro.Config.ID = id
+		p.Outputs = append(p.Outputs, ro)
+	case "processors":
+		rf, aggRf, err := c.loadProcessor(name, table)
+		if err != nil {
+			return err
+		}
+		if rf != nil {
+			rf.Config.ID = id
+			p.Processors = append(p.Processors, rf)
+		}
+		if aggRf != nil {
+			aggRf.Config.ID = id
+			p.AggProcessors = append(p.AggProcessors, aggRf)
+		}
+	case "aggregators":
+		ra, err := c.loadAggregator(name, table)
+		if err != nil {
+			return err
+		}
+		ra.Config.ID = id
+		p.Aggregators = append(p.Aggregators, ra)
+	default:
+		return fmt.Errorf("unsupported section %q in pipeline %q, expected outputs, processors or aggregators", section, p.Name)
+	}
+	return nil
+}
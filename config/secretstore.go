@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+	"github.com/influxdata/toml/ast"
+)
+
+var (
+	// secretRefRe is a regex to find secret references in the config file
+	secretRefRe = regexp.MustCompile(`@\{(\w+):([^}]+)\}`)
+
+	// secretStoreIDRe is a regex validating the identifiers of secret stores
+	secretStoreIDRe = regexp.MustCompile(`^\w+$`)
+)
+
+// addSecretStore creates and initializes the secret store configured in the
+// table.
+func (c *Config) addSecretStore(name string, table *ast.Table) error {
+	creator, ok := secretstores.SecretStores[name]
+	if !ok {
+		return fmt.Errorf("Undefined but requested secretstore: %s", name)
+	}
+	store := creator()
+
+	var id string
+	c.getFieldString(table, "id", &id)
+	delete(table.Fields, "id")
+	if !secretStoreIDRe.MatchString(id) {
+		return fmt.Errorf("invalid id %q, only letters, digits and underscores are allowed", id)
+	}
+	if _, found := c.SecretStores[id]; found {
+		return fmt.Errorf("duplicate id %q", id)
+	}
+
+	if err := c.toml.UnmarshalTable(table, store); err != nil {
+		return err
+	}
+	c.checkDurations(table, store)
+
+	if p, ok := store.(telegraf.Initializer); ok {
+		if err := p.Init(); err != nil {
+			return fmt.Errorf("could not initialize secretstore %s: %v", id, err)
+		}
+	}
+
+	c.SecretStores[id] = store
+	return nil
+}
+
+// pendingSecrets is a plugin whose secret references are resolved once all
+// configuration files are loaded.
+type pendingSecrets struct {
+	plugin string
+	file   string
+	table  *ast.Table
+	target interface{}
+}
+
+// deferSecrets records the plugin defined in the table for resolving its
+// secret references in ResolveSecrets.  The stores might be defined in a file
+// loaded later, so the references cannot be resolved while loading the
+// plugin.
+func (c *Config) deferSecrets(plugin string, table *ast.Table, target interface{}) {
+	c.pendingSecrets = append(c.pendingSecrets, pendingSecrets{
+		plugin: plugin,
+		file:   c.file,
+		table:  table,
+		target: target,
+	})
+}
+
+// ResolveSecrets replaces the @{store:key} references of the plugins loaded
+// since the last call with the secrets from the referenced stores.  It must
+// be called after loading all configuration files and before initializing
+// the plugins.  When validating, the errors are recorded in Problems instead.
+func (c *Config) ResolveSecrets() error {
+	pending := c.pendingSecrets
+	c.pendingSecrets = nil
+	for _, p := range pending {
+		err := c.resolveSecrets(p.target)
+		if err == nil {
+			continue
+		}
+		problem := &Problem{File: p.file, Line: p.table.Line, Plugin: p.plugin, Err: err}
+		if !c.Validating {
+			return problem
+		}
+		c.Problems = append(c.Problems, problem)
+	}
+	return nil
+}
+
+// resolveSecrets replaces the @{store:key} references in all string settings
+// of the plugin with the secrets from the referenced stores, so the plugin is
+// initialized with the actual secrets.
+func (c *Config) resolveSecrets(plugin interface{}) error {
+	return c.resolveValue(reflect.ValueOf(plugin), make(map[uintptr]bool))
+}
+
+func (c *Config) resolveValue(v reflect.Value, visited map[uintptr]bool) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return nil
+		}
+		visited[v.Pointer()] = true
+		return c.resolveValue(v.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue // unexported
+			}
+			if err := c.resolveValue(v.Field(i), visited); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := c.resolveValue(v.Index(i), visited); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			value := iter.Value().String()
+			resolved, err := c.resolveString(value)
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), reflect.ValueOf(resolved).Convert(v.Type().Elem()))
+			if resolved != value && iter.Key().Kind() == reflect.String {
+				c.secretRefs[secretField{addr: v.Pointer(), key: iter.Key().String()}] = value
+			}
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		value := v.String()
+		resolved, err := c.resolveString(value)
+		if err != nil {
+			return err
+		}
+		v.SetString(resolved)
+		if resolved != value {
+			c.secretRefs[secretField{addr: v.UnsafeAddr()}] = value
+		}
+	}
+	return nil
+}
+
+// secretField identifies a string setting of a plugin, by the address of the
+// string or by the map and the key holding it.
+type secretField struct {
+	addr uintptr
+	key  string
+}
+
+// secretRef returns the value with the secret references of the string
+// setting, if it was resolved from secret stores.
+func (c *Config) secretRef(v reflect.Value) (string, bool) {
+	if v.Kind() != reflect.String || !v.CanAddr() {
+		return "", false
+	}
+	ref, ok := c.secretRefs[secretField{addr: v.UnsafeAddr()}]
+	return ref, ok
+}
+
+// secretMapRef returns the value with the secret references of the map
+// entry, if it was resolved from secret stores.
+func (c *Config) secretMapRef(m reflect.Value, key string) (string, bool) {
+	ref, ok := c.secretRefs[secretField{addr: m.Pointer(), key: key}]
+	return ref, ok
+}
+
+// resolveString replaces the secret references in the string.
+func (c *Config) resolveString(s string) (string, error) {
+	var err error
+	resolved := secretRefRe.ReplaceAllStringFunc(s, func(ref string) string {
+		parts := secretRefRe.FindStringSubmatch(ref)
+		store, ok := c.SecretStores[parts[1]]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("unknown secretstore %q in %s", parts[1], ref)
+			}
+			return ref
+		}
+		secret, getErr := store.Get(parts[2])
+		if getErr != nil {
+			if err == nil {
+				err = fmt.Errorf("resolving %s failed: %w", ref, getErr)
+			}
+			return ref
+		}
+		return string(secret)
+	})
+	return resolved, err
+}
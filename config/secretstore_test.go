@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_SecretStore(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[secretstores.mock]]
+  id = "mystore"
+  secrets = { password = "secret", host = "example.com" }
+
+[[inputs.memcached]]
+  servers = ["tcp://@{mystore:host}:11211"]
+  command = "login --password=@{mystore:password}"
+  tls_key = "@{mystore:password}"
+`)))
+	require.NoError(t, c.ResolveSecrets())
+	require.Len(t, c.SecretStores, 1)
+	require.Contains(t, c.SecretStores, "mystore")
+
+	require.Len(t, c.Inputs, 1)
+	input := c.Inputs[0].Input.(*MockupInputPlugin)
+	require.Equal(t, []string{"tcp://example.com:11211"}, input.Servers)
+	require.Equal(t, "login --password=secret", input.Command)
+	require.Equal(t, "secret", input.TLSKey)
+}
+
+func TestConfig_SecretStoreErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "unknown store",
+			config: `
+[[inputs.memcached]]
+  command = "@{unknown:password}"
+`,
+			err: `unknown secretstore "unknown" in @{unknown:password}`,
+		},
+		{
+			name: "missing secret",
+			config: `
+[[secretstores.mock]]
+  id = "mystore"
+
+[[inputs.memcached]]
+  command = "@{mystore:password}"
+`,
+			err: `resolving @{mystore:password} failed: secret "password" not found`,
+		},
+		{
+			name: "invalid id",
+			config: `
+[[secretstores.mock]]
+  id = "my-store"
+`,
+			err: `invalid id "my-store"`,
+		},
+		{
+			name: "duplicate id",
+			config: `
+[[secretstores.mock]]
+  id = "mystore"
+[[secretstores.mock]]
+  id = "mystore"
+`,
+			err: `duplicate id "mystore"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			err := c.LoadConfigData([]byte(tt.config))
+			if err == nil {
+				err = c.ResolveSecrets()
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestConfig_SecretStoreInLaterFile(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  command = "login --password=@{mystore:password}"
+`)))
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[secretstores.mock]]
+  id = "mystore"
+  secrets = { password = "secret" }
+`)))
+	require.NoError(t, c.ResolveSecrets())
+
+	require.Len(t, c.Inputs, 1)
+	input := c.Inputs[0].Input.(*MockupInputPlugin)
+	require.Equal(t, "login --password=secret", input.Command)
+}
+
+func TestConfig_SecretStoresOnly(t *testing.T) {
+	c := NewConfig()
+	c.SecretStoresOnly = true
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[secretstores.mock]]
+  id = "mystore"
+
+[[inputs.memcached]]
+  command = "@{mystore:password}"
+`)))
+	require.Len(t, c.SecretStores, 1)
+	require.Empty(t, c.Inputs)
+}
+
+/*** Mockup SECRETSTORE plugin for testing to avoid cyclic dependencies ***/
+type MockupSecretStore struct {
+	Secrets map[string]string `toml:"secrets"`
+}
+
+func (s *MockupSecretStore) SampleConfig() string { return "" }
+func (s *MockupSecretStore) Description() string  { return "" }
+
+func (s *MockupSecretStore) Get(key string) ([]byte, error) {
+	value, ok := s.Secrets[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %q not found", key)
+	}
+	return []byte(value), nil
+}
+
+func (s *MockupSecretStore) Set(key string, value []byte) error {
+	s.Secrets[key] = string(value)
+	return nil
+}
+
+func (s *MockupSecretStore) List() ([]string, error) {
+	keys := make([]string, 0, len(s.Secrets))
+	for key := range s.Secrets {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func init() {
+	secretstores.Add("mock", func() telegraf.SecretStore { return &MockupSecretStore{} })
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -14,43 +15,51 @@ type Duration time.Duration
 // Size is an int64
 type Size int64
 
-// UnmarshalTOML parses the duration from the TOML config file
+// UnmarshalTOML parses the duration from the TOML config file.  Invalid
+// values leave the duration unchanged, they are reported by Config.Validate.
 func (d *Duration) UnmarshalTOML(b []byte) error {
-	var err error
+	dur, ok, err := parseDuration(b)
+	if err == nil && ok {
+		*d = Duration(dur)
+	}
+	return nil
+}
+
+// parseDuration parses a duration string, e.g. "1s", or a number of seconds.
+// An empty string is valid but not a duration, so ok is false.
+func parseDuration(b []byte) (dur time.Duration, ok bool, err error) {
 	b = bytes.Trim(b, `'`)
 
 	// see if we can directly convert it
-	dur, err := time.ParseDuration(string(b))
+	dur, err = time.ParseDuration(string(b))
 	if err == nil {
-		*d = Duration(dur)
-		return nil
+		return dur, true, nil
 	}
 
 	// Parse string duration, ie, "1s"
-	if uq, err := strconv.Unquote(string(b)); err == nil && len(uq) > 0 {
+	if uq, err := strconv.Unquote(string(b)); err == nil {
+		if len(uq) == 0 {
+			return 0, false, nil
+		}
 		dur, err := time.ParseDuration(uq)
-		if err == nil {
-			*d = Duration(dur)
-			return nil
+		if err != nil {
+			return 0, false, err
 		}
+		return dur, true, nil
 	}
 
 	// First try parsing as integer seconds
 	sI, err := strconv.ParseInt(string(b), 10, 64)
 	if err == nil {
-		dur := time.Second * time.Duration(sI)
-		*d = Duration(dur)
-		return nil
+		return time.Second * time.Duration(sI), true, nil
 	}
 	// Second try parsing as float seconds
 	sF, err := strconv.ParseFloat(string(b), 64)
 	if err == nil {
-		dur := time.Second * time.Duration(sF)
-		*d = Duration(dur)
-		return nil
+		return time.Second * time.Duration(sF), true, nil
 	}
 
-	return nil
+	return 0, false, fmt.Errorf("invalid duration %s", b)
 }
 
 func (d *Duration) UnmarshalText(text []byte) error {
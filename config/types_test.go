@@ -51,6 +51,16 @@ func TestDuration(t *testing.T) {
 	d = config.Duration(0)
 	require.NoError(t, d.UnmarshalTOML([]byte(`1.5`)))
 	require.Equal(t, time.Second, time.Duration(d))
+
+	d = config.Duration(0)
+	require.NoError(t, d.UnmarshalTOML([]byte(`""`)))
+	require.Equal(t, time.Duration(0), time.Duration(d))
+
+	// Invalid durations are reported by Validate only
+	d = config.Duration(time.Second)
+	require.NoError(t, d.UnmarshalTOML([]byte(`"10x"`)))
+	require.NoError(t, d.UnmarshalTOML([]byte(`abc`)))
+	require.Equal(t, time.Second, time.Duration(d))
 }
 
 func TestSize(t *testing.T) {
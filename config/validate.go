@@ -0,0 +1,350 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/toml/ast"
+)
+
+// dataFormatOptions maps the parser and serializer options to the data formats
+// using them.  Options are matched by name first and then by prefix.
+var dataFormatOptions = map[string][]string{
+	"avro_":            {"avro"},
+	"batch_template":   {"template"},
+	"binary":           {"binary"},
+	"binary_":          {"binary"},
+	"carbon2_":         {"carbon2"},
+	"collectd_":        {"collectd"},
+	"csv_":             {"csv"},
+	"dropwizard_":      {"dropwizard"},
+	"form_urlencoded_": {"form_urlencoded"},
+	"graphite_":        {"graphite"},
+	"grok_":            {"grok"},
+	"influx_":          {"influx"},
+	"json_":            {"json"},
+	"json_v2":          {"json_v2"},
+	"prometheus_":      {"prometheus", "prometheusremotewrite"},
+	"protobuf_":        {"protobuf"},
+	"splunkmetric_":    {"splunkmetric"},
+	"wavefront_":       {"wavefront"},
+	"xml":              {"xml", "xpath_json", "xpath_msgpack", "xpath_protobuf"},
+	"xpath":            {"xml", "xpath_json", "xpath_msgpack", "xpath_protobuf"},
+	"xpath_":           {"xml", "xpath_json", "xpath_msgpack", "xpath_protobuf"},
+	"xpath_json":       {"xpath_json"},
+	"xpath_msgpack":    {"xpath_msgpack"},
+	"xpath_protobuf":   {"xpath_protobuf"},
+	"xpath_protobuf_":  {"xpath_protobuf"},
+}
+
+// Problem is an issue found in the configuration when validating it.
+type Problem struct {
+	// File is the configuration file, empty when loading data directly.
+	File string
+	// Line of the table the problem was found in, zero if unknown.
+	Line int
+	// Plugin is the plugin the problem was found in, e.g. "inputs.cpu".
+	Plugin string
+	Err    error
+}
+
+func (p *Problem) Error() string {
+	var sb strings.Builder
+	if p.File != "" {
+		sb.WriteString(p.File)
+		sb.WriteString(":")
+	}
+	if p.Line > 0 {
+		fmt.Fprintf(&sb, "%d:", p.Line)
+	}
+	if sb.Len() > 0 {
+		sb.WriteString(" ")
+	}
+	if p.Plugin != "" {
+		sb.WriteString(p.Plugin)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(p.Err.Error())
+	return sb.String()
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// location is the position of a plugin's table in the configuration.
+type location struct {
+	file string
+	line int
+}
+
+// addProblem records a problem found in the given table.
+func (c *Config) addProblem(plugin string, tbl *ast.Table, err error) {
+	p := &Problem{File: c.file, Plugin: plugin, Err: err}
+	if tbl != nil {
+		p.Line = tbl.Line
+	}
+	c.Problems = append(c.Problems, p)
+}
+
+// check returns the error of adding the plugin defined in tbl.  When
+// validating, the error, all setting errors and the unused fields of the table
+// are recorded as problems instead and nil is returned to continue loading.
+func (c *Config) check(plugin string, tbl *ast.Table, err error) error {
+	if !c.Validating {
+		return err
+	}
+
+	errs := c.errs
+	c.errs = nil
+	for _, e := range errs {
+		// Strip the position, the problem carries the line of the table.
+		if inner := errors.Unwrap(e); inner != nil {
+			e = inner
+		}
+		c.addProblem(plugin, tbl, e)
+	}
+	if err != nil && len(errs) == 0 {
+		c.addProblem(plugin, tbl, err)
+	}
+
+	if len(c.UnusedFields) > 0 {
+		unused := keys(c.UnusedFields)
+		sort.Strings(unused)
+		c.addProblem(plugin, tbl, fmt.Errorf("configuration specified the fields %q, but they weren't used", unused))
+		c.UnusedFields = make(map[string]bool)
+	}
+	c.commonFields = make(map[string]bool)
+	return nil
+}
+
+// addLocation records the position of the table defining the plugin.  The
+// location is kept per plugin instance, as identical tables result in plugins
+// with the same ID.
+func (c *Config) addLocation(plugin interface{}, tbl *ast.Table) {
+	c.locations[plugin] = location{file: c.file, line: tbl.Line}
+}
+
+// checkDataFormat records problems for the parser and serializer options of
+// the table not used by the plugin, either because the plugin does not support
+// data formats or because the options belong to another format.  It must be
+// called after unmarshalling the table into the plugin.
+func (c *Config) checkDataFormat(plugin string, tbl *ast.Table, supported bool) {
+	if !c.Validating {
+		return
+	}
+
+	if !supported {
+		if c.commonFields["data_format"] {
+			c.addProblem(plugin, tbl, errors.New("plugin does not support setting 'data_format'"))
+		}
+		return
+	}
+
+	var format string
+	c.getFieldString(tbl, "data_format", &format)
+	if format == "" {
+		format = "influx"
+		// Legacy default of the exec input
+		if plugin == "inputs.exec" {
+			format = "json"
+		}
+	}
+
+	options := keys(c.commonFields)
+	sort.Strings(options)
+	for _, key := range options {
+		formats := dataFormatFor(key)
+		if formats == nil || choice.Contains(format, formats) {
+			continue
+		}
+		c.addProblem(plugin, tbl, fmt.Errorf("option %q is not used by data_format %q", key, format))
+	}
+}
+
+// checkDurations records an error for each invalid duration in the table
+// unmarshalled into v, including the durations of nested tables.  Invalid
+// durations are ignored when running, so they are only checked when
+// validating.
+func (c *Config) checkDurations(tbl *ast.Table, v interface{}) {
+	if !c.Validating {
+		return
+	}
+	c.checkDurationFields(tbl, reflect.TypeOf(v))
+}
+
+func (c *Config) checkDurationFields(tbl *ast.Table, typ reflect.Type) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+
+	keys := make([]string, 0, len(tbl.Fields))
+	for key := range tbl.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, ok := c.tomlField(typ, key)
+		if !ok {
+			continue
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+
+		switch node := tbl.Fields[key].(type) {
+		case *ast.KeyValue:
+			if ft != configDurType {
+				continue
+			}
+			values := []ast.Value{node.Value}
+			if array, ok := node.Value.(*ast.Array); ok {
+				values = array.Value
+			}
+			for _, value := range values {
+				if _, _, err := parseDuration([]byte(value.Source())); err != nil {
+					c.addError(tbl, fmt.Errorf("line %d: (%s.%s) %w", node.Line, typ, field.Name, err))
+				}
+			}
+		case *ast.Table:
+			c.checkDurationFields(node, ft)
+		case []*ast.Table:
+			for _, t := range node {
+				c.checkDurationFields(t, ft)
+			}
+		}
+	}
+}
+
+// tomlField returns the struct field the key is decoded into, matching the
+// field lookup of the TOML decoder.
+func (c *Config) tomlField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	var auto *reflect.StructField
+	var find func(t reflect.Type) *reflect.StructField
+	find = func(t reflect.Type) *reflect.StructField {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("toml"), ",")[0]
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && tag == "" {
+				if found := find(f.Type); found != nil {
+					return found
+				}
+				continue
+			}
+			switch {
+			case tag == key:
+				return &f
+			case tag == "" && auto == nil && c.toml.NormFieldName(t, f.Name) == c.toml.NormFieldName(t, key):
+				auto = &f
+			}
+		}
+		return nil
+	}
+	if f := find(typ); f != nil {
+		return *f, true
+	}
+	if auto != nil {
+		return *auto, true
+	}
+	return reflect.StructField{}, false
+}
+
+// dataFormatFor returns the data formats using the option, or nil if the option
+// is not a data format option.
+func dataFormatFor(key string) []string {
+	if formats, ok := dataFormatOptions[key]; ok {
+		return formats
+	}
+	var prefix string
+	for p := range dataFormatOptions {
+		if strings.HasSuffix(p, "_") && strings.HasPrefix(key, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	return dataFormatOptions[prefix]
+}
+
+// Validate initializes all plugins of the configuration without starting or
+// connecting them and returns all problems found when loading and
+// initializing.  The configuration must be loaded with Validating set.
+func (c *Config) Validate() []*Problem {
+	problems := c.Problems
+	reported := make(map[string]bool)
+	for _, p := range problems {
+		reported[p.Error()] = true
+	}
+	initialize := func(plugin string, p interface{}, init func() error) {
+		if err := init(); err != nil {
+			loc := c.locations[p]
+			problem := &Problem{
+				File:   loc.file,
+				Line:   loc.line,
+				Plugin: plugin,
+				Err:    fmt.Errorf("initializing failed: %w", err),
+			}
+			// Processors of both aggregation stages are loaded twice
+			if !reported[problem.Error()] {
+				reported[problem.Error()] = true
+				problems = append(problems, problem)
+			}
+		}
+	}
+	initializeProcessors := func(prefix string, processors models.RunningProcessors) {
+		for _, processor := range processors {
+			initialize(prefix+"processors."+processor.Config.Name, processor.Processor, processor.Init)
+		}
+	}
+	initializeOutputs := func(prefix string, outputs []*models.RunningOutput) {
+		for _, output := range outputs {
+			// Keep the metrics in memory to not touch the disk buffer of a
+			// running agent.
+			output.MemoryBuffer = true
+			initialize(prefix+"outputs."+output.Config.Name, output.Output, output.Init)
+		}
+	}
+
+	for _, input := range c.Inputs {
+		initialize("inputs."+input.Config.Name, input.Input, input.Init)
+	}
+	initializeProcessors("", c.Processors)
+	for _, aggregator := range c.Aggregators {
+		initialize("aggregators."+aggregator.Config.Name, aggregator.Aggregator, aggregator.Init)
+	}
+	initializeProcessors("", c.AggProcessors)
+	initializeOutputs("", c.Outputs)
+	for _, p := range c.Pipelines {
+		prefix := "pipelines." + p.Name + "."
+		initializeProcessors(prefix, p.Processors)
+		for _, aggregator := range p.Aggregators {
+			initialize(prefix+"aggregators."+aggregator.Config.Name, aggregator.Aggregator, aggregator.Init)
+		}
+		initializeProcessors(prefix, p.AggProcessors)
+		initializeOutputs(prefix, p.Outputs)
+	}
+
+	if _, err := models.ResolveDeadLetters(c.Outputs); err != nil {
+		problems = append(problems, &Problem{Err: err})
+	}
+	for _, p := range c.Pipelines {
+		if _, err := models.ResolveDeadLetters(p.Outputs); err != nil {
+			problems = append(problems, &Problem{Err: fmt.Errorf("pipeline %q: %w", p.Name, err)})
+		}
+	}
+	if err := c.CheckPipelines(); err != nil {
+		problems = append(problems, &Problem{Err: err})
+	}
+	return problems
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	c := NewConfig()
+	c.Validating = true
+	require.NoError(t, c.LoadConfig("./testdata/invalid_plugins.toml"))
+
+	problems := c.Validate()
+	messages := make([]string, 0, len(problems))
+	for _, p := range problems {
+		messages = append(messages, p.Error())
+	}
+	require.Equal(t, []string{
+		`./testdata/invalid_plugins.toml:1: agent: configuration specified the fields ["not_an_agent_field"], but they weren't used`,
+		`./testdata/invalid_plugins.toml:5: inputs.memcached: configuration specified the fields ["not_a_field"], but they weren't used`,
+		`./testdata/invalid_plugins.toml:9: inputs.memcached: line 10: (config.MockupInputPlugin.Timeout) time: unknown unit "x" in duration "10x"`,
+		`./testdata/invalid_plugins.toml:12: inputs.exec: Invalid data format: unknown`,
+		`./testdata/invalid_plugins.toml:15: inputs.http_listener_v2: option "csv_delimiter" is not used by data_format "json"`,
+		`./testdata/invalid_plugins.toml:19: inputs.not_a_plugin: Undefined but requested input: not_a_plugin`,
+		`./testdata/invalid_plugins.toml:21: outputs.http: plugin does not support setting 'data_format'`,
+		`./testdata/invalid_plugins.toml:24: processors.validate_init: initializing failed: init failed`,
+		`./testdata/invalid_plugins.toml:29: processors.validate_init: initializing failed: init failed`,
+		`./testdata/invalid_plugins.toml:26: outputs.http: initializing failed: invalid buffer strategy "unknown"`,
+	}, messages)
+
+	// Valid plugins are still loaded, invalid durations are ignored when
+	// running and so don't prevent loading the plugin either.
+	require.Len(t, c.Inputs, 3)
+	require.Len(t, c.Outputs, 2)
+	require.Len(t, c.Processors, 1)
+	require.Len(t, c.AggProcessors, 2)
+}
+
+func TestConfig_ValidateDiskBuffer(t *testing.T) {
+	dir := t.TempDir()
+	c := NewConfig()
+	c.Validating = true
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  buffer_strategy = "disk"
+  buffer_directory = "`+filepath.ToSlash(dir)+`"
+`)))
+	require.Empty(t, c.Validate())
+
+	// Validating must not create the write-ahead-log of a running agent
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestConfig_ValidateNotValidating(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/invalid_plugins.toml")
+	require.Error(t, err)
+	require.Empty(t, c.Problems)
+}
+
+func TestConfig_ValidateParseError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "telegraf.conf")
+	require.NoError(t, os.WriteFile(filename, []byte("[[inputs.memcached"), 0600))
+
+	c := NewConfig()
+	c.Validating = true
+	require.NoError(t, c.LoadConfig(filename))
+
+	problems := c.Validate()
+	require.Len(t, problems, 1)
+	require.Equal(t, filename, problems[0].File)
+	require.Contains(t, problems[0].Error(), "invalid TOML syntax")
+}
+
+func TestConfig_ValidateDeadLetter(t *testing.T) {
+	c := NewConfig()
+	c.Validating = true
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  url = "http://localhost"
+  dead_letter = "audit"
+`)))
+	require.Equal(t, "audit", c.Outputs[0].Config.DeadLetter)
+
+	problems := c.Validate()
+	require.Len(t, problems, 1)
+	require.Equal(t, `outputs.http: dead letter output "audit" not found`, problems[0].Error())
+}
+
+func TestConfig_ValidatePipelines(t *testing.T) {
+	c := NewConfig()
+	c.Validating = true
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  pipeline = "missing"
+
+[[pipelines.security]]
+  [[pipelines.security.processors.validate_init]]
+`)))
+
+	problems := c.Validate()
+	require.Len(t, problems, 2)
+	require.Equal(t, `6: pipelines.security.processors.validate_init: initializing failed: init failed`, problems[0].Error())
+	require.Equal(t, `input inputs.memcached: undefined pipeline "missing"`, problems[1].Error())
+}
+
+func TestConfig_ValidateIdenticalPlugins(t *testing.T) {
+	c := NewConfig()
+	c.Validating = true
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[processors.validate_init]]
+
+[[processors.validate_init]]
+`)))
+
+	problems := c.Validate()
+	require.Len(t, problems, 2)
+	require.Equal(t, `2: processors.validate_init: initializing failed: init failed`, problems[0].Error())
+	require.Equal(t, `4: processors.validate_init: initializing failed: init failed`, problems[1].Error())
+}
+
+/*** Mockup PROCESSOR plugin failing to initialize ***/
+type MockupProcessorInitError struct{}
+
+func (m *MockupProcessorInitError) SampleConfig() string { return "Mockup test processor plugin" }
+func (m *MockupProcessorInitError) Description() string  { return "Mockup test processor plugin" }
+func (m *MockupProcessorInitError) Init() error          { return errors.New("init failed") }
+func (m *MockupProcessorInitError) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	return in
+}
+
+func init() {
+	processors.Add("validate_init", func() telegraf.Processor { return &MockupProcessorInitError{} })
+}
@@ -0,0 +1,375 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Expression is a boolean expression evaluated against a metric, ie:
+//
+//	e, _ := CompileExpression(`fields.value > 100 && tags.region == "eu"`)
+//	e.Eval(m) // true if the metric has a value above 100 in the eu region
+//
+// Expressions use the Go expression syntax.  The metric is accessed using
+// `name`, `fields.<key>` and `tags.<key>`, keys that are not valid identifiers
+// can be accessed using an index, ie `tags["host-name"]`.  Supported are
+// boolean, integer, float and double-quoted string literals, the logical
+// operators `&&`, `||` and `!`, comparisons and the arithmetic operators
+// `+`, `-`, `*`, `/` and `%`.
+type Expression struct {
+	source string
+	eval   evalFunc
+}
+
+type evalFunc func(m telegraf.Metric) (interface{}, error)
+
+// CompileExpression parses the expression and prepares it for evaluation.
+func CompileExpression(expr string) (*Expression, error) {
+	root, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	eval, err := compileNode(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{source: expr, eval: eval}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression for the metric.  An error is returned if the
+// expression references a field or tag not present in the metric or if the
+// expression does not result in a boolean.
+func (e *Expression) Eval(m telegraf.Metric) (bool, error) {
+	v, err := e.eval(m)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression result %v is not a boolean", v)
+	}
+	return b, nil
+}
+
+func compileNode(node ast.Expr) (evalFunc, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return compileNode(n.X)
+	case *ast.BasicLit:
+		v, err := literal(n)
+		if err != nil {
+			return nil, err
+		}
+		return func(telegraf.Metric) (interface{}, error) { return v, nil }, nil
+	case *ast.Ident:
+		switch n.Name {
+		case "true":
+			return func(telegraf.Metric) (interface{}, error) { return true, nil }, nil
+		case "false":
+			return func(telegraf.Metric) (interface{}, error) { return false, nil }, nil
+		case "name":
+			return func(m telegraf.Metric) (interface{}, error) { return m.Name(), nil }, nil
+		}
+		return nil, fmt.Errorf("unknown identifier %q", n.Name)
+	case *ast.SelectorExpr:
+		return compileAccess(n.X, n.Sel.Name)
+	case *ast.IndexExpr:
+		lit, ok := n.Index.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return nil, fmt.Errorf("index must be a string literal at position %d", n.Index.Pos())
+		}
+		key, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return nil, err
+		}
+		return compileAccess(n.X, key)
+	case *ast.UnaryExpr:
+		return compileUnary(n)
+	case *ast.BinaryExpr:
+		return compileBinary(n)
+	}
+	return nil, fmt.Errorf("unsupported expression at position %d", node.Pos())
+}
+
+func literal(lit *ast.BasicLit) (interface{}, error) {
+	switch lit.Kind {
+	case token.INT:
+		return strconv.ParseInt(lit.Value, 0, 64)
+	case token.FLOAT:
+		return strconv.ParseFloat(lit.Value, 64)
+	case token.STRING:
+		return strconv.Unquote(lit.Value)
+	}
+	return nil, fmt.Errorf("unsupported literal %s", lit.Value)
+}
+
+func compileAccess(node ast.Expr, key string) (evalFunc, error) {
+	ident, ok := node.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("unsupported expression at position %d", node.Pos())
+	}
+
+	switch ident.Name {
+	case "fields":
+		return func(m telegraf.Metric) (interface{}, error) {
+			v, ok := m.GetField(key)
+			if !ok {
+				return nil, fmt.Errorf("field %q not found", key)
+			}
+			return normalize(v), nil
+		}, nil
+	case "tags":
+		return func(m telegraf.Metric) (interface{}, error) {
+			v, ok := m.GetTag(key)
+			if !ok {
+				return nil, fmt.Errorf("tag %q not found", key)
+			}
+			return v, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown identifier %q", ident.Name)
+}
+
+// normalize converts field values to int64, float64, string or bool.
+func normalize(v interface{}) interface{} {
+	if u, ok := v.(uint64); ok {
+		if u > math.MaxInt64 {
+			return float64(u)
+		}
+		return int64(u)
+	}
+	return v
+}
+
+func compileUnary(n *ast.UnaryExpr) (evalFunc, error) {
+	x, err := compileNode(n.X)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case token.NOT:
+		return func(m telegraf.Metric) (interface{}, error) {
+			v, err := x(m)
+			if err != nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("invalid operand %v for !", v)
+			}
+			return !b, nil
+		}, nil
+	case token.SUB:
+		return func(m telegraf.Metric) (interface{}, error) {
+			v, err := x(m)
+			if err != nil {
+				return nil, err
+			}
+			switch v := v.(type) {
+			case int64:
+				return -v, nil
+			case float64:
+				return -v, nil
+			}
+			return nil, fmt.Errorf("invalid operand %v for -", v)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s at position %d", n.Op, n.OpPos)
+}
+
+func compileBinary(n *ast.BinaryExpr) (evalFunc, error) {
+	x, err := compileNode(n.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := compileNode(n.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case token.LAND:
+		return logical(x, y, false), nil
+	case token.LOR:
+		return logical(x, y, true), nil
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ,
+		token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+	default:
+		return nil, fmt.Errorf("unsupported operator %s at position %d", n.Op, n.OpPos)
+	}
+
+	op := n.Op
+	return func(m telegraf.Metric) (interface{}, error) {
+		a, err := x(m)
+		if err != nil {
+			return nil, err
+		}
+		b, err := y(m)
+		if err != nil {
+			return nil, err
+		}
+		return binary(op, a, b)
+	}, nil
+}
+
+// logical returns the evaluation of a && or || operator, where short is the
+// value that short-circuits the operator.  An error on one side is ignored if
+// the other side determines the result.
+func logical(x, y evalFunc, short bool) evalFunc {
+	operand := func(f evalFunc, m telegraf.Metric) (bool, error) {
+		v, err := f(m)
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, fmt.Errorf("invalid operand %v for logical operator", v)
+		}
+		return b, nil
+	}
+
+	return func(m telegraf.Metric) (interface{}, error) {
+		a, errA := operand(x, m)
+		if errA == nil && a == short {
+			return short, nil
+		}
+		b, errB := operand(y, m)
+		if errB == nil && b == short {
+			return short, nil
+		}
+		if errA != nil {
+			return nil, errA
+		}
+		if errB != nil {
+			return nil, errB
+		}
+		return !short, nil
+	}
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+func binary(op token.Token, a, b interface{}) (interface{}, error) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			switch op {
+			case token.ADD:
+				return a + b, nil
+			case token.EQL:
+				return a == b, nil
+			case token.NEQ:
+				return a != b, nil
+			case token.LSS:
+				return a < b, nil
+			case token.LEQ:
+				return a <= b, nil
+			case token.GTR:
+				return a > b, nil
+			case token.GEQ:
+				return a >= b, nil
+			}
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch op {
+			case token.EQL:
+				return a == b, nil
+			case token.NEQ:
+				return a != b, nil
+			}
+		}
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return binaryInt(op, a, b)
+		case float64:
+			return binaryFloat(op, float64(a), b)
+		}
+	case float64:
+		switch b := b.(type) {
+		case int64:
+			return binaryFloat(op, a, float64(b))
+		case float64:
+			return binaryFloat(op, a, b)
+		}
+	}
+	return nil, fmt.Errorf("invalid operands %#v and %#v for %s", a, b, op)
+}
+
+func binaryInt(op token.Token, a, b int64) (interface{}, error) {
+	switch op {
+	case token.QUO:
+		if b == 0 {
+			return nil, errDivisionByZero
+		}
+		return float64(a) / float64(b), nil
+	case token.REM:
+		if b == 0 {
+			return nil, errDivisionByZero
+		}
+		return a % b, nil
+	case token.ADD:
+		return a + b, nil
+	case token.SUB:
+		return a - b, nil
+	case token.MUL:
+		return a * b, nil
+	case token.EQL:
+		return a == b, nil
+	case token.NEQ:
+		return a != b, nil
+	case token.LSS:
+		return a < b, nil
+	case token.LEQ:
+		return a <= b, nil
+	case token.GTR:
+		return a > b, nil
+	case token.GEQ:
+		return a >= b, nil
+	}
+	return nil, fmt.Errorf("invalid operator %s for integers", op)
+}
+
+func binaryFloat(op token.Token, a, b float64) (interface{}, error) {
+	switch op {
+	case token.QUO:
+		if b == 0 {
+			return nil, errDivisionByZero
+		}
+		return a / b, nil
+	case token.ADD:
+		return a + b, nil
+	case token.SUB:
+		return a - b, nil
+	case token.MUL:
+		return a * b, nil
+	case token.EQL:
+		return a == b, nil
+	case token.NEQ:
+		return a != b, nil
+	case token.LSS:
+		return a < b, nil
+	case token.LEQ:
+		return a <= b, nil
+	case token.GTR:
+		return a > b, nil
+	case token.GEQ:
+		return a >= b, nil
+	}
+	return nil, fmt.Errorf("invalid operator %s for floats", op)
+}
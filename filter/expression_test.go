@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpression(t *testing.T) {
+	m := metric.New(
+		"cpu",
+		map[string]string{"region": "eu", "host-name": "localhost"},
+		map[string]interface{}{
+			"value":  int64(150),
+			"usage":  42.5,
+			"count":  uint64(3),
+			"status": "ok",
+			"up":     true,
+		},
+		time.Unix(0, 0),
+	)
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{expr: `fields.value > 100 && tags.region == "eu"`, expected: true},
+		{expr: `fields.value > 100 && tags.region == "us"`, expected: false},
+		{expr: `name == "cpu"`, expected: true},
+		{expr: `tags["host-name"] == "localhost"`, expected: true},
+		{expr: `fields.usage >= 42.5 && fields.usage < 50`, expected: true},
+		{expr: `fields.value / fields.count == 50`, expected: true},
+		{expr: `fields.value % 4 == 2`, expected: true},
+		{expr: `-fields.usage < 0`, expected: true},
+		{expr: `fields.status != "ok" || !fields.up`, expected: false},
+		{expr: `(fields.value + 50) * 2 == 400`, expected: true},
+		{expr: `name + "_" + tags.region == "cpu_eu"`, expected: true},
+		{expr: `fields.up == true`, expected: true},
+		// Missing fields do not matter if the other side decides the result
+		{expr: `fields.missing > 1 || fields.up`, expected: true},
+		{expr: `fields.missing > 1 && false`, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := CompileExpression(tt.expr)
+			require.NoError(t, err)
+			require.Equal(t, tt.expr, e.String())
+
+			result, err := e.Eval(m)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExpressionEvalErrors(t *testing.T) {
+	m := metric.New(
+		"cpu",
+		map[string]string{"region": "eu"},
+		map[string]interface{}{"value": int64(150)},
+		time.Unix(0, 0),
+	)
+
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: `fields.missing > 1`, err: `field "missing" not found`},
+		{expr: `tags.missing == "eu" && true`, err: `tag "missing" not found`},
+		{expr: `fields.value + 1`, err: "expression result 151 is not a boolean"},
+		{expr: `fields.value == "150"`, err: `invalid operands 150 and "150" for ==`},
+		{expr: `fields.value / 0 > 1`, err: "division by zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := CompileExpression(tt.expr)
+			require.NoError(t, err)
+
+			_, err = e.Eval(m)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestCompileExpressionErrors(t *testing.T) {
+	tests := []string{
+		`fields.value >`,
+		`len(name) > 1`,
+		`unknown == 1`,
+		`fields.value & 1 == 1`,
+		`tags[name] == "eu"`,
+		`metric.fields.value > 1`,
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := CompileExpression(expr)
+			require.Error(t, err)
+		})
+	}
+}
@@ -26,6 +26,11 @@ var (
 	ErrTimeout             = errors.New("command timed out")
 	ErrorNotImplemented    = errors.New("not implemented yet")
 	ErrorVersionAlreadySet = errors.New("version has already been set")
+
+	// ErrSerialization is wrapped by the errors of the serializers of the
+	// outputs.  A batch failing to serialize can never be written, so it is
+	// dropped instead of retried.
+	ErrSerialization = errors.New("serialization of metrics failed")
 )
 
 // Set via the main module
@@ -0,0 +1,53 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket rate limiter refilled with 'rate' tokens per
+// second up to a capacity of 'burst' tokens.  Unlike the rateLimiter it does
+// not block itself, instead Reserve returns the time to wait.
+type TokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewTokenBucket returns a full token bucket refilled with rate tokens per
+// second up to burst tokens.
+func NewTokenBucket(rate float64, burst float64) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		now:    time.Now,
+	}
+}
+
+// Reserve takes n tokens from the bucket and returns the duration to wait
+// before they may be used.  Requests larger than the available tokens put the
+// bucket into debt, delaying following requests accordingly.
+func (b *TokenBucket) Reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
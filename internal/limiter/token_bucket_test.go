@@ -0,0 +1,33 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewTokenBucket(10, 20)
+	b.now = func() time.Time { return now }
+
+	// The bucket starts full
+	require.Equal(t, time.Duration(0), b.Reserve(15))
+	require.Equal(t, time.Duration(0), b.Reserve(5))
+
+	// Taking more tokens puts the bucket into debt
+	require.Equal(t, 500*time.Millisecond, b.Reserve(5))
+	require.Equal(t, 1500*time.Millisecond, b.Reserve(10))
+
+	// Refills at the rate
+	now = now.Add(1500 * time.Millisecond)
+	require.Equal(t, time.Duration(0), b.Reserve(0))
+	now = now.Add(time.Second)
+	require.Equal(t, time.Duration(0), b.Reserve(10))
+
+	// Does not refill beyond the burst
+	now = now.Add(time.Hour)
+	require.Equal(t, time.Duration(0), b.Reserve(20))
+	require.Equal(t, 100*time.Millisecond, b.Reserve(1))
+}
@@ -0,0 +1,76 @@
+package templating
+
+import (
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// FuncMap contains the helper functions available in Go text templates
+// rendering metrics.
+var FuncMap = template.FuncMap{
+	"formatTime": FormatTime,
+	"join":       strings.Join,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"quote":      strconv.Quote,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+}
+
+// FormatTime formats the time as "unix", "unix_ms", "unix_us" or "unix_ns"
+// timestamp, or according to the Go time layout in UTC.
+func FormatTime(format string, t time.Time) string {
+	switch format {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix_ms":
+		return strconv.FormatInt(t.UnixNano()/1e6, 10)
+	case "unix_us":
+		return strconv.FormatInt(t.UnixNano()/1e3, 10)
+	case "unix_ns":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.UTC().Format(format)
+	}
+}
+
+// Metric exposes a metric to Go text templates.
+type Metric struct {
+	metric telegraf.Metric
+}
+
+// NewMetric wraps the metric for use in a Go text template.
+func NewMetric(m telegraf.Metric) *Metric {
+	return &Metric{metric: m}
+}
+
+func (m *Metric) Name() string {
+	return m.metric.Name()
+}
+
+func (m *Metric) Tag(key string) string {
+	v, _ := m.metric.GetTag(key)
+	return v
+}
+
+func (m *Metric) Tags() map[string]string {
+	return m.metric.Tags()
+}
+
+func (m *Metric) Field(key string) interface{} {
+	v, _ := m.metric.GetField(key)
+	return v
+}
+
+func (m *Metric) Fields() map[string]interface{} {
+	return m.metric.Fields()
+}
+
+func (m *Metric) Time() time.Time {
+	return m.metric.Time()
+}
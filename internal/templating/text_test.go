@@ -0,0 +1,18 @@
+package templating
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatTime(t *testing.T) {
+	ts := time.Unix(1600000000, 123456789).In(time.FixedZone("CEST", 2*60*60))
+
+	require.Equal(t, "1600000000", FormatTime("unix", ts))
+	require.Equal(t, "1600000000123", FormatTime("unix_ms", ts))
+	require.Equal(t, "1600000000123456", FormatTime("unix_us", ts))
+	require.Equal(t, "1600000000123456789", FormatTime("unix_ns", ts))
+	require.Equal(t, "2020-09-13T12:26:40Z", FormatTime(time.RFC3339, ts))
+}
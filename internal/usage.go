@@ -12,11 +12,19 @@ Usage:
 The commands & flags are:
 
   config              print out full sample configuration to stdout
+  config check        validate the configuration and report all problems found
   version             print the version to stdout
+  secrets list [id]   list the keys of the secrets in all or the given secret store
+  secrets get <id> <key>
+                      print the secret stored for the key
+  secrets set <id> <key> [value]
+                      store the secret for the key, read from stdin if no value is given
 
   --aggregator-filter <filter>   filter the aggregators to enable, separator is :
   --config <file>                configuration file to load
   --config-directory <directory> directory containing additional *.conf files
+  --config-directory-formats     formats besides TOML of the files loaded from config
+                                 directories, separator is :  Valid values are 'yaml' and 'json'
   --watch-config                 Telegraf will restart on local config changes. Monitor changes 
                                  using either fs notifications or polling.  Valid values: 'inotify' or 'poll'. 
                                  Monitoring is off by default.
@@ -30,6 +38,8 @@ The commands & flags are:
   --output-list                  print available output plugins.
   --pidfile <file>               file to write our pid to
   --pprof-addr <address>         pprof address to listen on, don't activate pprof if empty
+  --print-effective-config       print the configuration as loaded, including the defaults
+                                 of the plugins, with secrets masked and exit
   --processor-filter <filter>    filter the processors to enable, separator is :
   --quiet                        run in quiet mode
   --section-filter               filter config sections to output, separator is :
@@ -40,7 +50,11 @@ The commands & flags are:
   --test                         enable test mode: gather metrics once and print them
   --test-wait                    wait up to this many seconds for service
                                  inputs to complete in test or once mode
+  --test-pipeline                enable pipeline test mode: gather metrics once, run them
+                                 through processors and aggregators and print what each
+                                 output would write, without connecting the outputs
   --usage <plugin>               print usage for a plugin, ie, 'telegraf --usage mysql'
+  --validate                     validate the configuration, report all problems and exit
   --version                      display the version and exit
 
 Examples:
@@ -54,6 +68,15 @@ Examples:
   # run a single telegraf collection, outputting metrics to stdout
   telegraf --config telegraf.conf --test
 
+  # show what each output would write for a single collection
+  telegraf --config telegraf.conf --test-pipeline
+
+  # check a config file and its config directory for problems
+  telegraf --config telegraf.conf --config-directory telegraf.d config check
+
+  # store a password in the secret store "local" defined in the config file
+  telegraf --config telegraf.conf secrets set local db_password
+
   # run telegraf with all plugins defined in config file
   telegraf --config telegraf.conf
 
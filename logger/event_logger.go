@@ -8,7 +8,6 @@ import (
 	"log"
 	"strings"
 
-	"github.com/influxdata/wlog"
 	"golang.org/x/sys/windows/svc/eventlog"
 )
 
@@ -24,6 +23,10 @@ type eventLogger struct {
 }
 
 func (t *eventLogger) Write(b []byte) (n int, err error) {
+	if !parseEntry(b).enabled() {
+		return len(b), nil
+	}
+
 	loc := prefixRegex.FindIndex(b)
 	n = len(b)
 	if loc == nil {
@@ -48,7 +51,7 @@ type eventLoggerCreator struct {
 }
 
 func (e *eventLoggerCreator) CreateLogger(config LogConfig) (io.Writer, error) {
-	return wlog.NewWriter(&eventLogger{logger: e.logger}), nil
+	return &eventLogger{logger: e.logger}, nil
 }
 
 func RegisterEventLogger(name string) error {
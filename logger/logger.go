@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal/rotate"
+	"github.com/influxdata/telegraf/models"
 	"github.com/influxdata/wlog"
 )
 
@@ -21,14 +24,21 @@ const (
 	LogTargetStderr = "stderr"
 )
 
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
 // LogConfig contains the log configuration settings
 type LogConfig struct {
 	// will set the log level to DEBUG
 	Debug bool
 	//will set the log level to ERROR
 	Quiet bool
-	//stderr, stdout, file or eventlog (Windows only)
+	//stderr, stdout, file, syslog or eventlog (Windows only)
 	LogTarget string
+	// format of the log lines, "text" or "json"
+	LogFormat string
 	// will direct the logging output to a file. Empty string is
 	// interpreted as stderr. If there is an error opening the file the
 	// logger will fallback to stderr
@@ -57,22 +67,38 @@ func registerLogger(name string, loggerCreator LoggerCreator) {
 }
 
 type telegrafLog struct {
-	writer         io.Writer
 	internalWriter io.Writer
 	timezone       *time.Location
+	format         string
 }
 
 func (t *telegrafLog) Write(b []byte) (n int, err error) {
 	var line []byte
 	timeToPrint := time.Now().In(t.timezone)
 
+	entry := parseEntry(b)
+	if !entry.enabled() {
+		return len(b), nil
+	}
+
+	if t.format == LogFormatJSON {
+		entry.Time = timeToPrint.Format(time.RFC3339)
+		if line, err = json.Marshal(entry); err != nil {
+			return 0, err
+		}
+		if _, err = t.internalWriter.Write(append(line, '\n')); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
 	if !prefixRegex.Match(b) {
 		line = append([]byte(timeToPrint.Format(time.RFC3339)+" I! "), b...)
 	} else {
 		line = append([]byte(timeToPrint.Format(time.RFC3339)+" "), b...)
 	}
 
-	return t.writer.Write(line)
+	return t.internalWriter.Write(line)
 }
 
 func (t *telegrafLog) Close() error {
@@ -101,13 +127,92 @@ func newTelegrafWriter(w io.Writer, c LogConfig) (io.Writer, error) {
 		return nil, errors.New("error while setting logging timezone: " + err.Error())
 	}
 
+	if err := checkFormat(c.LogFormat); err != nil {
+		return nil, err
+	}
+
 	return &telegrafLog{
-		writer:         wlog.NewWriter(w),
 		internalWriter: w,
 		timezone:       tz,
+		format:         c.LogFormat,
 	}, nil
 }
 
+// checkFormat returns an error for unknown log formats.
+func checkFormat(format string) error {
+	switch format {
+	case "", LogFormatText, LogFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unsupported log format %q", format)
+}
+
+// logEntry is a log line split into its parts.
+type logEntry struct {
+	Time       string `json:"time,omitempty"`
+	Level      string `json:"level"`
+	Source     string `json:"source,omitempty"`
+	PluginType string `json:"plugin_type,omitempty"`
+	Plugin     string `json:"plugin,omitempty"`
+	Alias      string `json:"alias,omitempty"`
+	Message    string `json:"msg"`
+
+	name  string
+	level wlog.Level
+}
+
+var levelNames = map[wlog.Level]string{
+	wlog.DEBUG: "debug",
+	wlog.INFO:  "info",
+	wlog.WARN:  "warn",
+	wlog.ERROR: "error",
+}
+
+// enabled returns whether the entry is logged at the agent-wide log level, or
+// at a lower level overridden by a plugin logging with the name.  The plugin
+// loggers already filtered their messages at their own level.
+func (e *logEntry) enabled() bool {
+	level := wlog.LogLevel()
+	if l, ok := models.LogLevel(e.name); ok && l < level {
+		level = l
+	}
+	return e.level >= level
+}
+
+// parseEntry splits a log line of the form "E! [inputs.cpu::alias] message"
+// into its parts.  Lines without a level are logged at info level.  The
+// bracketed name is either a plugin, split into type, name and alias, or
+// another source like the agent.
+func parseEntry(b []byte) *logEntry {
+	line := strings.TrimRight(string(b), "\r\n")
+	entry := &logEntry{level: wlog.INFO}
+	if prefixRegex.MatchString(line) {
+		entry.level = wlog.Levels[line[0]]
+		line = strings.TrimLeft(line[2:], " ")
+	}
+	entry.Level = levelNames[entry.level]
+
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end > 0 {
+			name := line[1:end]
+			line = strings.TrimLeft(line[end+1:], " ")
+			entry.name = name
+
+			var alias string
+			if i := strings.Index(name, "::"); i >= 0 {
+				name, alias = name[:i], name[i+2:]
+			}
+			if i := strings.Index(name, "."); i > 0 {
+				entry.PluginType, entry.Plugin, entry.Alias = name[:i], name[i+1:], alias
+			} else {
+				entry.Source = name
+			}
+		}
+	}
+	entry.Message = line
+	return entry
+}
+
 // SetupLogging configures the logging output.
 func SetupLogging(config LogConfig) {
 	newLogWriter(config)
@@ -158,7 +263,14 @@ func newLogWriter(config LogConfig) io.Writer {
 	}
 	var logWriter io.Writer
 	if logCreator, ok := loggerRegistry[config.LogTarget]; ok {
-		logWriter, _ = logCreator.CreateLogger(config)
+		var err error
+		if logWriter, err = logCreator.CreateLogger(config); err != nil {
+			log.Printf("E! Unable to log to %s (%s), using stderr", config.LogTarget, err)
+			config.LogTarget = LogTargetStderr
+			if checkFormat(config.LogFormat) != nil {
+				config.LogFormat = LogFormatText
+			}
+		}
 	}
 	if logWriter == nil {
 		logWriter, _ = (&telegrafLogCreator{}).CreateLogger(config)
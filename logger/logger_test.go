@@ -2,13 +2,18 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/wlog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -133,6 +138,86 @@ func TestLogTargetSettings(t *testing.T) {
 	assert.Equal(t, logger.internalWriter, os.Stderr)
 }
 
+func TestWriteLogAsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newTelegrafWriter(&buf, LogConfig{LogFormat: LogFormatJSON})
+	require.NoError(t, err)
+
+	wlog.SetLevel(wlog.INFO)
+	_, err = w.Write([]byte("E! [inputs.cpu::mycpu] collection failed\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("D! [agent] not logged\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("I! [agent] starting\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("no level\n"))
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var entries []map[string]string
+	for _, line := range lines {
+		var entry map[string]string
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		require.NotEmpty(t, entry["time"])
+		delete(entry, "time")
+		entries = append(entries, entry)
+	}
+	require.Equal(t, []map[string]string{
+		{"level": "error", "plugin_type": "inputs", "plugin": "cpu", "alias": "mycpu", "msg": "collection failed"},
+		{"level": "info", "source": "agent", "msg": "starting"},
+		{"level": "info", "msg": "no level"},
+	}, entries)
+}
+
+func TestPluginLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newTelegrafWriter(&buf, LogConfig{})
+	require.NoError(t, err)
+	log.SetOutput(w)
+	defer log.SetOutput(os.Stderr)
+
+	snmp := models.NewRunningInput(&mockInput{}, &models.InputConfig{Name: "snmp", LogLevel: "debug"})
+	defer snmp.Log().(*models.Logger).ReleaseLevel()
+	cpu := models.NewRunningInput(&mockInput{}, &models.InputConfig{Name: "cpu", LogLevel: "error"})
+	defer cpu.Log().(*models.Logger).ReleaseLevel()
+	// An unaliased instance of the same plugin keeps the agent-wide level.
+	other := models.NewRunningInput(&mockInput{}, &models.InputConfig{Name: "snmp"})
+
+	wlog.SetLevel(wlog.INFO)
+	snmp.Log().Debug("logged")
+	other.Log().Debug("not logged")
+	other.Log().Info("logged by other")
+	cpu.Log().Info("not logged")
+	cpu.Log().Error("logged")
+	log.Printf("D! [inputs.mem] not logged")
+	log.Printf("I! [agent] logged")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	require.True(t, strings.HasSuffix(lines[0], "Z D! [inputs.snmp] logged"))
+	require.True(t, strings.HasSuffix(lines[1], "Z I! [inputs.snmp] logged by other"))
+	require.True(t, strings.HasSuffix(lines[2], "Z E! [inputs.cpu] logged"))
+	require.True(t, strings.HasSuffix(lines[3], "Z I! [agent] logged"))
+}
+
+func TestFilteredWriteLength(t *testing.T) {
+	w, err := newTelegrafWriter(&bytes.Buffer{}, LogConfig{})
+	require.NoError(t, err)
+
+	wlog.SetLevel(wlog.INFO)
+	line := []byte("D! [inputs.mem] not logged\n")
+	n, err := w.Write(line)
+	require.NoError(t, err)
+	require.Equal(t, len(line), n)
+}
+
+func TestInvalidLogFormat(t *testing.T) {
+	_, err := newTelegrafWriter(&bytes.Buffer{}, LogConfig{LogFormat: "xml"})
+	require.EqualError(t, err, `unsupported log format "xml"`)
+}
+
 func BenchmarkTelegrafLogWrite(b *testing.B) {
 	var msg = []byte("test")
 	var buf bytes.Buffer
@@ -146,6 +231,20 @@ func BenchmarkTelegrafLogWrite(b *testing.B) {
 	}
 }
 
+type mockInput struct{}
+
+func (m *mockInput) SampleConfig() string {
+	return ""
+}
+
+func (m *mockInput) Description() string {
+	return ""
+}
+
+func (m *mockInput) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
 func createBasicLogConfig(filename string) LogConfig {
 	return LogConfig{
 		Logfile:             filename,
@@ -0,0 +1,81 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"log/syslog"
+	"strings"
+
+	"github.com/influxdata/wlog"
+)
+
+// LogTargetSyslog logs to the local syslog daemon.  On systems running
+// systemd the messages are received by journald.
+const LogTargetSyslog = "syslog"
+
+type syslogLogger struct {
+	writer *syslog.Writer
+	format string
+}
+
+func (s *syslogLogger) Write(b []byte) (int, error) {
+	entry := parseEntry(b)
+	if !entry.enabled() {
+		return len(b), nil
+	}
+
+	// The level is conveyed by the priority and syslog adds the time.
+	msg := strings.TrimRight(string(b), "\r\n")
+	if prefixRegex.MatchString(msg) {
+		msg = strings.TrimLeft(msg[2:], " ")
+	}
+	if s.format == LogFormatJSON {
+		octets, err := json.Marshal(entry)
+		if err != nil {
+			return 0, err
+		}
+		msg = string(octets)
+	}
+
+	var err error
+	switch entry.level {
+	case wlog.DEBUG:
+		err = s.writer.Debug(msg)
+	case wlog.WARN:
+		err = s.writer.Warning(msg)
+	case wlog.ERROR:
+		err = s.writer.Err(msg)
+	default:
+		err = s.writer.Info(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (s *syslogLogger) Close() error {
+	return s.writer.Close()
+}
+
+type syslogLoggerCreator struct {
+}
+
+func (s *syslogLoggerCreator) CreateLogger(config LogConfig) (io.Writer, error) {
+	if err := checkFormat(config.LogFormat); err != nil {
+		return nil, err
+	}
+
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "telegraf")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogLogger{writer: writer, format: config.LogFormat}, nil
+}
+
+func init() {
+	registerLogger(LogTargetSyslog, &syslogLoggerCreator{})
+}
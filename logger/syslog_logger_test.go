@@ -0,0 +1,55 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/wlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogLogger(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	writer, err := syslog.Dial("unixgram", addr, syslog.LOG_DAEMON|syslog.LOG_INFO, "telegraf")
+	require.NoError(t, err)
+
+	read := func() string {
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	wlog.SetLevel(wlog.INFO)
+	logger := &syslogLogger{writer: writer}
+	_, err = logger.Write([]byte("E! [outputs.file] write failed\n"))
+	require.NoError(t, err)
+
+	// LOG_DAEMON|LOG_ERR
+	msg := read()
+	require.True(t, strings.HasPrefix(msg, "<27>"), msg)
+	require.True(t, strings.HasSuffix(msg, "telegraf["+strconv.Itoa(os.Getpid())+"]: [outputs.file] write failed\n"), msg)
+
+	logger.format = LogFormatJSON
+	_, err = logger.Write([]byte("W! [inputs.cpu::mycpu] slow\n"))
+	require.NoError(t, err)
+
+	// LOG_DAEMON|LOG_WARNING
+	msg = read()
+	require.True(t, strings.HasPrefix(msg, "<28>"), msg)
+	require.True(t, strings.HasSuffix(msg, `{"level":"warn","plugin_type":"inputs","plugin":"cpu","alias":"mycpu","msg":"slow"}`+"\n"), msg)
+
+	require.NoError(t, logger.Close())
+}
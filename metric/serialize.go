@@ -0,0 +1,54 @@
+package metric
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// serializedMetric is the on-disk representation of a metric used by
+// ToBytes and FromBytes.
+type serializedMetric struct {
+	Name   string
+	Tags   []*telegraf.Tag
+	Fields []*telegraf.Field
+	Time   time.Time
+	Type   telegraf.ValueType
+}
+
+// ToBytes encodes the metric into a binary representation that can be decoded
+// again using FromBytes.  Any tracking information is not preserved.
+func ToBytes(m telegraf.Metric) ([]byte, error) {
+	sm := serializedMetric{
+		Name:   m.Name(),
+		Tags:   m.TagList(),
+		Fields: m.FieldList(),
+		Time:   m.Time(),
+		Type:   m.Type(),
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&sm); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FromBytes decodes a metric previously encoded with ToBytes.
+func FromBytes(b []byte) (telegraf.Metric, error) {
+	var sm serializedMetric
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&sm); err != nil {
+		return nil, err
+	}
+
+	m := &metric{
+		name:   sm.Name,
+		tags:   sm.Tags,
+		fields: sm.Fields,
+		tm:     sm.Time,
+		tp:     sm.Type,
+	}
+	return m, nil
+}
@@ -0,0 +1,38 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	m := New(
+		"cpu",
+		map[string]string{
+			"host": "localhost",
+		},
+		map[string]interface{}{
+			"float":  42.0,
+			"int":    int64(42),
+			"uint":   uint64(42),
+			"string": "foo",
+			"bool":   true,
+		},
+		time.Unix(42, 42),
+		telegraf.Counter,
+	)
+
+	b, err := ToBytes(m)
+	require.NoError(t, err)
+
+	actual, err := FromBytes(b)
+	require.NoError(t, err)
+	require.Equal(t, m.Name(), actual.Name())
+	require.Equal(t, m.TagList(), actual.TagList())
+	require.Equal(t, m.FieldList(), actual.FieldList())
+	require.True(t, m.Time().Equal(actual.Time()))
+	require.Equal(t, telegraf.Counter, actual.Type())
+}
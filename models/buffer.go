@@ -12,39 +12,62 @@ var (
 	AgentMetricsDropped = selfstat.Register("agent", "metrics_dropped", map[string]string{})
 )
 
-// Buffer stores metrics in a circular buffer.
-type Buffer struct {
-	sync.Mutex
-	buf   []telegraf.Metric
-	first int // index of the first/oldest metric
-	last  int // one after the index of the last/newest metric
-	size  int // number of metrics currently in the buffer
-	cap   int // the capacity of the buffer
+// MetricBuffer is the interface implemented by the buffers holding the
+// metrics of an output until they are written.
+type MetricBuffer interface {
+	// Len returns the number of metrics currently in the buffer.
+	Len() int
 
-	batchFirst int // index of the first metric in the batch
-	batchSize  int // number of metrics currently in the batch
+	// Add adds metrics to the buffer and returns number of dropped metrics.
+	Add(metrics ...telegraf.Metric) int
+
+	// Batch returns a slice containing up to batchSize of the oldest metrics
+	// in the buffer.
+	Batch(batchSize int) []telegraf.Metric
 
+	// Accept marks the batch, acquired from Batch(), as successfully written.
+	Accept(batch []telegraf.Metric)
+
+	// Reject returns the batch, acquired from Batch(), to the buffer and
+	// marks it as unsent.
+	Reject(batch []telegraf.Metric)
+
+	// Drop removes the batch, acquired from Batch(), from the buffer without
+	// writing it and counts the metrics as dropped for the given reason.
+	// Drop can follow Accept to drop the metrics of the batch not written.
+	Drop(batch []telegraf.Metric, reason string)
+
+	// Close releases any resources held by the buffer.
+	Close() error
+}
+
+// Reasons for dropping metrics from a buffer.
+const (
+	dropBufferOverflow   = "buffer_overflow"
+	dropRetriesExhausted = "retries_exhausted"
+	dropSerialization    = "serialization"
+)
+
+// BufferStats holds the internal statistics shared by all buffer types.
+type BufferStats struct {
 	MetricsAdded   selfstat.Stat
 	MetricsWritten selfstat.Stat
 	MetricsDropped selfstat.Stat
 	BufferSize     selfstat.Stat
 	BufferLimit    selfstat.Stat
+
+	// onDrop is called with each dropped metric before it is rejected.
+	onDrop func(metric telegraf.Metric, reason string)
 }
 
-// NewBuffer returns a new empty Buffer with the given capacity.
-func NewBuffer(name string, alias string, capacity int) *Buffer {
+// NewBufferStats registers the statistics of the buffer for the given output.
+func NewBufferStats(name string, alias string, capacity int) BufferStats {
 	tags := map[string]string{"output": name}
 	if alias != "" {
 		tags["alias"] = alias
 	}
 
-	b := &Buffer{
-		buf:   make([]telegraf.Metric, capacity),
-		first: 0,
-		last:  0,
-		size:  0,
-		cap:   capacity,
-
+	stats := BufferStats{
 		MetricsAdded: selfstat.Register(
 			"write",
 			"metrics_added",
@@ -71,8 +94,56 @@ func NewBuffer(name string, alias string, capacity int) *Buffer {
 			tags,
 		),
 	}
-	b.BufferSize.Set(int64(0))
-	b.BufferLimit.Set(int64(capacity))
+	stats.BufferSize.Set(int64(0))
+	stats.BufferLimit.Set(int64(capacity))
+	return stats
+}
+
+func (s *BufferStats) metricAdded() {
+	s.MetricsAdded.Incr(1)
+}
+
+func (s *BufferStats) metricWritten(metric telegraf.Metric) {
+	AgentMetricsWritten.Incr(1)
+	s.MetricsWritten.Incr(1)
+	metric.Accept()
+}
+
+func (s *BufferStats) metricDropped(metric telegraf.Metric, reason string) {
+	AgentMetricsDropped.Incr(1)
+	s.MetricsDropped.Incr(1)
+	if s.onDrop != nil {
+		s.onDrop(metric, reason)
+	}
+	metric.Reject()
+}
+
+// Buffer stores metrics in a circular buffer.
+type Buffer struct {
+	sync.Mutex
+	BufferStats
+
+	buf   []telegraf.Metric
+	first int // index of the first/oldest metric
+	last  int // one after the index of the last/newest metric
+	size  int // number of metrics currently in the buffer
+	cap   int // the capacity of the buffer
+
+	batchFirst int // index of the first metric in the batch
+	batchSize  int // number of metrics currently in the batch
+}
+
+// NewBuffer returns a new empty Buffer with the given capacity.
+func NewBuffer(name string, alias string, capacity int) *Buffer {
+	b := &Buffer{
+		BufferStats: NewBufferStats(name, alias, capacity),
+
+		buf:   make([]telegraf.Metric, capacity),
+		first: 0,
+		last:  0,
+		size:  0,
+		cap:   capacity,
+	}
 	return b
 }
 
@@ -88,27 +159,11 @@ func (b *Buffer) length() int {
 	return min(b.size+b.batchSize, b.cap)
 }
 
-func (b *Buffer) metricAdded() {
-	b.MetricsAdded.Incr(1)
-}
-
-func (b *Buffer) metricWritten(metric telegraf.Metric) {
-	AgentMetricsWritten.Incr(1)
-	b.MetricsWritten.Incr(1)
-	metric.Accept()
-}
-
-func (b *Buffer) metricDropped(metric telegraf.Metric) {
-	AgentMetricsDropped.Incr(1)
-	b.MetricsDropped.Incr(1)
-	metric.Reject()
-}
-
 func (b *Buffer) add(m telegraf.Metric) int {
 	dropped := 0
 	// Check if Buffer is full
 	if b.size == b.cap {
-		b.metricDropped(b.buf[b.last])
+		b.metricDropped(b.buf[b.last], dropBufferOverflow)
 		dropped++
 
 		if b.batchSize > 0 {
@@ -187,6 +242,20 @@ func (b *Buffer) Accept(batch []telegraf.Metric) {
 	b.BufferSize.Set(int64(b.length()))
 }
 
+// Drop removes the batch, acquired from Batch(), from the buffer without
+// writing it and counts the metrics as dropped for the given reason.
+func (b *Buffer) Drop(batch []telegraf.Metric, reason string) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range batch {
+		b.metricDropped(m, reason)
+	}
+
+	b.resetBatch()
+	b.BufferSize.Set(int64(b.length()))
+}
+
 // Reject returns the batch, acquired from Batch(), to the buffer and marks it
 // as unsent.
 func (b *Buffer) Reject(batch []telegraf.Metric) {
@@ -209,7 +278,7 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 	// Copy metrics from the batch back into the buffer
 	for i := range batch {
 		if i < skip {
-			b.metricDropped(batch[i])
+			b.metricDropped(batch[i], dropBufferOverflow)
 		} else {
 			b.buf[re] = batch[i]
 			re = b.next(re)
@@ -220,6 +289,11 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 	b.BufferSize.Set(int64(b.length()))
 }
 
+// Close is a no-op for the in-memory buffer.
+func (b *Buffer) Close() error {
+	return nil
+}
+
 // next returns the next index with wrapping.
 func (b *Buffer) next(index int) int {
 	index++
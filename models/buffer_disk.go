@@ -0,0 +1,555 @@
+package models
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+const (
+	// Size after which a new write-ahead-log segment is started.
+	walSegmentMaxBytes = 4 * 1024 * 1024
+
+	// Size of the header preceding each entry of a segment, consisting of
+	// the length and the CRC32 checksum of the entry.
+	walHeaderSize = 8
+
+	// Number of added metrics after which the write-ahead-log is synced to
+	// disk.  The log is synced before each batch in any case.
+	walSyncCount = 1000
+
+	walSegmentExt = ".wal"
+	walFrontFile  = "front"
+)
+
+// walSegment is a single file of the write-ahead-log.  Each entry consists of
+// the header followed by the serialized metric.
+type walSegment struct {
+	path    string
+	first   uint64  // index of the first entry in the segment
+	offsets []int64 // file offset of each entry in the segment
+	size    int64   // size of the segment file in bytes
+}
+
+func (s *walSegment) contains(index uint64) bool {
+	return index >= s.first && index < s.first+uint64(len(s.offsets))
+}
+
+// DiskBuffer stores metrics in a write-ahead-log on disk, so they survive
+// restarts of the agent.  Like the in-memory Buffer it holds up to capacity
+// metrics, dropping the oldest ones on overflow.
+type DiskBuffer struct {
+	sync.Mutex
+	BufferStats
+
+	path     string
+	segments []*walSegment
+	tail     *os.File // file of the last segment, opened for appending
+
+	front uint64 // index of the oldest entry not yet accepted
+	first uint64 // index of the oldest entry not part of the current batch
+	last  uint64 // index one after the newest entry
+
+	batchSize int // number of entries in the current batch
+	cap       int // maximum number of entries held
+
+	unsynced []telegraf.Metric // added metrics not yet synced to disk
+}
+
+// NewDiskBuffer opens or creates the write-ahead-log at the given path and
+// returns a buffer containing any metrics left over from previous runs.
+func NewDiskBuffer(name string, alias string, path string, capacity int) (*DiskBuffer, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, fmt.Errorf("creating buffer directory failed: %v", err)
+	}
+
+	b := &DiskBuffer{
+		BufferStats: NewBufferStats(name, alias, capacity),
+		path:        path,
+		cap:         capacity,
+	}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	b.trim()
+	b.BufferSize.Set(int64(b.length()))
+	return b, nil
+}
+
+// load reads the existing segments and restores the buffer position.
+func (b *DiskBuffer) load() error {
+	front, err := b.readFront()
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(b.path, "*"+walSegmentExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for i, fn := range files {
+		name := strings.TrimSuffix(filepath.Base(fn), walSegmentExt)
+		first, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid segment name %q: %v", fn, err)
+		}
+		s := &walSegment{path: fn, first: first}
+		if err := s.scan(); err != nil {
+			return fmt.Errorf("reading segment %q failed: %v", fn, err)
+		}
+		next := s.first + uint64(len(s.offsets))
+
+		// Segments already completely accepted are not needed anymore
+		if next <= front && i < len(files)-1 {
+			if err := os.Remove(fn); err != nil {
+				return err
+			}
+			continue
+		}
+		b.segments = append(b.segments, s)
+	}
+
+	if len(b.segments) == 0 {
+		if err := b.newSegment(front); err != nil {
+			return err
+		}
+	} else {
+		s := b.segments[len(b.segments)-1]
+		b.tail, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	first := b.segments[0].first
+	last := b.segments[len(b.segments)-1]
+	b.last = last.first + uint64(len(last.offsets))
+	if front < first {
+		front = first
+	}
+	if front > b.last {
+		front = b.last
+	}
+	b.front = front
+	b.first = front
+	return nil
+}
+
+// scan reads the offsets of all valid entries in the segment and truncates
+// the file after the last valid entry.
+func (s *walSegment) scan() error {
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		data, err := readEntry(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			// A partially written or corrupted entry, most likely due to a
+			// crash while writing, so drop it and anything after it.
+			if err := f.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		s.offsets = append(s.offsets, offset)
+		offset += int64(walHeaderSize + len(data))
+	}
+	s.size = offset
+	return nil
+}
+
+// newSegment closes the current tail and starts a new segment beginning with
+// the given index.
+func (b *DiskBuffer) newSegment(first uint64) error {
+	if b.tail != nil {
+		if err := b.tail.Sync(); err != nil {
+			return err
+		}
+		if err := b.tail.Close(); err != nil {
+			return err
+		}
+	}
+
+	fn := filepath.Join(b.path, fmt.Sprintf("%020d%s", first, walSegmentExt))
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	b.tail = f
+	b.segments = append(b.segments, &walSegment{path: fn, first: first})
+	return nil
+}
+
+// readFront returns the persisted index of the oldest entry not yet accepted.
+func (b *DiskBuffer) readFront() (uint64, error) {
+	buf, err := os.ReadFile(filepath.Join(b.path, walFrontFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	front, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid buffer position: %v", err)
+	}
+	return front, nil
+}
+
+// writeFront persists the index of the oldest entry not yet accepted.
+func (b *DiskBuffer) writeFront() error {
+	fn := filepath.Join(b.path, walFrontFile)
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(b.front, 10)), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// Len returns the number of metrics currently in the buffer.
+func (b *DiskBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.length()
+}
+
+func (b *DiskBuffer) length() int {
+	return int(b.last - b.front)
+}
+
+func (b *DiskBuffer) add(m telegraf.Metric) bool {
+	data, err := metric.ToBytes(m)
+	if err != nil {
+		return false
+	}
+
+	s := b.segments[len(b.segments)-1]
+	if s.size >= walSegmentMaxBytes {
+		b.sync()
+		if err := b.newSegment(b.last); err != nil {
+			return false
+		}
+		s = b.segments[len(b.segments)-1]
+	}
+
+	n, err := writeEntry(b.tail, data)
+	if err != nil {
+		// Remove any partially written entry so the segment stays readable
+		if err := b.tail.Truncate(s.size); err != nil {
+			return false
+		}
+		return false
+	}
+	s.offsets = append(s.offsets, s.size)
+	s.size += int64(n)
+	b.last++
+	b.metricAdded()
+	return true
+}
+
+// Add adds metrics to the buffer and returns number of dropped metrics.
+// Metrics are dropped if they cannot be written to disk or, once the buffer
+// is full, the oldest metrics are dropped.
+//
+// The log is synced to disk before each batch and after every walSyncCount
+// added metrics, not on each call.  The metrics read back from disk are plain
+// copies, so the tracking information of the added metrics is released once
+// they are synced.  Tracking inputs therefore see the metrics as delivered
+// when they are persisted in the buffer, not when the output has written them.
+func (b *DiskBuffer) Add(metrics ...telegraf.Metric) int {
+	b.Lock()
+	defer b.Unlock()
+
+	dropped := 0
+	for _, m := range metrics {
+		if !b.add(m) {
+			b.metricDropped(m, dropBufferOverflow)
+			dropped++
+			continue
+		}
+		b.unsynced = append(b.unsynced, m)
+		if len(b.unsynced) >= walSyncCount {
+			b.sync()
+		}
+	}
+	dropped += b.trim()
+
+	b.BufferSize.Set(int64(b.length()))
+	return dropped
+}
+
+// sync flushes the tail segment to disk and releases the tracking information
+// of the metrics added since the last sync.
+func (b *DiskBuffer) sync() {
+	if len(b.unsynced) == 0 {
+		return
+	}
+
+	// Without sync the metrics might still be lost on a crash, so reject
+	// the tracking to let the inputs deliver the metrics again.
+	synced := b.tail.Sync() == nil
+	for _, m := range b.unsynced {
+		if synced {
+			m.Accept()
+		} else {
+			m.Reject()
+		}
+	}
+	b.unsynced = nil
+}
+
+// trim drops the oldest entries exceeding the capacity of the buffer and
+// returns their number.  Entries of the current batch are resolved by the
+// output first, so trimming is postponed while a batch is outstanding.
+func (b *DiskBuffer) trim() int {
+	overflow := b.length() - b.cap
+	if b.cap <= 0 || overflow <= 0 || b.batchSize > 0 {
+		return 0
+	}
+
+	end := b.front + uint64(overflow)
+	for _, s := range b.segments {
+		if b.front >= end {
+			break
+		}
+		if !s.contains(b.front) {
+			continue
+		}
+		metrics, n := b.readSegment(s, b.front, end)
+		for _, m := range metrics {
+			b.metricDropped(m, dropBufferOverflow)
+		}
+		b.front += uint64(n)
+	}
+	b.first = b.front
+	if err := b.writeFront(); err == nil {
+		b.removeAccepted()
+	}
+	return overflow
+}
+
+// Batch returns a slice containing up to batchSize of the oldest metrics.
+// Metrics are ordered from oldest to newest in the batch.  Entries that cannot
+// be read back from disk are dropped.
+func (b *DiskBuffer) Batch(batchSize int) []telegraf.Metric {
+	b.Lock()
+	defer b.Unlock()
+
+	b.sync()
+
+	count := int(b.last - b.first)
+	if batchSize < count {
+		count = batchSize
+	}
+	out := make([]telegraf.Metric, 0, count)
+	if count <= 0 {
+		return out
+	}
+
+	end := b.first + uint64(count)
+	for _, s := range b.segments {
+		if b.first >= end {
+			break
+		}
+		if !s.contains(b.first) {
+			continue
+		}
+		metrics, n := b.readSegment(s, b.first, end)
+		out = append(out, metrics...)
+		b.first += uint64(n)
+		b.batchSize += n
+	}
+
+	// Nothing could be read, so skip the unreadable entries for good as the
+	// caller will neither accept nor reject an empty batch.
+	if len(out) == 0 {
+		b.front = b.first
+		b.batchSize = 0
+		if err := b.writeFront(); err == nil {
+			b.removeAccepted()
+		}
+		b.BufferSize.Set(int64(b.length()))
+	}
+	return out
+}
+
+// readSegment returns the metrics of the segment in the index range
+// [start, end) together with the number of consumed entries.
+func (b *DiskBuffer) readSegment(s *walSegment, start, end uint64) ([]telegraf.Metric, int) {
+	stop := s.first + uint64(len(s.offsets))
+	if end < stop {
+		stop = end
+	}
+	count := int(stop - start)
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		// The whole range is unreadable, so consume it to not block the
+		// buffer forever.
+		b.dropUnreadable(count)
+		return nil, count
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(s.offsets[start-s.first], io.SeekStart); err != nil {
+		b.dropUnreadable(count)
+		return nil, count
+	}
+
+	r := bufio.NewReader(f)
+	metrics := make([]telegraf.Metric, 0, count)
+	for i := 0; i < count; i++ {
+		data, err := readEntry(r)
+		if err != nil {
+			b.dropUnreadable(count - i)
+			break
+		}
+		m, err := metric.FromBytes(data)
+		if err != nil {
+			b.dropUnreadable(1)
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, count
+}
+
+func (b *DiskBuffer) dropUnreadable(count int) {
+	AgentMetricsDropped.Incr(int64(count))
+	b.MetricsDropped.Incr(int64(count))
+}
+
+// Accept marks the batch, acquired from Batch(), as successfully written.
+func (b *DiskBuffer) Accept(batch []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range batch {
+		b.metricWritten(m)
+	}
+
+	b.front = b.first
+	b.batchSize = 0
+	if err := b.writeFront(); err == nil {
+		b.removeAccepted()
+	}
+	b.trim()
+	b.BufferSize.Set(int64(b.length()))
+}
+
+// Drop removes the batch, acquired from Batch(), from the buffer without
+// writing it and counts the metrics as dropped for the given reason.
+func (b *DiskBuffer) Drop(batch []telegraf.Metric, reason string) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range batch {
+		b.metricDropped(m, reason)
+	}
+
+	b.front = b.first
+	b.batchSize = 0
+	if err := b.writeFront(); err == nil {
+		b.removeAccepted()
+	}
+	b.trim()
+	b.BufferSize.Set(int64(b.length()))
+}
+
+// removeAccepted deletes all segments, except the tail, whose entries were
+// all accepted.
+func (b *DiskBuffer) removeAccepted() {
+	for len(b.segments) > 1 {
+		s := b.segments[0]
+		if s.first+uint64(len(s.offsets)) > b.front {
+			break
+		}
+		if err := os.Remove(s.path); err != nil {
+			break
+		}
+		b.segments = b.segments[1:]
+	}
+}
+
+// Reject returns the batch, acquired from Batch(), to the buffer and marks it
+// as unsent.  The metrics are still on disk, so only the read position needs
+// to be reset.
+func (b *DiskBuffer) Reject(_ []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.first = b.front
+	b.batchSize = 0
+	b.trim()
+	b.BufferSize.Set(int64(b.length()))
+}
+
+// Close flushes the write-ahead-log to disk and closes the files.
+func (b *DiskBuffer) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.tail == nil {
+		return nil
+	}
+	b.sync()
+	if err := b.tail.Sync(); err != nil {
+		return err
+	}
+	err := b.tail.Close()
+	b.tail = nil
+	return err
+}
+
+func writeEntry(w io.Writer, data []byte) (int, error) {
+	buf := make([]byte, walHeaderSize+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[walHeaderSize:], data)
+	return w.Write(buf)
+}
+
+func readEntry(r io.Reader) ([]byte, error) {
+	var header [walHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return data, nil
+}
@@ -0,0 +1,292 @@
+package models
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func setupDisk(t *testing.T, path string) *DiskBuffer {
+	return setupDiskCapacity(t, path, 100)
+}
+
+func setupDiskCapacity(t *testing.T, path string, capacity int) *DiskBuffer {
+	b, err := NewDiskBuffer("test", "", path, capacity)
+	require.NoError(t, err)
+	b.MetricsAdded.Set(0)
+	b.MetricsWritten.Set(0)
+	b.MetricsDropped.Set(0)
+	return b
+}
+
+func TestDiskBuffer_LenEmpty(t *testing.T) {
+	b := setupDisk(t, t.TempDir())
+	defer b.Close()
+
+	require.Equal(t, 0, b.Len())
+}
+
+func TestDiskBuffer_BatchAccept(t *testing.T) {
+	b := setupDisk(t, t.TempDir())
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	require.Equal(t, 3, b.Len())
+
+	batch := b.Batch(2)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+		}, batch)
+	require.Equal(t, 3, b.Len())
+
+	b.Accept(batch)
+	require.Equal(t, 1, b.Len())
+	require.Equal(t, int64(2), b.MetricsWritten.Get())
+
+	batch = b.Batch(2)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+		}, batch)
+}
+
+func TestDiskBuffer_Reject(t *testing.T) {
+	b := setupDisk(t, t.TempDir())
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2))
+	batch := b.Batch(2)
+	b.Add(MetricTime(3))
+	b.Reject(batch)
+
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, int64(0), b.MetricsDropped.Get())
+
+	batch = b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+			MetricTime(3),
+		}, batch)
+}
+
+func TestDiskBuffer_Drop(t *testing.T) {
+	dir := t.TempDir()
+	b := setupDisk(t, dir)
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	batch := b.Batch(2)
+	b.Drop(batch, dropRetriesExhausted)
+
+	require.Equal(t, 1, b.Len())
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	require.Equal(t, int64(0), b.MetricsWritten.Get())
+	require.NoError(t, b.Close())
+
+	// Dropped metrics are not loaded again
+	b = setupDisk(t, dir)
+	defer b.Close()
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_Restart(t *testing.T) {
+	path := t.TempDir()
+
+	b := setupDisk(t, path)
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	b.Accept(b.Batch(1))
+	b.Batch(1) // unfinished batch must be sent again after restart
+	require.NoError(t, b.Close())
+
+	b = setupDisk(t, path)
+	defer b.Close()
+	require.Equal(t, 2, b.Len())
+
+	batch := b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(2),
+			MetricTime(3),
+		}, batch)
+}
+
+func TestDiskBuffer_TruncatedEntry(t *testing.T) {
+	path := t.TempDir()
+
+	b := setupDisk(t, path)
+	b.Add(MetricTime(1), MetricTime(2))
+	require.NoError(t, b.Close())
+
+	// Simulate a crash while writing the last entry
+	fn := b.segments[0].path
+	info, err := os.Stat(fn)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(fn, info.Size()-3))
+
+	b = setupDisk(t, path)
+	defer b.Close()
+	require.Equal(t, 1, b.Len())
+
+	b.Add(MetricTime(3))
+	batch := b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(3),
+		}, batch)
+}
+
+func TestDiskBuffer_RemoveAcceptedSegments(t *testing.T) {
+	path := t.TempDir()
+
+	b := setupDisk(t, path)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2))
+	require.NoError(t, b.newSegment(b.last))
+	b.Add(MetricTime(3))
+
+	files, err := filepath.Glob(filepath.Join(path, "*"+walSegmentExt))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	batch := b.Batch(2)
+	b.Accept(batch)
+
+	files, err = filepath.Glob(filepath.Join(path, "*"+walSegmentExt))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, 1, b.Len())
+}
+
+func TestDiskBuffer_AcceptTrackingOnSync(t *testing.T) {
+	var accept int
+	mm := &MockMetric{
+		Metric: Metric(),
+		AcceptF: func() {
+			accept++
+		},
+	}
+	b := setupDisk(t, t.TempDir())
+	defer b.Close()
+
+	// Syncing each added metric is too slow, so the log is synced before
+	// the next batch.
+	b.Add(mm)
+	require.Equal(t, 0, accept)
+
+	b.Batch(1)
+	require.Equal(t, 1, accept)
+}
+
+func TestDiskBuffer_RejectTrackingWithoutSync(t *testing.T) {
+	var accept, reject int
+	mm := &MockMetric{
+		Metric: Metric(),
+		AcceptF: func() {
+			accept++
+		},
+		RejectF: func() {
+			reject++
+		},
+	}
+	b := setupDisk(t, t.TempDir())
+	defer b.Close()
+
+	// Writing to a pipe succeeds but syncing it fails
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	go func() {
+		_, _ = io.Copy(io.Discard, r)
+	}()
+	require.NoError(t, b.tail.Close())
+	b.tail = w
+
+	require.Equal(t, 0, b.Add(mm))
+	b.sync()
+	require.Equal(t, 1, b.Len())
+	require.Equal(t, 0, accept)
+	require.Equal(t, 1, reject)
+}
+
+func TestDiskBuffer_SyncCount(t *testing.T) {
+	var accept int
+	b := setupDiskCapacity(t, t.TempDir(), 2*walSyncCount)
+	defer b.Close()
+
+	for i := 0; i < walSyncCount; i++ {
+		require.Equal(t, 0, accept)
+		b.Add(&MockMetric{
+			Metric: Metric(),
+			AcceptF: func() {
+				accept++
+			},
+		})
+	}
+	require.Equal(t, walSyncCount, accept)
+}
+
+func TestDiskBuffer_DropOldestOnOverflow(t *testing.T) {
+	path := t.TempDir()
+	b := setupDiskCapacity(t, path, 3)
+
+	require.Equal(t, 0, b.Add(MetricTime(1), MetricTime(2), MetricTime(3)))
+	require.Equal(t, 2, b.Add(MetricTime(4), MetricTime(5)))
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+
+	batch := b.Batch(3)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+			MetricTime(5),
+		}, batch)
+
+	// Metrics of an outstanding batch are only dropped after it is resolved
+	require.Equal(t, 0, b.Add(MetricTime(6)))
+	require.Equal(t, 4, b.Len())
+	b.Reject(batch)
+	require.Equal(t, 3, b.Len())
+	require.NoError(t, b.Close())
+
+	// The limit also applies to metrics left over from previous runs
+	b = setupDiskCapacity(t, path, 2)
+	defer b.Close()
+	require.Equal(t, 2, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(5),
+			MetricTime(6),
+		}, b.Batch(2))
+}
+
+func TestDiskBuffer_LoadSegmentNames(t *testing.T) {
+	path := t.TempDir()
+
+	b := setupDisk(t, path)
+	b.Add(MetricTime(1), MetricTime(2))
+	require.NoError(t, b.newSegment(5))
+	b.Add(MetricTime(3))
+	require.NoError(t, b.Close())
+
+	b = setupDisk(t, path)
+	defer b.Close()
+
+	require.Len(t, b.segments, 2)
+	require.Equal(t, uint64(0), b.segments[0].first)
+	require.Equal(t, uint64(5), b.segments[1].first)
+	require.Equal(t, uint64(6), b.last)
+}
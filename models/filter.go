@@ -34,6 +34,10 @@ type Filter struct {
 	TagInclude []string
 	tagInclude filter.Filter
 
+	// MetricPass is a boolean expression selecting the metrics to pass.
+	MetricPass string
+	metricPass *filter.Expression
+
 	isActive bool
 }
 
@@ -46,7 +50,8 @@ func (f *Filter) Compile() error {
 		len(f.TagInclude) == 0 &&
 		len(f.TagExclude) == 0 &&
 		len(f.TagPass) == 0 &&
-		len(f.TagDrop) == 0 {
+		len(f.TagDrop) == 0 &&
+		f.MetricPass == "" {
 		return nil
 	}
 
@@ -91,11 +96,19 @@ func (f *Filter) Compile() error {
 			return fmt.Errorf("error compiling 'tagpass', %s", err)
 		}
 	}
+
+	if f.MetricPass != "" {
+		f.metricPass, err = filter.CompileExpression(f.MetricPass)
+		if err != nil {
+			return fmt.Errorf("error compiling 'metricpass', %s", err)
+		}
+	}
 	return nil
 }
 
 // Select returns true if the metric matches according to the
-// namepass/namedrop and tagpass/tagdrop filters.  The metric is not modified.
+// namepass/namedrop, tagpass/tagdrop and metricpass filters.  The metric is not
+// modified.
 func (f *Filter) Select(metric telegraf.Metric) bool {
 	if !f.isActive {
 		return true
@@ -109,6 +122,10 @@ func (f *Filter) Select(metric telegraf.Metric) bool {
 		return false
 	}
 
+	if !f.shouldMetricPass(metric) {
+		return false
+	}
+
 	return true
 }
 
@@ -150,6 +167,18 @@ func (f *Filter) shouldNamePass(key string) bool {
 	return true
 }
 
+// shouldMetricPass returns true if the metric should pass based on the
+// metricpass expression.  Metrics the expression cannot be evaluated for, ie
+// because of a missing field, are dropped.
+func (f *Filter) shouldMetricPass(metric telegraf.Metric) bool {
+	if f.metricPass == nil {
+		return true
+	}
+
+	pass, err := f.metricPass.Eval(metric)
+	return err == nil && pass
+}
+
 // shouldFieldPass returns true if the metric should pass, false if should drop
 // based on the drop/pass filter parameters
 func (f *Filter) shouldFieldPass(key string) bool {
@@ -283,6 +283,59 @@ func TestFilter_TagPass(t *testing.T) {
 	}
 }
 
+func TestFilter_MetricPass(t *testing.T) {
+	f := Filter{
+		MetricPass: `fields.value > 100 && tags.region == "eu"`,
+	}
+	require.NoError(t, f.Compile())
+	require.True(t, f.IsActive())
+
+	passes := []telegraf.Metric{
+		metric.New("m",
+			map[string]string{"region": "eu"},
+			map[string]interface{}{"value": int64(101)},
+			time.Now()),
+		metric.New("m",
+			map[string]string{"region": "eu", "host": "localhost"},
+			map[string]interface{}{"value": 150.5},
+			time.Now()),
+	}
+
+	drops := []telegraf.Metric{
+		metric.New("m",
+			map[string]string{"region": "us"},
+			map[string]interface{}{"value": int64(101)},
+			time.Now()),
+		metric.New("m",
+			map[string]string{"region": "eu"},
+			map[string]interface{}{"value": int64(100)},
+			time.Now()),
+		metric.New("m",
+			map[string]string{},
+			map[string]interface{}{"value": int64(101)},
+			time.Now()),
+		metric.New("m",
+			map[string]string{"region": "eu"},
+			map[string]interface{}{"other": int64(101)},
+			time.Now()),
+	}
+
+	for _, m := range passes {
+		require.Truef(t, f.Select(m), "Expected metric %v to pass", m)
+	}
+
+	for _, m := range drops {
+		require.Falsef(t, f.Select(m), "Expected metric %v to drop", m)
+	}
+}
+
+func TestFilter_MetricPassInvalid(t *testing.T) {
+	f := Filter{
+		MetricPass: `fields.value >`,
+	}
+	require.Error(t, f.Compile())
+}
+
 func TestFilter_TagDrop(t *testing.T) {
 	filters := []TagFilter{
 		{
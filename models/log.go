@@ -1,16 +1,31 @@
 package models
 
 import (
+	"fmt"
 	"log"
 	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/wlog"
+)
+
+// logLevels holds the loggers overriding the agent-wide log level by logger
+// name.  Unaliased instances of the same plugin share the name, so each logger
+// filters its own messages and the log writers only need the lowest level of
+// all loggers of a name.
+var (
+	logLevels   = make(map[string]map[*Logger]wlog.Level)
+	logLevelsMu sync.RWMutex
 )
 
 // Logger defines a logging structure for plugins.
 type Logger struct {
 	OnErrs []func()
 	Name   string // Name is the plugin name, will be printed in the `[]`.
+
+	level wlog.Level // level overriding the agent-wide level, zero if unset
 }
 
 // NewLogger creates a new logger instance
@@ -20,6 +35,74 @@ func NewLogger(pluginType, name, alias string) *Logger {
 	}
 }
 
+// ParseLogLevel returns the log level with the given name, one of "debug",
+// "info", "warn" or "error".
+func ParseLogLevel(name string) (wlog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return wlog.DEBUG, nil
+	case "info":
+		return wlog.INFO, nil
+	case "warn", "warning":
+		return wlog.WARN, nil
+	case "error":
+		return wlog.ERROR, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q", name)
+}
+
+// LogLevel returns the lowest level of the loggers with the given name
+// overriding the agent-wide log level.
+func LogLevel(name string) (wlog.Level, bool) {
+	logLevelsMu.RLock()
+	defer logLevelsMu.RUnlock()
+
+	var lowest wlog.Level
+	for _, level := range logLevels[name] {
+		if lowest == 0 || level < lowest {
+			lowest = level
+		}
+	}
+	return lowest, lowest != 0
+}
+
+// setLevel overrides the agent-wide log level for the messages of the logger.
+// The agent-wide level is used if name is empty or not a valid level.
+func (l *Logger) setLevel(name string) {
+	level, err := ParseLogLevel(name)
+	if err != nil {
+		return
+	}
+	l.level = level
+
+	logLevelsMu.Lock()
+	defer logLevelsMu.Unlock()
+	if logLevels[l.Name] == nil {
+		logLevels[l.Name] = make(map[*Logger]wlog.Level)
+	}
+	logLevels[l.Name][l] = level
+}
+
+// ReleaseLevel removes the level override of the logger from the levels used
+// by the log writers, when the plugin is stopped.
+func (l *Logger) ReleaseLevel() {
+	logLevelsMu.Lock()
+	defer logLevelsMu.Unlock()
+	delete(logLevels[l.Name], l)
+	if len(logLevels[l.Name]) == 0 {
+		delete(logLevels, l.Name)
+	}
+}
+
+// enabled returns whether messages of the level are logged, at the level of
+// the logger if overridden or at the agent-wide level.
+func (l *Logger) enabled(level wlog.Level) bool {
+	if l.level != 0 {
+		return level >= l.level
+	}
+	return level >= wlog.LogLevel()
+}
+
 // OnErr defines a callback that triggers only when errors are about to be written to the log
 func (l *Logger) OnErr(f func()) {
 	l.OnErrs = append(l.OnErrs, f)
@@ -30,6 +113,9 @@ func (l *Logger) Errorf(format string, args ...interface{}) {
 	for _, f := range l.OnErrs {
 		f()
 	}
+	if !l.enabled(wlog.ERROR) {
+		return
+	}
 	log.Printf("E! ["+l.Name+"] "+format, args...)
 }
 
@@ -38,36 +124,57 @@ func (l *Logger) Error(args ...interface{}) {
 	for _, f := range l.OnErrs {
 		f()
 	}
+	if !l.enabled(wlog.ERROR) {
+		return
+	}
 	log.Print(append([]interface{}{"E! [" + l.Name + "] "}, args...)...)
 }
 
 // Debugf logs a debug message, patterned after log.Printf.
 func (l *Logger) Debugf(format string, args ...interface{}) {
+	if !l.enabled(wlog.DEBUG) {
+		return
+	}
 	log.Printf("D! ["+l.Name+"] "+format, args...)
 }
 
 // Debug logs a debug message, patterned after log.Print.
 func (l *Logger) Debug(args ...interface{}) {
+	if !l.enabled(wlog.DEBUG) {
+		return
+	}
 	log.Print(append([]interface{}{"D! [" + l.Name + "] "}, args...)...)
 }
 
 // Warnf logs a warning message, patterned after log.Printf.
 func (l *Logger) Warnf(format string, args ...interface{}) {
+	if !l.enabled(wlog.WARN) {
+		return
+	}
 	log.Printf("W! ["+l.Name+"] "+format, args...)
 }
 
 // Warn logs a warning message, patterned after log.Print.
 func (l *Logger) Warn(args ...interface{}) {
+	if !l.enabled(wlog.WARN) {
+		return
+	}
 	log.Print(append([]interface{}{"W! [" + l.Name + "] "}, args...)...)
 }
 
 // Infof logs an information message, patterned after log.Printf.
 func (l *Logger) Infof(format string, args ...interface{}) {
+	if !l.enabled(wlog.INFO) {
+		return
+	}
 	log.Printf("I! ["+l.Name+"] "+format, args...)
 }
 
 // Info logs an information message, patterned after log.Print.
 func (l *Logger) Info(args ...interface{}) {
+	if !l.enabled(wlog.INFO) {
+		return
+	}
 	log.Print(append([]interface{}{"I! [" + l.Name + "] "}, args...)...)
 }
 
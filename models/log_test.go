@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/wlog"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,3 +23,31 @@ func TestErrorCounting(t *testing.T) {
 
 	require.Equal(t, int64(2), reg.Get())
 }
+
+func TestLogLevelInstances(t *testing.T) {
+	debug := &Logger{Name: "inputs.test"}
+	debug.setLevel("debug")
+	defer debug.ReleaseLevel()
+
+	// Another instance with the same name keeps the override of the first.
+	other := &Logger{Name: "inputs.test"}
+	other.setLevel("")
+	level, ok := LogLevel("inputs.test")
+	require.True(t, ok)
+	require.Equal(t, wlog.DEBUG, level)
+
+	errors := &Logger{Name: "inputs.test"}
+	errors.setLevel("error")
+	level, ok = LogLevel("inputs.test")
+	require.True(t, ok)
+	require.Equal(t, wlog.DEBUG, level)
+
+	debug.ReleaseLevel()
+	level, ok = LogLevel("inputs.test")
+	require.True(t, ok)
+	require.Equal(t, wlog.ERROR, level)
+
+	errors.ReleaseLevel()
+	_, ok = LogLevel("inputs.test")
+	require.False(t, ok)
+}
@@ -1,10 +1,14 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/selfstat"
+	"github.com/robfig/cron/v3"
 )
 
 var (
@@ -21,6 +25,7 @@ type RunningInput struct {
 
 	MetricsGathered selfstat.Stat
 	GatherTime      selfstat.Stat
+	GatherTimeouts  selfstat.Stat
 }
 
 func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
@@ -31,6 +36,7 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 
 	inputErrorsRegister := selfstat.Register("gather", "errors", tags)
 	logger := NewLogger("inputs", config.Name, config.Alias)
+	logger.setLevel(config.LogLevel)
 	logger.OnErr(func() {
 		inputErrorsRegister.Incr(1)
 		GlobalGatherErrors.Incr(1)
@@ -50,6 +56,11 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 			"gather_time_ns",
 			tags,
 		),
+		GatherTimeouts: selfstat.Register(
+			"gather",
+			"gather_timeouts",
+			tags,
+		),
 		log: logger,
 	}
 }
@@ -58,9 +69,24 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 type InputConfig struct {
 	Name             string
 	Alias            string
+	ID               string
 	Interval         time.Duration
 	CollectionJitter time.Duration
 	Precision        time.Duration
+	LogLevel         string
+
+	// GatherTimeout is the time after which a collection is abandoned, zero
+	// waits for the collection to complete.
+	GatherTimeout time.Duration
+
+	// Schedule is a cron expression replacing the interval for triggering
+	// collections, in the timezone ScheduleTimezone or the local timezone.
+	Schedule         string
+	ScheduleTimezone string
+
+	// Pipeline is the named pipeline the metrics are sent to, the top-level
+	// processors, aggregators and outputs are used if empty.
+	Pipeline string
 
 	NameOverride      string
 	MeasurementPrefix string
@@ -69,6 +95,29 @@ type InputConfig struct {
 	Filter            Filter
 }
 
+// ParseSchedule parses a cron expression with the five fields minute, hour,
+// day of month, month and day of week, or a descriptor such as "@daily".  The
+// schedule is evaluated in the given timezone, or in the local timezone if
+// empty.
+func ParseSchedule(spec, timezone string) (cron.Schedule, error) {
+	if timezone != "" {
+		if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+			return nil, errors.New("timezone set both in schedule and schedule_timezone")
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone %q: %w", timezone, err)
+		}
+		spec = "CRON_TZ=" + timezone + " " + spec
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
 func (r *RunningInput) metricFiltered(metric telegraf.Metric) {
 	metric.Drop()
 }
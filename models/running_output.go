@@ -1,11 +1,18 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/limiter"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -15,12 +22,16 @@ const (
 
 	// Default number of metrics kept. It should be a multiple of batch size.
 	DefaultMetricBufferLimit = 10000
+
+	// Default maximum delay between retries of failed writes.
+	DefaultRetryMaxDelay = 5 * time.Minute
 )
 
 // OutputConfig containing name and filter
 type OutputConfig struct {
 	Name   string
 	Alias  string
+	ID     string
 	Filter Filter
 
 	FlushInterval     time.Duration
@@ -28,6 +39,33 @@ type OutputConfig struct {
 	MetricBufferLimit int
 	MetricBatchSize   int
 
+	// BufferStrategy selects the buffer holding unwritten metrics and can be
+	// "memory" or "disk".  BufferDirectory is the base directory used by the
+	// "disk" strategy.
+	BufferStrategy  string
+	BufferDirectory string
+
+	// MaxMetricsPerSecond and MaxBytesPerSecond limit the rate of metrics and
+	// serialized bytes written to the output, zero disables the limit.
+	MaxMetricsPerSecond int
+	MaxBytesPerSecond   int64
+
+	// RetryInitialDelay is the delay before retrying a failed write, doubled on
+	// each consecutive failure up to RetryMaxDelay and extended by a random
+	// duration of up to RetryJitter.  Zero retries on the next flush.  After
+	// RetryMaxAttempts failed writes the batch is dropped, zero never drops.
+	RetryInitialDelay time.Duration
+	RetryMaxDelay     time.Duration
+	RetryJitter       time.Duration
+	RetryMaxAttempts  int
+
+	// DeadLetter is the alias, or the name, of the output receiving the
+	// metrics dropped by this output.
+	DeadLetter string
+
+	// LogLevel overrides the agent-wide log level for the output.
+	LogLevel string
+
 	NameOverride string
 	NamePrefix   string
 	NameSuffix   string
@@ -39,19 +77,48 @@ type RunningOutput struct {
 	newMetricsCount int64
 	droppedMetrics  int64
 
-	Output            telegraf.Output
-	Config            *OutputConfig
+	Output telegraf.Output
+	Config *OutputConfig
+	// Serializer is the serializer set on outputs supporting data formats, it
+	// is nil for all other outputs.
+	Serializer serializers.Serializer
+	// CheckSerializer is a separate instance of the serializer of the output
+	// finding the metrics that cannot be serialized before a batch is
+	// written, without changing the state of the serializer of the output.
+	CheckSerializer   serializers.Serializer
 	MetricBufferLimit int
 	MetricBatchSize   int
 
 	MetricsFiltered selfstat.Stat
 	WriteTime       selfstat.Stat
+	ThrottledTime   selfstat.Stat
+
+	WriteRetries     selfstat.Stat
+	RetriesExhausted selfstat.Stat
 
 	BatchReady chan time.Time
 
-	buffer *Buffer
+	// MemoryBuffer keeps the unwritten metrics in memory whatever the buffer
+	// strategy, so validating or testing the configuration does not touch the
+	// disk buffer of a running agent.
+	MemoryBuffer bool
+
+	buffer MetricBuffer
 	log    telegraf.Logger
 
+	metricLimit    *limiter.TokenBucket
+	byteLimit      *limiter.TokenBucket
+	sizeSerializer serializers.Serializer
+	unthrottled    chan struct{}
+	unthrottleOnce sync.Once
+
+	retryMutex sync.Mutex
+	failures   int
+	retryAt    time.Time
+
+	deadLetterMutex sync.RWMutex
+	deadLetter      *RunningOutput
+
 	aggMutex sync.Mutex
 }
 
@@ -68,6 +135,7 @@ func NewRunningOutput(
 
 	writeErrorsRegister := selfstat.Register("write", "errors", tags)
 	logger := NewLogger("outputs", config.Name, config.Alias)
+	logger.setLevel(config.LogLevel)
 	logger.OnErr(func() {
 		writeErrorsRegister.Incr(1)
 	})
@@ -86,8 +154,9 @@ func NewRunningOutput(
 		batchSize = DefaultMetricBatchSize
 	}
 
+	buffer := NewBuffer(config.Name, config.Alias, bufferLimit)
 	ro := &RunningOutput{
-		buffer:            NewBuffer(config.Name, config.Alias, bufferLimit),
+		buffer:            buffer,
 		BatchReady:        make(chan time.Time, 1),
 		Output:            output,
 		Config:            config,
@@ -103,7 +172,34 @@ func NewRunningOutput(
 			"write_time_ns",
 			tags,
 		),
-		log: logger,
+		ThrottledTime: selfstat.Register(
+			"write",
+			"throttled_time_ns",
+			tags,
+		),
+		WriteRetries: selfstat.Register(
+			"write",
+			"retries",
+			tags,
+		),
+		RetriesExhausted: selfstat.Register(
+			"write",
+			"retries_exhausted",
+			tags,
+		),
+		log:         logger,
+		unthrottled: make(chan struct{}),
+	}
+	buffer.onDrop = ro.routeDropped
+
+	if config.MaxMetricsPerSecond > 0 {
+		rate := float64(config.MaxMetricsPerSecond)
+		ro.metricLimit = limiter.NewTokenBucket(rate, rate)
+	}
+	if config.MaxBytesPerSecond > 0 {
+		rate := float64(config.MaxBytesPerSecond)
+		ro.byteLimit = limiter.NewTokenBucket(rate, rate)
+		ro.sizeSerializer = influx.NewSerializer()
 	}
 
 	return ro
@@ -118,27 +214,97 @@ func (r *RunningOutput) metricFiltered(metric telegraf.Metric) {
 	metric.Drop()
 }
 
+// Preview returns a copy of the metric as it would be added to the output, or
+// nil if the metric is filtered by the output.
+func (r *RunningOutput) Preview(metric telegraf.Metric) telegraf.Metric {
+	metric = metric.Copy()
+	if ok := r.filter(metric); !ok {
+		metric.Drop()
+		return nil
+	}
+	r.rename(metric)
+	return metric
+}
+
+// filter applies the filter of the output to the metric and returns false if
+// the metric should be dropped.
+func (r *RunningOutput) filter(metric telegraf.Metric) bool {
+	if ok := r.Config.Filter.Select(metric); !ok {
+		return false
+	}
+
+	r.Config.Filter.Modify(metric)
+	return len(metric.FieldList()) != 0
+}
+
+// rename applies the name modifiers of the output to the metric.
+func (r *RunningOutput) rename(metric telegraf.Metric) {
+	if len(r.Config.NameOverride) > 0 {
+		metric.SetName(r.Config.NameOverride)
+	}
+
+	if len(r.Config.NamePrefix) > 0 {
+		metric.AddPrefix(r.Config.NamePrefix)
+	}
+
+	if len(r.Config.NameSuffix) > 0 {
+		metric.AddSuffix(r.Config.NameSuffix)
+	}
+}
+
 func (r *RunningOutput) Init() error {
+	// Mark the errors of the serializer, so batches that cannot be serialized
+	// as a whole are dropped instead of retried whatever output is used.
+	if o, ok := r.Output.(serializers.SerializerOutput); ok && r.Serializer != nil {
+		o.SetSerializer(&errorMarkingSerializer{Serializer: r.Serializer})
+	}
+
 	if p, ok := r.Output.(telegraf.Initializer); ok {
 		err := p.Init()
 		if err != nil {
 			return err
 		}
 	}
+
+	switch r.Config.BufferStrategy {
+	case "", "memory":
+	case "disk":
+		if r.Config.BufferDirectory == "" {
+			return fmt.Errorf("buffer_directory must be set for the disk buffer strategy")
+		}
+		if r.MemoryBuffer {
+			break
+		}
+		buffer, err := NewDiskBuffer(r.Config.Name, r.Config.Alias, r.BufferPath(), r.MetricBufferLimit)
+		if err != nil {
+			return fmt.Errorf("opening disk buffer failed: %v", err)
+		}
+		buffer.onDrop = r.routeDropped
+		r.buffer = buffer
+	default:
+		return fmt.Errorf("invalid buffer strategy %q", r.Config.BufferStrategy)
+	}
 	return nil
 }
 
+// BufferPath returns the directory used by the disk buffer of the output, or
+// an empty string if the output buffers metrics in memory.
+func (r *RunningOutput) BufferPath() string {
+	if r.Config.BufferStrategy != "disk" {
+		return ""
+	}
+	name := r.Config.Name
+	if r.Config.Alias != "" {
+		name += "-" + r.Config.Alias
+	}
+	return filepath.Join(r.Config.BufferDirectory, name)
+}
+
 // AddMetric adds a metric to the output.
 //
 // Takes ownership of metric
 func (r *RunningOutput) AddMetric(metric telegraf.Metric) {
-	if ok := r.Config.Filter.Select(metric); !ok {
-		r.metricFiltered(metric)
-		return
-	}
-
-	r.Config.Filter.Modify(metric)
-	if len(metric.FieldList()) == 0 {
+	if ok := r.filter(metric); !ok {
 		r.metricFiltered(metric)
 		return
 	}
@@ -150,17 +316,7 @@ func (r *RunningOutput) AddMetric(metric telegraf.Metric) {
 		return
 	}
 
-	if len(r.Config.NameOverride) > 0 {
-		metric.SetName(r.Config.NameOverride)
-	}
-
-	if len(r.Config.NamePrefix) > 0 {
-		metric.AddPrefix(r.Config.NamePrefix)
-	}
-
-	if len(r.Config.NameSuffix) > 0 {
-		metric.AddSuffix(r.Config.NameSuffix)
-	}
+	r.rename(metric)
 
 	dropped := r.buffer.Add(metric)
 	atomic.AddInt64(&r.droppedMetrics, int64(dropped))
@@ -198,12 +354,9 @@ func (r *RunningOutput) Write() error {
 			break
 		}
 
-		err := r.write(batch)
-		if err != nil {
-			r.buffer.Reject(batch)
+		if err := r.writeBatch(batch); err != nil {
 			return err
 		}
-		r.buffer.Accept(batch)
 	}
 	return nil
 }
@@ -215,22 +368,188 @@ func (r *RunningOutput) WriteBatch() error {
 		return nil
 	}
 
-	err := r.write(batch)
+	return r.writeBatch(batch)
+}
+
+// writeBatch writes the metrics of the batch that can be serialized and drops
+// the others.
+func (r *RunningOutput) writeBatch(batch []telegraf.Metric) error {
+	metrics, invalid := r.checkSerialization(batch)
+	if len(metrics) > 0 {
+		if err := r.write(metrics); err != nil {
+			r.writeFailed(metrics, invalid, err)
+			return err
+		}
+	}
+	r.writeSucceeded(metrics, invalid)
+	return nil
+}
+
+// checkSerialization splits the batch into the metrics that can be serialized
+// by the output and the ones that cannot.
+func (r *RunningOutput) checkSerialization(batch []telegraf.Metric) (metrics, invalid []telegraf.Metric) {
+	if r.CheckSerializer == nil {
+		return batch, nil
+	}
+
+	metrics = make([]telegraf.Metric, 0, len(batch))
+	for _, m := range batch {
+		if _, err := r.CheckSerializer.Serialize(m); err != nil {
+			r.log.Errorf("Dropping metric %q that cannot be serialized: %v", m.Name(), err)
+			invalid = append(invalid, m)
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, invalid
+}
+
+// RetryDelay returns the time left until a failed write should be retried, or
+// zero if the output is not backing off.
+func (r *RunningOutput) RetryDelay() time.Duration {
+	r.retryMutex.Lock()
+	defer r.retryMutex.Unlock()
+
+	if r.retryAt.IsZero() {
+		return 0
+	}
+	if d := time.Until(r.retryAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// writeFailed returns the batch to the buffer and schedules the next attempt,
+// or drops the batch if it cannot be serialized or once the retry attempts are
+// exhausted.  The invalid metrics of the batch, which cannot be serialized,
+// are only dropped once the batch is not retried.
+func (r *RunningOutput) writeFailed(metrics, invalid []telegraf.Metric, err error) {
+	if errors.Is(err, internal.ErrSerialization) {
+		r.log.Errorf("Dropping batch of %d metrics that cannot be serialized", len(metrics)+len(invalid))
+		r.buffer.Drop(append(metrics, invalid...), dropSerialization)
+		return
+	}
+
+	r.retryMutex.Lock()
+	defer r.retryMutex.Unlock()
+
+	r.failures++
+	if r.Config.RetryMaxAttempts > 0 && r.failures >= r.Config.RetryMaxAttempts {
+		r.log.Errorf("Dropping batch of %d metrics after %d failed write attempts", len(metrics), r.failures)
+		r.buffer.Drop(metrics, dropRetriesExhausted)
+		if len(invalid) > 0 {
+			r.buffer.Drop(invalid, dropSerialization)
+		}
+		r.RetriesExhausted.Incr(1)
+		r.failures = 0
+		r.retryAt = time.Time{}
+		return
+	}
+
+	r.buffer.Reject(append(metrics, invalid...))
+	r.WriteRetries.Incr(1)
+	if r.Config.RetryInitialDelay <= 0 {
+		return
+	}
+
+	maxDelay := r.Config.RetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+	delay := r.Config.RetryInitialDelay
+	for i := 1; i < r.failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	delay += internal.RandomDuration(r.Config.RetryJitter)
+
+	r.log.Debugf("Retrying write in %s after %d failed attempts", delay, r.failures)
+	r.retryAt = time.Now().Add(delay)
+}
+
+// errorMarkingSerializer wraps the errors of the serializer of an output with
+// internal.ErrSerialization.
+type errorMarkingSerializer struct {
+	serializers.Serializer
+}
+
+func (s *errorMarkingSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	octets, err := s.Serializer.Serialize(metric)
 	if err != nil {
-		r.buffer.Reject(batch)
-		return err
+		return nil, fmt.Errorf("%w: %v", internal.ErrSerialization, err)
 	}
-	r.buffer.Accept(batch)
+	return octets, nil
+}
 
-	return nil
+func (s *errorMarkingSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	octets, err := s.Serializer.SerializeBatch(metrics)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", internal.ErrSerialization, err)
+	}
+	return octets, nil
+}
+
+// SetDeadLetter sets the output receiving the metrics dropped by this output,
+// nil disables routing dropped metrics.
+func (r *RunningOutput) SetDeadLetter(output *RunningOutput) {
+	r.deadLetterMutex.Lock()
+	defer r.deadLetterMutex.Unlock()
+	r.deadLetter = output
+}
+
+// routeDropped adds a copy of the dropped metric to the dead letter output,
+// tagged with the reason and the name of this output.
+func (r *RunningOutput) routeDropped(metric telegraf.Metric, reason string) {
+	r.deadLetterMutex.RLock()
+	deadLetter := r.deadLetter
+	r.deadLetterMutex.RUnlock()
+	if deadLetter == nil {
+		return
+	}
+
+	m := metric.Copy()
+	m.AddTag("dead_letter_reason", reason)
+	m.AddTag("dead_letter_output", r.LogName())
+	deadLetter.AddMetric(m)
+}
+
+// writeSucceeded accepts the written metrics, drops the invalid metrics of
+// the batch and resets the retry state.
+func (r *RunningOutput) writeSucceeded(metrics, invalid []telegraf.Metric) {
+	r.retryMutex.Lock()
+	defer r.retryMutex.Unlock()
+
+	r.failures = 0
+	r.retryAt = time.Time{}
+	r.buffer.Accept(metrics)
+	if len(invalid) > 0 {
+		r.buffer.Drop(invalid, dropSerialization)
+	}
+}
+
+// DisableRateLimits interrupts a write waiting for the rate limits and writes
+// all following batches without delay, so stopping the output is not held up
+// by the final flush.
+func (r *RunningOutput) DisableRateLimits() {
+	r.unthrottleOnce.Do(func() {
+		close(r.unthrottled)
+	})
 }
 
 // Close closes the output
 func (r *RunningOutput) Close() {
+	r.DisableRateLimits()
+
 	err := r.Output.Close()
 	if err != nil {
 		r.log.Errorf("Error closing output: %v", err)
 	}
+
+	if err := r.buffer.Close(); err != nil {
+		r.log.Errorf("Error closing buffer: %v", err)
+	}
 }
 
 func (r *RunningOutput) write(metrics []telegraf.Metric) error {
@@ -240,6 +559,8 @@ func (r *RunningOutput) write(metrics []telegraf.Metric) error {
 		atomic.StoreInt64(&r.droppedMetrics, 0)
 	}
 
+	r.throttle(metrics)
+
 	start := time.Now()
 	err := r.Output.Write(metrics)
 	elapsed := time.Since(start)
@@ -251,6 +572,57 @@ func (r *RunningOutput) write(metrics []telegraf.Metric) error {
 	return err
 }
 
+// throttle waits until the rate limits of the output allow writing the
+// metrics, or until the rate limits are disabled.
+func (r *RunningOutput) throttle(metrics []telegraf.Metric) {
+	select {
+	case <-r.unthrottled:
+		return
+	default:
+	}
+
+	var wait time.Duration
+	if r.metricLimit != nil {
+		wait = r.metricLimit.Reserve(float64(len(metrics)))
+	}
+	if r.byteLimit != nil {
+		if d := r.byteLimit.Reserve(float64(r.size(metrics))); d > wait {
+			wait = d
+		}
+	}
+	if wait <= 0 {
+		return
+	}
+
+	r.log.Debugf("Rate limit reached, delaying write of %d metrics by %s", len(metrics), wait)
+	start := time.Now()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.unthrottled:
+		r.log.Debugf("Rate limits disabled, writing %d metrics without further delay", len(metrics))
+	}
+	r.ThrottledTime.Incr(time.Since(start).Nanoseconds())
+}
+
+// size returns the number of bytes of the metrics in influx line protocol,
+// an approximation of the bytes written by the output.  The serializer of the
+// output is not used, as serializing the batch an additional time may be
+// expensive and change the state of the serializer.
+func (r *RunningOutput) size(metrics []telegraf.Metric) int {
+	var size int
+	for _, m := range metrics {
+		octets, err := r.sizeSerializer.Serialize(m)
+		if err != nil {
+			r.log.Debugf("Could not determine size of metric: %v", err)
+			continue
+		}
+		size += len(octets)
+	}
+	return size
+}
+
 func (r *RunningOutput) LogBufferStatus() {
 	nBuffer := r.buffer.Len()
 	r.log.Debugf("Buffer fullness: %d / %d metrics", nBuffer, r.MetricBufferLimit)
@@ -263,3 +635,50 @@ func (r *RunningOutput) Log() telegraf.Logger {
 func (r *RunningOutput) BufferLength() int {
 	return r.buffer.Len()
 }
+
+// LinkDeadLetters sets the dead letter output of all outputs configured with
+// one.  On error no output is modified.
+func LinkDeadLetters(outputs []*RunningOutput) error {
+	deadLetters, err := ResolveDeadLetters(outputs)
+	if err != nil {
+		return err
+	}
+	for i, output := range outputs {
+		output.SetDeadLetter(deadLetters[i])
+	}
+	return nil
+}
+
+// ResolveDeadLetters returns the dead letter output of each output, nil for
+// outputs configured without one.  The dead letter output is matched by alias,
+// or by name for outputs without an alias, and must not have a dead letter
+// output itself.
+func ResolveDeadLetters(outputs []*RunningOutput) ([]*RunningOutput, error) {
+	deadLetters := make([]*RunningOutput, len(outputs))
+	for i, output := range outputs {
+		ref := output.Config.DeadLetter
+		if ref == "" {
+			continue
+		}
+
+		var matches []*RunningOutput
+		for _, o := range outputs {
+			if o.Config.Alias == ref || (o.Config.Alias == "" && o.Config.Name == ref) {
+				matches = append(matches, o)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			return nil, fmt.Errorf("%s: dead letter output %q not found", output.LogName(), ref)
+		case len(matches) > 1:
+			return nil, fmt.Errorf("%s: dead letter output %q is ambiguous, set an alias", output.LogName(), ref)
+		case matches[0] == output:
+			return nil, fmt.Errorf("%s: output cannot be its own dead letter output", output.LogName())
+		case matches[0].Config.DeadLetter != "":
+			return nil, fmt.Errorf("%s: dead letter output %s must not have a dead letter output",
+				output.LogName(), matches[0].LogName())
+		}
+		deadLetters[i] = matches[0]
+	}
+	return deadLetters, nil
+}
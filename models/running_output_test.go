@@ -1,12 +1,15 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/selfstat"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
@@ -246,6 +249,29 @@ func TestRunningOutput_NamePrefix(t *testing.T) {
 	assert.Equal(t, "prefix_metric1", m.Metrics()[0].Name())
 }
 
+// Test that the preview applies filters and name modifiers to a copy
+func TestRunningOutput_Preview(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{
+			NamePass: []string{"metric1"},
+		},
+		NamePrefix: "prefix_",
+	}
+	require.NoError(t, conf.Filter.Compile())
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+
+	metric := testutil.TestMetric(101, "metric1")
+	preview := ro.Preview(metric)
+	require.NotNil(t, preview)
+	require.Equal(t, "prefix_metric1", preview.Name())
+	require.Equal(t, "metric1", metric.Name())
+
+	require.Nil(t, ro.Preview(testutil.TestMetric(101, "metric2")))
+	require.Equal(t, 0, ro.BufferLength())
+}
+
 // Test that measurement name suffix is added correctly
 func TestRunningOutput_NameSuffix(t *testing.T) {
 	conf := &OutputConfig{
@@ -286,6 +312,343 @@ func TestRunningOutputDefault(t *testing.T) {
 	assert.Len(t, m.Metrics(), 10)
 }
 
+func TestRunningOutputRateLimit(t *testing.T) {
+	conf := &OutputConfig{
+		Name:                "rate_limited",
+		MaxMetricsPerSecond: 1000,
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 2000, 10000)
+
+	// The first second of metrics is written immediately
+	for i := 0; i < 1000; i++ {
+		ro.AddMetric(testutil.TestMetric(101, "metric1"))
+	}
+	require.NoError(t, ro.Write())
+	require.Zero(t, ro.ThrottledTime.Get())
+
+	// Exceeding the rate delays the write
+	for i := 0; i < 100; i++ {
+		ro.AddMetric(testutil.TestMetric(101, "metric1"))
+	}
+	start := time.Now()
+	require.NoError(t, ro.Write())
+	require.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	require.Greater(t, ro.ThrottledTime.Get(), int64(0))
+	require.Len(t, m.Metrics(), 1100)
+}
+
+func TestRunningOutputByteRateLimit(t *testing.T) {
+	conf := &OutputConfig{
+		Name:              "byte_rate_limited",
+		MaxBytesPerSecond: 1000,
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+
+	// Each metric is about 50 bytes in line protocol
+	for i := 0; i < 22; i++ {
+		ro.AddMetric(testutil.TestMetric(101, "metric1"))
+	}
+	start := time.Now()
+	require.NoError(t, ro.Write())
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	require.Greater(t, ro.ThrottledTime.Get(), int64(0))
+	require.Len(t, m.Metrics(), 22)
+}
+
+func TestRunningOutputDisableRateLimits(t *testing.T) {
+	conf := &OutputConfig{
+		Name:              "byte_rate_limited",
+		MaxBytesPerSecond: 10,
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+
+	// Writing the metrics would be delayed by minutes
+	for i := 0; i < 22; i++ {
+		ro.AddMetric(testutil.TestMetric(101, "metric1"))
+	}
+	done := make(chan error)
+	go func() {
+		done <- ro.Write()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	ro.DisableRateLimits()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "write still waiting for the rate limit")
+	}
+	require.Len(t, m.Metrics(), 22)
+
+	// Following writes are not delayed anymore
+	for i := 0; i < 22; i++ {
+		ro.AddMetric(testutil.TestMetric(101, "metric1"))
+	}
+	start := time.Now()
+	require.NoError(t, ro.Write())
+	require.Less(t, time.Since(start), time.Second)
+	require.Len(t, m.Metrics(), 44)
+}
+
+func TestRunningOutputRetryBackoff(t *testing.T) {
+	conf := &OutputConfig{
+		Name:              "retry_backoff",
+		RetryInitialDelay: time.Hour,
+		RetryMaxDelay:     3 * time.Hour,
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput(m, conf, 100, 1000)
+	require.Zero(t, ro.RetryDelay())
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	// The delay doubles on each failure up to the maximum
+	for _, expected := range []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour, 3 * time.Hour} {
+		require.Error(t, ro.Write())
+		delay := ro.RetryDelay()
+		require.LessOrEqual(t, delay, expected)
+		require.Greater(t, delay, expected-time.Minute)
+	}
+	require.Equal(t, int64(4), ro.WriteRetries.Get())
+	require.Equal(t, 5, ro.BufferLength())
+
+	// A successful write resets the backoff
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.Zero(t, ro.RetryDelay())
+	require.Len(t, m.Metrics(), 5)
+}
+
+func TestRunningOutputRetryMaxAttempts(t *testing.T) {
+	conf := &OutputConfig{
+		Name:             "retry_max_attempts",
+		RetryMaxAttempts: 3,
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput(m, conf, 100, 1000)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	require.Error(t, ro.Write())
+	require.Error(t, ro.Write())
+	require.Equal(t, 5, ro.BufferLength())
+	require.Zero(t, ro.RetryDelay())
+
+	// The batch is dropped after the last attempt
+	require.Error(t, ro.Write())
+	require.Equal(t, 0, ro.BufferLength())
+	require.Equal(t, int64(2), ro.WriteRetries.Get())
+	require.Equal(t, int64(1), ro.RetriesExhausted.Get())
+
+	// New metrics get a fresh set of attempts
+	for _, metric := range next5 {
+		ro.AddMetric(metric)
+	}
+	require.Error(t, ro.Write())
+	require.Equal(t, 5, ro.BufferLength())
+
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.Len(t, m.Metrics(), 5)
+}
+
+func TestRunningOutputDeadLetter(t *testing.T) {
+	dl := &mockOutput{}
+	deadLetter := NewRunningOutput(dl, &OutputConfig{Name: "file"}, 100, 1000)
+
+	m := &mockOutput{}
+	m.writeErr = fmt.Errorf("%w: bad metric", internal.ErrSerialization)
+	ro := NewRunningOutput(m, &OutputConfig{Name: "http", Alias: "api", DeadLetter: "file"}, 100, 2)
+	require.NoError(t, LinkDeadLetters([]*RunningOutput{ro, deadLetter}))
+
+	// Overflowing the buffer drops the oldest metric
+	for _, metric := range first5[:3] {
+		ro.AddMetric(metric)
+	}
+
+	// Metrics that cannot be serialized are dropped instead of retried
+	require.Error(t, ro.Write())
+	require.Equal(t, 0, ro.BufferLength())
+	require.Zero(t, ro.RetryDelay())
+
+	require.NoError(t, deadLetter.Write())
+	expected := []telegraf.Metric{
+		first5[0].Copy(),
+		first5[1].Copy(),
+		first5[2].Copy(),
+	}
+	expected[0].AddTag("dead_letter_reason", "buffer_overflow")
+	expected[1].AddTag("dead_letter_reason", "serialization")
+	expected[2].AddTag("dead_letter_reason", "serialization")
+	for _, metric := range expected {
+		metric.AddTag("dead_letter_output", "outputs.http::api")
+	}
+	testutil.RequireMetricsEqual(t, expected, dl.Metrics())
+}
+
+type serializingOutput struct {
+	mockOutput
+	serializer serializers.Serializer
+}
+
+func (o *serializingOutput) SetSerializer(serializer serializers.Serializer) {
+	o.serializer = serializer
+}
+
+func (o *serializingOutput) Write(metrics []telegraf.Metric) error {
+	if _, err := o.serializer.SerializeBatch(metrics); err != nil {
+		return err
+	}
+	return o.mockOutput.Write(metrics)
+}
+
+type failingSerializer struct{}
+
+func (s *failingSerializer) Serialize(telegraf.Metric) ([]byte, error) {
+	return nil, errors.New("bad metric")
+}
+
+func (s *failingSerializer) SerializeBatch([]telegraf.Metric) ([]byte, error) {
+	return nil, errors.New("bad metric")
+}
+
+func TestRunningOutputSerializerErrors(t *testing.T) {
+	m := &serializingOutput{}
+	ro := NewRunningOutput(m, &OutputConfig{Name: "file"}, 100, 1000)
+	ro.Serializer = &failingSerializer{}
+	m.SetSerializer(ro.Serializer)
+	require.NoError(t, ro.Init())
+
+	ro.AddMetric(first5[0])
+	err := ro.Write()
+	require.ErrorIs(t, err, internal.ErrSerialization)
+	require.Equal(t, 0, ro.BufferLength())
+	require.Zero(t, ro.RetryDelay())
+}
+
+// nameSerializer fails to serialize the metrics with the given name.
+type nameSerializer struct {
+	name string
+}
+
+func (s *nameSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	if metric.Name() == s.name {
+		return nil, errors.New("bad metric")
+	}
+	return []byte(metric.Name()), nil
+}
+
+func (s *nameSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var octets []byte
+	for _, m := range metrics {
+		b, err := s.Serialize(m)
+		if err != nil {
+			return nil, err
+		}
+		octets = append(octets, b...)
+	}
+	return octets, nil
+}
+
+func TestRunningOutputCheckSerializer(t *testing.T) {
+	dl := &mockOutput{}
+	deadLetter := NewRunningOutput(dl, &OutputConfig{Name: "file"}, 100, 1000)
+
+	m := &serializingOutput{}
+	ro := NewRunningOutput(m, &OutputConfig{Name: "http", DeadLetter: "file"}, 100, 1000)
+	ro.Serializer = &nameSerializer{name: "metric2"}
+	ro.CheckSerializer = &nameSerializer{name: "metric2"}
+	m.SetSerializer(ro.Serializer)
+	require.NoError(t, ro.Init())
+	require.NoError(t, LinkDeadLetters([]*RunningOutput{ro, deadLetter}))
+
+	for _, metric := range first5[:3] {
+		ro.AddMetric(metric)
+	}
+
+	// A failed write keeps the whole batch without dropping any metric
+	m.failWrite = true
+	require.Error(t, ro.Write())
+	require.Equal(t, 3, ro.BufferLength())
+	require.NoError(t, deadLetter.Write())
+	require.Empty(t, dl.Metrics())
+
+	// Only the metric that cannot be serialized is dropped
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.Equal(t, 0, ro.BufferLength())
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{first5[0], first5[2]}, m.Metrics())
+
+	require.NoError(t, deadLetter.Write())
+	expected := first5[1].Copy()
+	expected.AddTag("dead_letter_reason", "serialization")
+	expected.AddTag("dead_letter_output", "outputs.http")
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected}, dl.Metrics())
+}
+
+func TestLinkDeadLetters(t *testing.T) {
+	newOutput := func(name, alias, deadLetter string) *RunningOutput {
+		return NewRunningOutput(&mockOutput{}, &OutputConfig{Name: name, Alias: alias, DeadLetter: deadLetter}, 0, 0)
+	}
+
+	tests := []struct {
+		name    string
+		outputs []*RunningOutput
+		err     string
+	}{
+		{
+			name:    "by alias",
+			outputs: []*RunningOutput{newOutput("http", "", "audit"), newOutput("file", "audit", "")},
+		},
+		{
+			name:    "not found",
+			outputs: []*RunningOutput{newOutput("http", "", "file")},
+			err:     `outputs.http: dead letter output "file" not found`,
+		},
+		{
+			name:    "ambiguous",
+			outputs: []*RunningOutput{newOutput("http", "", "file"), newOutput("file", "", ""), newOutput("file", "", "")},
+			err:     `outputs.http: dead letter output "file" is ambiguous, set an alias`,
+		},
+		{
+			name:    "self",
+			outputs: []*RunningOutput{newOutput("http", "", "http")},
+			err:     `outputs.http: output cannot be its own dead letter output`,
+		},
+		{
+			name:    "chained",
+			outputs: []*RunningOutput{newOutput("http", "", "file"), newOutput("file", "", "http")},
+			err:     `outputs.http: dead letter output outputs.file must not have a dead letter output`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LinkDeadLetters(tt.outputs)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.outputs[1], tt.outputs[0].deadLetter)
+		})
+	}
+}
+
 func TestRunningOutputWriteFail(t *testing.T) {
 	conf := &OutputConfig{
 		Filter: Filter{},
@@ -479,14 +842,17 @@ func TestInternalMetrics(t *testing.T) {
 				"alias":  "test_alias",
 			},
 			map[string]interface{}{
-				"buffer_limit":     10,
-				"buffer_size":      0,
-				"errors":           0,
-				"metrics_added":    0,
-				"metrics_dropped":  0,
-				"metrics_filtered": 0,
-				"metrics_written":  0,
-				"write_time_ns":    0,
+				"buffer_limit":      10,
+				"buffer_size":       0,
+				"errors":            0,
+				"metrics_added":     0,
+				"metrics_dropped":   0,
+				"metrics_filtered":  0,
+				"metrics_written":   0,
+				"retries":           0,
+				"retries_exhausted": 0,
+				"throttled_time_ns": 0,
+				"write_time_ns":     0,
 			},
 			time.Unix(0, 0),
 		),
@@ -510,6 +876,8 @@ type mockOutput struct {
 
 	// if true, mock a write failure
 	failWrite bool
+	// if set, returned as the write failure
+	writeErr error
 }
 
 func (m *mockOutput) Connect() error {
@@ -531,6 +899,9 @@ func (m *mockOutput) SampleConfig() string {
 func (m *mockOutput) Write(metrics []telegraf.Metric) error {
 	m.Lock()
 	defer m.Unlock()
+	if m.writeErr != nil {
+		return m.writeErr
+	}
 	if m.failWrite {
 		return fmt.Errorf("failed write")
 	}
@@ -552,6 +923,8 @@ func (m *mockOutput) Metrics() []telegraf.Metric {
 type perfOutput struct {
 	// if true, mock a write failure
 	failWrite bool
+	// if set, returned as the write failure
+	writeErr error
 }
 
 func (m *perfOutput) Connect() error {
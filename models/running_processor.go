@@ -20,12 +20,30 @@ func (rp RunningProcessors) Len() int           { return len(rp) }
 func (rp RunningProcessors) Swap(i, j int)      { rp[i], rp[j] = rp[j], rp[i] }
 func (rp RunningProcessors) Less(i, j int) bool { return rp[i].Config.Order < rp[j].Config.Order }
 
+// Aggregation stages a processor can be restricted to.  Processors apply to
+// the metrics before and after the aggregators by default.
+const (
+	AggregationStageBefore = "before"
+	AggregationStageAfter  = "after"
+)
+
 // FilterConfig containing a name and filter
 type ProcessorConfig struct {
 	Name   string
 	Alias  string
+	ID     string
 	Order  int64
 	Filter Filter
+
+	LogLevel string
+
+	// AggregationStage restricts the processor to the metrics before or after
+	// the aggregators, it applies to both if empty.
+	AggregationStage string
+
+	// Branch is the name of the parallel branch the processor runs in, the
+	// processor runs in the main chain if empty.
+	Branch string
 }
 
 func NewRunningProcessor(processor telegraf.StreamingProcessor, config *ProcessorConfig) *RunningProcessor {
@@ -36,6 +54,7 @@ func NewRunningProcessor(processor telegraf.StreamingProcessor, config *Processo
 
 	processErrorsRegister := selfstat.Register("process", "errors", tags)
 	logger := NewLogger("processors", config.Name, config.Alias)
+	logger.setLevel(config.LogLevel)
 	logger.OnErr(func() {
 		processErrorsRegister.Incr(1)
 	})
@@ -78,6 +97,11 @@ func (rp *RunningProcessor) Start(acc telegraf.Accumulator) error {
 	return rp.Processor.Start(acc)
 }
 
+// Selects returns true if the metric passes the filter of the processor.
+func (rp *RunningProcessor) Selects(m telegraf.Metric) bool {
+	return rp.Config.Filter.Select(m)
+}
+
 func (rp *RunningProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
 	if ok := rp.Config.Filter.Select(m); !ok {
 		// pass downstream
@@ -0,0 +1,92 @@
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// TimestampMessage is the full name of the well-known timestamp message,
+// which is mapped to the metric time instead of a nested message.
+const TimestampMessage = "google.protobuf.Timestamp"
+
+// Config selects the message type in the .proto files.
+type Config struct {
+	// Files are the .proto files defining the message and its dependencies.
+	Files []string `toml:"protobuf_files"`
+	// ImportPaths are the directories to resolve the files and their imports
+	// in.  Defaults to the directories of the files.
+	ImportPaths []string `toml:"protobuf_import_paths"`
+	// MessageType is the fully qualified name of the message.
+	MessageType string `toml:"protobuf_message_type"`
+}
+
+// LoadMessageDescriptor parses the .proto files and returns the descriptor of
+// the message type.  The descriptors are not registered globally, so the same
+// files can be used by several plugins.
+func (c *Config) LoadMessageDescriptor() (protoreflect.MessageDescriptor, error) {
+	if len(c.Files) == 0 {
+		return nil, errors.New("no .proto files given")
+	}
+	if c.MessageType == "" {
+		return nil, errors.New("message type not set")
+	}
+
+	files := c.Files
+	importPaths := c.ImportPaths
+	if len(importPaths) == 0 {
+		files = make([]string, 0, len(c.Files))
+		seen := make(map[string]bool)
+		for _, f := range c.Files {
+			dir := filepath.Dir(f)
+			if !seen[dir] {
+				seen[dir] = true
+				importPaths = append(importPaths, dir)
+			}
+			files = append(files, filepath.Base(f))
+		}
+	}
+
+	parser := protoparse.Parser{ImportPaths: importPaths}
+	fds, err := parser.ParseFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing .proto files failed: %v", err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		if fd == nil || seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		set.File = append(set.File, fd.AsFileDescriptorProto())
+	}
+	for _, fd := range fds {
+		add(fd)
+	}
+
+	registry, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("creating file descriptors failed: %v", err)
+	}
+	d, err := registry.FindDescriptorByName(protoreflect.FullName(c.MessageType))
+	if err != nil {
+		return nil, fmt.Errorf("message type %q not found: %v", c.MessageType, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a message type", c.MessageType)
+	}
+	return md, nil
+}
@@ -0,0 +1,99 @@
+package structured
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Options select the name, tags, fields and time of a metric created from
+// the flattened values of a structured message, e.g. an Avro record or a
+// protocol-buffer message.
+type Options struct {
+	MetricName       string
+	MeasurementField string
+	Tags             []string
+	Fields           []string
+	Timestamp        string
+	TimestampFormat  string
+	DefaultTags      map[string]string
+}
+
+// NewMetric creates a metric from the values, keyed by the flattened names.
+// Values of type time.Time are used as is for the metric time, other times are
+// parsed with the timestamp format, defaulting to "unix".  The values map is
+// modified.
+func (o *Options) NewMetric(values map[string]interface{}) (telegraf.Metric, error) {
+	name := o.MetricName
+	if o.MeasurementField != "" {
+		v, ok := values[o.MeasurementField]
+		if !ok {
+			return nil, fmt.Errorf("measurement field %q not found", o.MeasurementField)
+		}
+		name = fmt.Sprintf("%v", v)
+		delete(values, o.MeasurementField)
+	}
+
+	timestamp := time.Now()
+	if o.Timestamp != "" {
+		v, ok := values[o.Timestamp]
+		if !ok {
+			return nil, fmt.Errorf("timestamp field %q not found", o.Timestamp)
+		}
+		t, err := o.parseTimestamp(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timestamp field %q failed: %v", o.Timestamp, err)
+		}
+		timestamp = t
+		delete(values, o.Timestamp)
+	}
+
+	tags := make(map[string]string)
+	for k, v := range o.DefaultTags {
+		tags[k] = v
+	}
+	for _, key := range o.Tags {
+		v, ok := values[key]
+		if !ok {
+			continue
+		}
+		tags[key] = fmt.Sprintf("%v", v)
+		delete(values, key)
+	}
+
+	fields := values
+	if len(o.Fields) > 0 {
+		fields = make(map[string]interface{}, len(o.Fields))
+		for _, key := range o.Fields {
+			if v, ok := values[key]; ok {
+				fields[key] = v
+			}
+		}
+	}
+	for key, v := range fields {
+		// Timestamps other than the metric time are stored in nanoseconds.
+		if t, ok := v.(time.Time); ok {
+			fields[key] = t.UnixNano()
+		}
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("message has no fields")
+	}
+
+	return metric.New(name, tags, fields, timestamp), nil
+}
+
+func (o *Options) parseTimestamp(v interface{}) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+	format := o.TimestampFormat
+	if format == "" {
+		format = "unix"
+	}
+	return internal.ParseTimestamp(format, v, "")
+}
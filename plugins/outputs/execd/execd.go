@@ -92,7 +92,7 @@ func (e *Execd) Write(metrics []telegraf.Metric) error {
 	for _, m := range metrics {
 		b, err := e.serializer.Serialize(m)
 		if err != nil {
-			return fmt.Errorf("error serializing metrics: %s", err)
+			return fmt.Errorf("error serializing metrics: %w", err)
 		}
 
 		if _, err = e.process.Stdin.Write(b); err != nil {
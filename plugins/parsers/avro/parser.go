@@ -0,0 +1,298 @@
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/structured"
+)
+
+// magicByte starts each message in the schema registry wire format, followed
+// by the 4-byte big-endian schema ID and the Avro binary encoded record.
+const magicByte = 0x00
+
+// Parser decodes Avro binary encoded records into metrics.  The schema is
+// either looked up in a schema registry using the schema ID of the message or
+// read from a local schema file.
+type Parser struct {
+	MetricName       string
+	SchemaRegistry   string
+	SchemaFile       string
+	Measurement      string
+	MeasurementField string
+	Tags             []string
+	Fields           []string
+	FieldSeparator   string
+	Timestamp        string
+	TimestampFormat  string
+	DefaultTags      map[string]string
+
+	registry *schemaRegistry
+	schema   *schema
+}
+
+func (p *Parser) Init() error {
+	if p.SchemaRegistry == "" && p.SchemaFile == "" {
+		return errors.New("either a schema registry or a schema file is required")
+	}
+
+	if p.SchemaRegistry != "" {
+		p.registry = newSchemaRegistry(p.SchemaRegistry)
+	}
+	if p.SchemaFile != "" {
+		buf, err := ioutil.ReadFile(p.SchemaFile)
+		if err != nil {
+			return fmt.Errorf("reading schema file failed: %v", err)
+		}
+		s, err := newSchema(string(buf))
+		if err != nil {
+			return fmt.Errorf("invalid schema in %q: %v", p.SchemaFile, err)
+		}
+		p.schema = s
+	}
+
+	if p.FieldSeparator == "" {
+		p.FieldSeparator = "_"
+	}
+	return nil
+}
+
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	s, data, err := p.schemaOf(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := s.codec.NativeFromBinary(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding avro record failed: %v", err)
+	}
+
+	values := make(map[string]interface{})
+	flatten(values, "", p.FieldSeparator, s.definition, "", native, make(map[string]interface{}))
+
+	m, err := p.createMetric(values)
+	if err != nil {
+		return nil, err
+	}
+	return []telegraf.Metric{m}, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("can not parse the line: %s, for data format: avro ", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+// schemaOf returns the schema of the message and the encoded record.  With a
+// schema registry the message must be in the registry wire format, otherwise
+// the message is the plain record.
+func (p *Parser) schemaOf(buf []byte) (*schema, []byte, error) {
+	if p.registry == nil {
+		if p.schema == nil {
+			return nil, nil, errors.New("parser is not initialized")
+		}
+		return p.schema, buf, nil
+	}
+
+	if len(buf) < 5 || buf[0] != magicByte {
+		return nil, nil, errors.New("message is not in the schema registry wire format")
+	}
+	id := int(binary.BigEndian.Uint32(buf[1:5]))
+	s, err := p.registry.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, buf[5:], nil
+}
+
+func (p *Parser) createMetric(values map[string]interface{}) (telegraf.Metric, error) {
+	name := p.MetricName
+	if p.Measurement != "" {
+		name = p.Measurement
+	}
+	opts := structured.Options{
+		MetricName:       name,
+		MeasurementField: p.MeasurementField,
+		Tags:             p.Tags,
+		Fields:           p.Fields,
+		Timestamp:        p.Timestamp,
+		TimestampFormat:  p.TimestampFormat,
+		DefaultTags:      p.DefaultTags,
+	}
+	return opts.NewMetric(values)
+}
+
+// flatten adds the leaf values of the decoded value to values, joining the
+// names of nested records, maps and arrays with the separator.  The schema
+// is used to unwrap union values, which are decoded as maps keyed by the name
+// of the union member.
+func flatten(values map[string]interface{}, prefix, sep string, def interface{}, namespace string, value interface{}, named map[string]interface{}) {
+	if value == nil {
+		return
+	}
+
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + sep + name
+	}
+
+	switch d := def.(type) {
+	case string:
+		if ref, ok := named[fullName(d, namespace)]; ok {
+			flatten(values, prefix, sep, ref, namespace, value, named)
+			return
+		}
+		if ref, ok := named[d]; ok {
+			flatten(values, prefix, sep, ref, namespace, value, named)
+			return
+		}
+	case []interface{}:
+		wrapped, ok := value.(map[string]interface{})
+		if !ok || len(wrapped) != 1 {
+			break
+		}
+		for key, inner := range wrapped {
+			member := unionMember(d, key, namespace, named)
+			flatten(values, prefix, sep, member, namespace, inner, named)
+		}
+		return
+	case map[string]interface{}:
+		if ns, ok := d["namespace"].(string); ok {
+			namespace = ns
+		}
+		if name, ok := d["name"].(string); ok {
+			named[fullName(name, namespace)] = d
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				namespace = name[:i]
+			}
+		}
+
+		switch d["type"] {
+		case "record", "error":
+			record, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			fields, _ := d["fields"].([]interface{})
+			for _, f := range fields {
+				field, ok := f.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := field["name"].(string)
+				flatten(values, join(name), sep, field["type"], namespace, record[name], named)
+			}
+			return
+		case "array":
+			items, ok := value.([]interface{})
+			if !ok {
+				break
+			}
+			for i, item := range items {
+				flatten(values, join(strconv.Itoa(i)), sep, d["items"], namespace, item, named)
+			}
+			return
+		case "map":
+			entries, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			for key, entry := range entries {
+				flatten(values, join(key), sep, d["values"], namespace, entry, named)
+			}
+			return
+		default:
+			if _, ok := d["type"].(string); !ok {
+				// Nested type definition, e.g. {"type": {"type": "array", ...}}
+				flatten(values, prefix, sep, d["type"], namespace, value, named)
+				return
+			}
+		}
+	}
+
+	if v := convertValue(value); v != nil {
+		values[prefix] = v
+	}
+}
+
+// unionMember returns the schema of the union member named by the key of a
+// decoded union value.
+func unionMember(members []interface{}, key, namespace string, named map[string]interface{}) interface{} {
+	for _, member := range members {
+		var names []string
+		switch m := member.(type) {
+		case string:
+			names = append(names, m, fullName(m, namespace))
+		case map[string]interface{}:
+			if name, ok := m["name"].(string); ok {
+				names = append(names, name, fullName(name, namespace))
+				if ns, ok := m["namespace"].(string); ok {
+					names = append(names, fullName(name, ns))
+				}
+			}
+			if typ, ok := m["type"].(string); ok {
+				names = append(names, typ)
+				if logical, ok := m["logicalType"].(string); ok {
+					names = append(names, typ+"."+logical)
+				}
+			}
+		}
+		for _, name := range names {
+			if name == key {
+				return member
+			}
+		}
+	}
+	return named[key]
+}
+
+func fullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// convertValue converts a decoded primitive value to a field value.
+func convertValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case int64, float64, bool, string:
+		return v
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v
+	case time.Duration:
+		return v.Nanoseconds()
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	default:
+		return nil
+	}
+}
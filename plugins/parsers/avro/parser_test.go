@@ -0,0 +1,196 @@
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+var record = map[string]interface{}{
+	"name":   "cpu",
+	"host":   "server01",
+	"time":   time.Unix(1600000000, 0),
+	"value":  42.5,
+	"count":  goavro.Union("int", int32(3)),
+	"status": goavro.Union("com.example.Status", "OK"),
+	"cpu": map[string]interface{}{
+		"user":   float32(1.5),
+		"system": float32(0.5),
+	},
+	"disks":    []interface{}{int64(10), int64(20)},
+	"labels":   map[string]interface{}{"rack": "a1"},
+	"previous": goavro.Union("com.example.CPU", map[string]interface{}{"user": float32(1), "system": float32(2)}),
+}
+
+func encode(t *testing.T, schemaFile string, native map[string]interface{}) []byte {
+	buf, err := ioutil.ReadFile(schemaFile)
+	require.NoError(t, err)
+	codec, err := goavro.NewCodec(string(buf))
+	require.NoError(t, err)
+	data, err := codec.BinaryFromNative(nil, native)
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseSchemaFile(t *testing.T) {
+	parser := &Parser{
+		MetricName:       "avro",
+		SchemaFile:       "testdata/measurement.avsc",
+		MeasurementField: "name",
+		Tags:             []string{"host", "status"},
+		Timestamp:        "time",
+		DefaultTags:      map[string]string{"source": "kafka"},
+	}
+	require.NoError(t, parser.Init())
+
+	metrics, err := parser.Parse(encode(t, "testdata/measurement.avsc", record))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{
+				"source": "kafka",
+				"host":   "server01",
+				"status": "OK",
+			},
+			map[string]interface{}{
+				"value":           42.5,
+				"count":           int64(3),
+				"cpu_user":        1.5,
+				"cpu_system":      0.5,
+				"disks_0":         int64(10),
+				"disks_1":         int64(20),
+				"labels_rack":     "a1",
+				"previous_user":   1.0,
+				"previous_system": 2.0,
+			},
+			time.Unix(1600000000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseSelectedFields(t *testing.T) {
+	native := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		native[k] = v
+	}
+	native["count"] = goavro.Union("null", nil)
+
+	parser := &Parser{
+		MetricName:     "avro",
+		SchemaFile:     "testdata/measurement.avsc",
+		Measurement:    "system",
+		Fields:         []string{"value", "count", "cpu.user"},
+		FieldSeparator: ".",
+	}
+	require.NoError(t, parser.Init())
+
+	metrics, err := parser.Parse(encode(t, "testdata/measurement.avsc", native))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "system", metrics[0].Name())
+	require.Equal(t, map[string]interface{}{"value": 42.5, "cpu.user": 1.5}, metrics[0].Fields())
+}
+
+func TestParseTimestampFormat(t *testing.T) {
+	schema := `{"type": "record", "name": "Event", "fields": [
+		{"name": "ts", "type": "string"},
+		{"name": "value", "type": "long"}
+	]}`
+	codec, err := goavro.NewCodec(schema)
+	require.NoError(t, err)
+	data, err := codec.BinaryFromNative(nil, map[string]interface{}{"ts": "2021-09-14T12:00:00Z", "value": int64(1)})
+	require.NoError(t, err)
+
+	file, err := ioutil.TempFile("", "schema*.avsc")
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString(schema)
+	require.NoError(t, err)
+
+	parser := &Parser{
+		MetricName:      "event",
+		SchemaFile:      file.Name(),
+		Timestamp:       "ts",
+		TimestampFormat: time.RFC3339,
+	}
+	require.NoError(t, parser.Init())
+
+	metrics, err := parser.Parse(data)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, time.Date(2021, 9, 14, 12, 0, 0, 0, time.UTC), metrics[0].Time())
+	require.Equal(t, map[string]interface{}{"value": int64(1)}, metrics[0].Fields())
+}
+
+func TestParseSchemaRegistry(t *testing.T) {
+	schema, err := ioutil.ReadFile("testdata/measurement.avsc")
+	require.NoError(t, err)
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/ids/42" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		fmt.Fprintf(w, `{"schema": %q}`, string(schema))
+	}))
+	defer ts.Close()
+
+	parser := &Parser{
+		MetricName:     "avro",
+		SchemaRegistry: ts.URL + "/",
+		Tags:           []string{"host"},
+		Timestamp:      "time",
+	}
+	require.NoError(t, parser.Init())
+
+	header := []byte{magicByte, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[1:], 42)
+	message := append(header, encode(t, "testdata/measurement.avsc", record)...)
+
+	for i := 0; i < 2; i++ {
+		metrics, err := parser.Parse(message)
+		require.NoError(t, err)
+		require.Len(t, metrics, 1)
+		require.Equal(t, "avro", metrics[0].Name())
+		require.Equal(t, map[string]string{"host": "server01"}, metrics[0].Tags())
+		require.True(t, time.Unix(1600000000, 0).Equal(metrics[0].Time()))
+	}
+	require.Equal(t, 1, requests, "schema should be cached")
+
+	// Unknown schema ID
+	binary.BigEndian.PutUint32(message[1:], 7)
+	_, err = parser.Parse(message)
+	require.Error(t, err)
+
+	// Missing magic byte
+	message[0] = 0x01
+	_, err = parser.Parse(message)
+	require.EqualError(t, err, "message is not in the schema registry wire format")
+}
+
+func TestInitWithoutSchema(t *testing.T) {
+	parser := &Parser{MetricName: "avro"}
+	require.Error(t, parser.Init())
+}
+
+func TestParseUninitialized(t *testing.T) {
+	parser := &Parser{MetricName: "avro", SchemaFile: "testdata/measurement.avsc"}
+	_, err := parser.Parse(encode(t, "testdata/measurement.avsc", record))
+	require.EqualError(t, err, "parser is not initialized")
+}
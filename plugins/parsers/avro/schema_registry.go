@@ -0,0 +1,81 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// schema is a parsed Avro schema with its codec.
+type schema struct {
+	codec      *goavro.Codec
+	definition interface{}
+}
+
+func newSchema(text string) (*schema, error) {
+	codec, err := goavro.NewCodec(text)
+	if err != nil {
+		return nil, err
+	}
+	var definition interface{}
+	if err := json.Unmarshal([]byte(text), &definition); err != nil {
+		return nil, err
+	}
+	return &schema{codec: codec, definition: definition}, nil
+}
+
+// schemaRegistry fetches schemas by ID from a Confluent-compatible schema
+// registry and caches them, as the schema of an ID never changes.
+type schemaRegistry struct {
+	url    string
+	client *http.Client
+
+	sync.Mutex
+	cache map[int]*schema
+}
+
+func newSchemaRegistry(url string) *schemaRegistry {
+	return &schemaRegistry{
+		url:    strings.TrimRight(url, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+		cache:  make(map[int]*schema),
+	}
+}
+
+func (r *schemaRegistry) get(id int) (*schema, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if s, ok := r.cache[id]; ok {
+		return s, nil
+	}
+
+	resp, err := r.client.Get(fmt.Sprintf("%s/schemas/ids/%d", r.url, id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching schema %d failed: %s", id, resp.Status)
+	}
+
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding schema %d failed: %v", id, err)
+	}
+
+	s, err := newSchema(body.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %d: %v", id, err)
+	}
+	r.cache[id] = s
+	return s, nil
+}
@@ -0,0 +1,376 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Parser decodes fixed-layout binary messages into metrics.  Each
+// configuration whose filter matches the message produces a metric.
+type Parser struct {
+	MetricName  string
+	Endianness  string
+	Configs     []Config
+	DefaultTags map[string]string
+
+	order binary.ByteOrder
+}
+
+// Config describes the layout of one type of message.
+type Config struct {
+	MetricName      string
+	TimestampFormat string
+	Filter          *Filter
+	Entries         []Entry
+}
+
+// Filter selects the messages a configuration applies to.
+type Filter struct {
+	// Length of the message in bytes, any length if zero.
+	Length     int
+	Selections []Selection
+}
+
+// Selection matches the bytes at the offset in the message.
+type Selection struct {
+	Offset int
+	// Match is the hex encoded value of the bytes, e.g. "0x01ff".
+	Match string
+
+	match []byte
+}
+
+// Entry is a value in the message.
+type Entry struct {
+	Name string
+	// Type is one of int8..int64, uint8..uint64, float32, float64, bool or
+	// string.
+	Type string
+	// Offset of the entry in bytes, following the previous entry if nil.
+	Offset *int
+	// Bits reads a bitfield of the given number of bits, most significant bit
+	// first, instead of the full size of an integer or bool type.
+	Bits int
+	// Length of a string in bytes.  Strings without length end at the first
+	// zero byte or the end of the message.
+	Length int
+	// Assignment is one of "field" (default), "tag", "time" or
+	// "measurement".
+	Assignment string
+	// Omit skips the entry, e.g. for reserved bits or padding.
+	Omit bool
+}
+
+var typeBits = map[string]int{
+	"int8":    8,
+	"int16":   16,
+	"int32":   32,
+	"int64":   64,
+	"uint8":   8,
+	"uint16":  16,
+	"uint32":  32,
+	"uint64":  64,
+	"float32": 32,
+	"float64": 64,
+	"bool":    8,
+	"string":  0,
+}
+
+func (p *Parser) Init() error {
+	switch p.Endianness {
+	case "", "be":
+		p.order = binary.BigEndian
+	case "le":
+		p.order = binary.LittleEndian
+	default:
+		return fmt.Errorf("invalid endianness %q", p.Endianness)
+	}
+
+	if len(p.Configs) == 0 {
+		return errors.New("no binary configuration given")
+	}
+
+	// The configurations are completed below, so copy them as they may be
+	// shared by all parsers created from the same settings.
+	configs := make([]Config, 0, len(p.Configs))
+	for _, cfg := range p.Configs {
+		if cfg.Filter != nil {
+			filter := *cfg.Filter
+			filter.Selections = append([]Selection(nil), filter.Selections...)
+			cfg.Filter = &filter
+		}
+		cfg.Entries = append([]Entry(nil), cfg.Entries...)
+		configs = append(configs, cfg)
+	}
+	p.Configs = configs
+
+	for i := range p.Configs {
+		cfg := &p.Configs[i]
+		if cfg.Filter != nil {
+			for j := range cfg.Filter.Selections {
+				s := &cfg.Filter.Selections[j]
+				if s.Offset < 0 {
+					return fmt.Errorf("invalid offset %d in filter", s.Offset)
+				}
+				match, err := hex.DecodeString(strings.TrimPrefix(s.Match, "0x"))
+				if err != nil || len(match) == 0 {
+					return fmt.Errorf("invalid match %q in filter", s.Match)
+				}
+				s.match = match
+			}
+		}
+
+		if len(cfg.Entries) == 0 {
+			return fmt.Errorf("no entries in configuration %d", i+1)
+		}
+		for j := range cfg.Entries {
+			if err := cfg.Entries[j].check(); err != nil {
+				return fmt.Errorf("entry %d of configuration %d: %v", j+1, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Entry) check() error {
+	if e.Type == "" {
+		switch {
+		case e.Length > 0:
+			e.Type = "string"
+		case e.Bits > 0:
+			e.Type = "uint64"
+		default:
+			return errors.New("type required")
+		}
+	}
+	size, ok := typeBits[e.Type]
+	if !ok {
+		return fmt.Errorf("invalid type %q", e.Type)
+	}
+	if e.Bits < 0 || e.Bits > size || (e.Bits > 0 && strings.HasPrefix(e.Type, "float")) {
+		return fmt.Errorf("invalid number of bits %d for type %q", e.Bits, e.Type)
+	}
+	if e.Length != 0 && e.Type != "string" {
+		return fmt.Errorf("length is only supported for strings")
+	}
+	if e.Offset != nil && *e.Offset < 0 {
+		return fmt.Errorf("invalid offset %d", *e.Offset)
+	}
+
+	switch e.Assignment {
+	case "":
+		e.Assignment = "field"
+	case "field", "tag", "time", "measurement":
+	default:
+		return fmt.Errorf("invalid assignment %q", e.Assignment)
+	}
+	if e.Name == "" && !e.Omit && (e.Assignment == "field" || e.Assignment == "tag") {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	if p.order == nil {
+		return nil, errors.New("parser is not initialized")
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	for i := range p.Configs {
+		cfg := &p.Configs[i]
+		if !cfg.matches(buf) {
+			continue
+		}
+		m, err := p.parseMessage(cfg, buf)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("can not parse the line: %s, for data format: binary ", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (c *Config) matches(buf []byte) bool {
+	if c.Filter == nil {
+		return true
+	}
+	if c.Filter.Length > 0 && len(buf) != c.Filter.Length {
+		return false
+	}
+	for _, s := range c.Filter.Selections {
+		end := s.Offset + len(s.match)
+		if end > len(buf) || !bytes.Equal(buf[s.Offset:end], s.match) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Parser) parseMessage(cfg *Config, buf []byte) (telegraf.Metric, error) {
+	name := p.MetricName
+	if cfg.MetricName != "" {
+		name = cfg.MetricName
+	}
+	timestamp := time.Now()
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	fields := make(map[string]interface{})
+
+	var pos int // position in bits
+	for _, e := range cfg.Entries {
+		if e.Offset != nil {
+			pos = *e.Offset * 8
+		}
+		value, n, err := p.read(e, buf, pos)
+		if err != nil {
+			return nil, fmt.Errorf("entry %q: %v", e.Name, err)
+		}
+		pos += n
+		if e.Omit {
+			continue
+		}
+
+		switch e.Assignment {
+		case "field":
+			fields[e.Name] = value
+		case "tag":
+			tags[e.Name] = fmt.Sprintf("%v", value)
+		case "measurement":
+			name = fmt.Sprintf("%v", value)
+		case "time":
+			format := cfg.TimestampFormat
+			if format == "" {
+				format = "unix"
+			}
+			timestamp, err = internal.ParseTimestamp(format, value, "")
+			if err != nil {
+				return nil, fmt.Errorf("entry %q: parsing time failed: %v", e.Name, err)
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.New("no fields in message")
+	}
+	return metric.New(name, tags, fields, timestamp), nil
+}
+
+// read returns the value of the entry at the bit position and the number of
+// bits read.
+func (p *Parser) read(e Entry, buf []byte, pos int) (interface{}, int, error) {
+	if e.Type == "string" {
+		if pos%8 != 0 {
+			return nil, 0, errors.New("not aligned to a byte")
+		}
+		start := pos / 8
+		if start > len(buf) {
+			return nil, 0, errors.New("message too short")
+		}
+		if e.Length > 0 {
+			end := start + e.Length
+			if end > len(buf) {
+				return nil, 0, errors.New("message too short")
+			}
+			raw := buf[start:end]
+			if i := bytes.IndexByte(raw, 0); i >= 0 {
+				raw = raw[:i]
+			}
+			return string(raw), e.Length * 8, nil
+		}
+		raw := buf[start:]
+		n := len(raw)
+		if i := bytes.IndexByte(raw, 0); i >= 0 {
+			raw = raw[:i]
+			n = i + 1
+		}
+		return string(raw), n * 8, nil
+	}
+
+	if e.Bits > 0 {
+		if pos+e.Bits > len(buf)*8 {
+			return nil, 0, errors.New("message too short")
+		}
+		var raw uint64
+		for i := pos; i < pos+e.Bits; i++ {
+			bit := (buf[i/8] >> (7 - uint(i%8))) & 1
+			raw = raw<<1 | uint64(bit)
+		}
+		switch {
+		case e.Type == "bool":
+			return raw != 0, e.Bits, nil
+		case strings.HasPrefix(e.Type, "int"):
+			// Sign-extend the bitfield
+			shift := uint(64 - e.Bits)
+			return int64(raw<<shift) >> shift, e.Bits, nil
+		default:
+			return raw, e.Bits, nil
+		}
+	}
+
+	if pos%8 != 0 {
+		return nil, 0, errors.New("not aligned to a byte")
+	}
+	size := typeBits[e.Type]
+	start, end := pos/8, pos/8+size/8
+	if end > len(buf) {
+		return nil, 0, errors.New("message too short")
+	}
+	b := buf[start:end]
+
+	var value interface{}
+	switch e.Type {
+	case "int8":
+		value = int64(int8(b[0]))
+	case "int16":
+		value = int64(int16(p.order.Uint16(b)))
+	case "int32":
+		value = int64(int32(p.order.Uint32(b)))
+	case "int64":
+		value = int64(p.order.Uint64(b))
+	case "uint8":
+		value = uint64(b[0])
+	case "uint16":
+		value = uint64(p.order.Uint16(b))
+	case "uint32":
+		value = uint64(p.order.Uint32(b))
+	case "uint64":
+		value = p.order.Uint64(b)
+	case "float32":
+		value = float64(math.Float32frombits(p.order.Uint32(b)))
+	case "float64":
+		value = math.Float64frombits(p.order.Uint64(b))
+	case "bool":
+		value = b[0] != 0
+	}
+	return value, size, nil
+}
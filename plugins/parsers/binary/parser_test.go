@@ -0,0 +1,193 @@
+package binary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func offset(o int) *int {
+	return &o
+}
+
+func TestParse(t *testing.T) {
+	parser := &Parser{
+		MetricName:  "binary",
+		DefaultTags: map[string]string{"source": "plc"},
+		Configs: []Config{
+			{
+				MetricName: "status",
+				Filter: &Filter{
+					Length:     24,
+					Selections: []Selection{{Offset: 0, Match: "0x01"}},
+				},
+				Entries: []Entry{
+					{Name: "type", Type: "uint8", Omit: true},
+					{Name: "device", Type: "uint16", Assignment: "tag"},
+					{Name: "time", Type: "uint32", Assignment: "time"},
+					{Name: "temperature", Type: "float32"},
+					{Name: "pressure", Type: "int16"},
+					{Name: "running", Type: "bool", Bits: 1},
+					{Name: "mode", Type: "uint8", Bits: 3},
+					{Name: "trend", Type: "int8", Bits: 4},
+					{Name: "label", Type: "string", Length: 6},
+					{Name: "counter", Type: "uint32", Offset: offset(20)},
+				},
+			},
+			{
+				MetricName: "alarm",
+				Filter: &Filter{
+					Selections: []Selection{{Offset: 0, Match: "0x02"}},
+				},
+				Entries: []Entry{
+					{Name: "code", Type: "uint16", Offset: offset(1)},
+					{Name: "text", Type: "string"},
+				},
+			},
+		},
+	}
+	require.NoError(t, parser.Init())
+
+	status := []byte{
+		0x01,       // type
+		0x00, 0x2a, // device
+		0x5f, 0x5e, 0x10, 0x00, // time 1600000000
+		0x41, 0xc8, 0x00, 0x00, // temperature 25.0
+		0xff, 0x38, // pressure -200
+		0xae,                           // running=1, mode=010, trend=1110
+		'p', 'u', 'm', 'p', 0x00, 0x00, // label
+		0x00, 0x00, 0x01, 0x00, // counter
+	}
+	metrics, err := parser.Parse(status)
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"status",
+			map[string]string{"source": "plc", "device": "42"},
+			map[string]interface{}{
+				"temperature": 25.0,
+				"pressure":    int64(-200),
+				"running":     true,
+				"mode":        uint64(2),
+				"trend":       int64(-2),
+				"label":       "pump",
+				"counter":     uint64(256),
+			},
+			time.Unix(1600000000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+
+	metrics, err = parser.Parse([]byte{0x02, 0x00, 0x07, 'o', 'v', 'e', 'r', 'h', 'e', 'a', 't'})
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "alarm", metrics[0].Name())
+	require.Equal(t, map[string]interface{}{"code": uint64(7), "text": "overheat"}, metrics[0].Fields())
+
+	// Messages not matching any filter are ignored
+	metrics, err = parser.Parse([]byte{0x03, 0x00})
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+
+	// Matching message shorter than the layout
+	parser.Configs[0].Filter.Length = 0
+	_, err = parser.Parse(status[:10])
+	require.EqualError(t, err, `entry "temperature": message too short`)
+}
+
+func TestParseLittleEndian(t *testing.T) {
+	parser := &Parser{
+		MetricName: "binary",
+		Endianness: "le",
+		Configs: []Config{
+			{
+				TimestampFormat: "unix_ms",
+				Entries: []Entry{
+					{Name: "time", Type: "uint64", Assignment: "time"},
+					{Name: "value", Type: "float64"},
+					{Name: "count", Type: "int32"},
+				},
+			},
+		},
+	}
+	require.NoError(t, parser.Init())
+
+	buf := []byte{
+		0x00, 0x80, 0x6e, 0x87, 0x74, 0x01, 0x00, 0x00, // 1600000000000 ms
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, // 1.5
+		0xfe, 0xff, 0xff, 0xff, // -2
+	}
+	metrics, err := parser.Parse(buf)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "binary", metrics[0].Name())
+	require.Equal(t, map[string]interface{}{"value": 1.5, "count": int64(-2)}, metrics[0].Fields())
+	require.True(t, time.Unix(1600000000, 0).Equal(metrics[0].Time()))
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		parser   *Parser
+		expected string
+	}{
+		{
+			name:     "endianness",
+			parser:   &Parser{Endianness: "middle", Configs: []Config{{Entries: []Entry{{Name: "a", Type: "uint8"}}}}},
+			expected: `invalid endianness "middle"`,
+		},
+		{
+			name:     "no configs",
+			parser:   &Parser{},
+			expected: "no binary configuration given",
+		},
+		{
+			name:     "type",
+			parser:   &Parser{Configs: []Config{{Entries: []Entry{{Name: "a", Type: "int128"}}}}},
+			expected: `entry 1 of configuration 1: invalid type "int128"`,
+		},
+		{
+			name:     "bits",
+			parser:   &Parser{Configs: []Config{{Entries: []Entry{{Name: "a", Type: "uint8", Bits: 9}}}}},
+			expected: `entry 1 of configuration 1: invalid number of bits 9 for type "uint8"`,
+		},
+		{
+			name:     "name",
+			parser:   &Parser{Configs: []Config{{Entries: []Entry{{Type: "uint8"}}}}},
+			expected: "entry 1 of configuration 1: name required",
+		},
+		{
+			name: "match",
+			parser: &Parser{Configs: []Config{{
+				Filter:  &Filter{Selections: []Selection{{Match: "0xzz"}}},
+				Entries: []Entry{{Name: "a", Type: "uint8"}},
+			}}},
+			expected: `invalid match "0xzz" in filter`,
+		},
+		{
+			name: "selection offset",
+			parser: &Parser{Configs: []Config{{
+				Filter:  &Filter{Selections: []Selection{{Offset: -1, Match: "0x01"}}},
+				Entries: []Entry{{Name: "a", Type: "uint8"}},
+			}}},
+			expected: `invalid offset -1 in filter`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.EqualError(t, tt.parser.Init(), tt.expected)
+		})
+	}
+}
+
+func TestParseUninitialized(t *testing.T) {
+	parser := &Parser{Configs: []Config{{Entries: []Entry{{Name: "a", Type: "uint8"}}}}}
+	_, err := parser.Parse([]byte{0x01})
+	require.EqualError(t, err, "parser is not initialized")
+}
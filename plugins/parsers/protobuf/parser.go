@@ -0,0 +1,192 @@
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/protobuf"
+	"github.com/influxdata/telegraf/plugins/common/structured"
+)
+
+// Parser decodes protocol-buffer messages of the type defined in .proto files
+// into metrics.
+type Parser struct {
+	protobuf.Config
+
+	MetricName       string
+	LengthDelimited  bool
+	MeasurementField string
+	Tags             []string
+	Fields           []string
+	FieldSeparator   string
+	Timestamp        string
+	TimestampFormat  string
+	DefaultTags      map[string]string
+
+	msgType protoreflect.MessageType
+}
+
+func (p *Parser) Init() error {
+	md, err := p.LoadMessageDescriptor()
+	if err != nil {
+		return err
+	}
+	p.msgType = dynamicpb.NewMessageType(md)
+
+	if p.FieldSeparator == "" {
+		p.FieldSeparator = "_"
+	}
+	return nil
+}
+
+// Parse decodes the message, or all messages of a stream of length-delimited
+// messages, each prefixed with its size as varint.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	if !p.LengthDelimited {
+		m, err := p.parseMessage(buf)
+		if err != nil {
+			return nil, err
+		}
+		return []telegraf.Metric{m}, nil
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	for len(buf) > 0 {
+		size, n := protowire.ConsumeVarint(buf)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid message length: %v", protowire.ParseError(n))
+		}
+		buf = buf[n:]
+		if uint64(len(buf)) < size {
+			return nil, errors.New("message truncated")
+		}
+		m, err := p.parseMessage(buf[:size])
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+		buf = buf[size:]
+	}
+	return metrics, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("can not parse the line: %s, for data format: protobuf ", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (p *Parser) parseMessage(buf []byte) (telegraf.Metric, error) {
+	if p.msgType == nil {
+		return nil, errors.New("parser is not initialized")
+	}
+
+	msg := p.msgType.New()
+	if err := proto.Unmarshal(buf, msg.Interface()); err != nil {
+		return nil, fmt.Errorf("decoding message failed: %v", err)
+	}
+
+	values := make(map[string]interface{})
+	p.flattenMessage(values, "", msg)
+
+	opts := structured.Options{
+		MetricName:       p.MetricName,
+		MeasurementField: p.MeasurementField,
+		Tags:             p.Tags,
+		Fields:           p.Fields,
+		Timestamp:        p.Timestamp,
+		TimestampFormat:  p.TimestampFormat,
+		DefaultTags:      p.DefaultTags,
+	}
+	return opts.NewMetric(values)
+}
+
+// flattenMessage adds the values of the message to values, joining the names
+// of nested messages, repeated fields and maps with the separator.  Fields
+// without presence are added with their default value, unset fields with
+// presence are skipped.
+func (p *Parser) flattenMessage(values map[string]interface{}, prefix string, msg protoreflect.Message) {
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if (fd.HasPresence() || fd.IsList() || fd.IsMap()) && !msg.Has(fd) {
+			continue
+		}
+		name := p.join(prefix, string(fd.Name()))
+		v := msg.Get(fd)
+
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for j := 0; j < list.Len(); j++ {
+				p.flattenValue(values, p.join(name, fmt.Sprintf("%d", j)), fd, list.Get(j))
+			}
+		case fd.IsMap():
+			v.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+				p.flattenValue(values, p.join(name, key.String()), fd.MapValue(), value)
+				return true
+			})
+		default:
+			p.flattenValue(values, name, fd, v)
+		}
+	}
+}
+
+func (p *Parser) flattenValue(values map[string]interface{}, name string, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		msg := v.Message()
+		if msg.Descriptor().FullName() == protobuf.TimestampMessage {
+			seconds := msg.Get(msg.Descriptor().Fields().ByName("seconds")).Int()
+			nanos := msg.Get(msg.Descriptor().Fields().ByName("nanos")).Int()
+			values[name] = time.Unix(seconds, nanos)
+			return
+		}
+		p.flattenMessage(values, name, msg)
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			values[name] = string(ev.Name())
+		} else {
+			values[name] = int64(v.Enum())
+		}
+	case protoreflect.BoolKind:
+		values[name] = v.Bool()
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		values[name] = v.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		values[name] = v.Uint()
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		values[name] = v.Float()
+	case protoreflect.StringKind:
+		values[name] = v.String()
+	case protoreflect.BytesKind:
+		values[name] = string(v.Bytes())
+	}
+}
+
+func (p *Parser) join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + p.FieldSeparator + name
+}
@@ -0,0 +1,174 @@
+package protobuf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/protobuf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+var config = protobuf.Config{
+	Files:       []string{"../../common/protobuf/testdata/measurement.proto"},
+	MessageType: "telegraf.test.Measurement",
+}
+
+// encode sets the fields of a new measurement message from the values
+// and returns the encoded message.
+func encode(t *testing.T, values map[string]interface{}) []byte {
+	md, err := config.LoadMessageDescriptor()
+	require.NoError(t, err)
+
+	msg := dynamicpb.NewMessage(md)
+	fields := md.Fields()
+	for name, v := range values {
+		fd := fields.ByName(protoreflect.Name(name))
+		require.NotNil(t, fd, name)
+		switch v := v.(type) {
+		case time.Time:
+			ts := dynamicpb.NewMessage(fd.Message())
+			ts.Set(fd.Message().Fields().ByName("seconds"), protoreflect.ValueOfInt64(v.Unix()))
+			msg.Set(fd, protoreflect.ValueOfMessage(ts))
+		case map[string]interface{}:
+			sub := dynamicpb.NewMessage(fd.Message())
+			for k, sv := range v {
+				sub.Set(fd.Message().Fields().ByName(protoreflect.Name(k)), protoreflect.ValueOf(sv))
+			}
+			msg.Set(fd, protoreflect.ValueOfMessage(sub))
+		case []int64:
+			list := msg.Mutable(fd).List()
+			for _, e := range v {
+				list.Append(protoreflect.ValueOfInt64(e))
+			}
+		case map[string]string:
+			m := msg.Mutable(fd).Map()
+			for k, e := range v {
+				m.Set(protoreflect.ValueOfString(k).MapKey(), protoreflect.ValueOfString(e))
+			}
+		case protoreflect.EnumNumber:
+			msg.Set(fd, protoreflect.ValueOfEnum(v))
+		default:
+			msg.Set(fd, protoreflect.ValueOf(v))
+		}
+	}
+
+	buf, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	return buf
+}
+
+func TestParse(t *testing.T) {
+	parser := &Parser{
+		Config:           config,
+		MetricName:       "protobuf",
+		MeasurementField: "name",
+		Tags:             []string{"host", "status", "labels_rack"},
+		Timestamp:        "time",
+		DefaultTags:      map[string]string{"source": "mqtt"},
+	}
+	require.NoError(t, parser.Init())
+
+	buf := encode(t, map[string]interface{}{
+		"name":    "system",
+		"host":    "server01",
+		"time":    time.Unix(1600000000, 0),
+		"value":   42.5,
+		"bytes":   uint64(1024),
+		"running": true,
+		"status":  protoreflect.EnumNumber(1),
+		"cpu":     map[string]interface{}{"user": float32(1.5), "system": 0.25},
+		"disks":   []int64{10, -20},
+		"labels":  map[string]string{"rack": "a1"},
+		"errors":  int64(0),
+	})
+	metrics, err := parser.Parse(buf)
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"system",
+			map[string]string{
+				"source":      "mqtt",
+				"host":        "server01",
+				"status":      "OK",
+				"labels_rack": "a1",
+			},
+			map[string]interface{}{
+				"value":      42.5,
+				"count":      int64(0),
+				"bytes":      uint64(1024),
+				"running":    true,
+				"cpu_user":   1.5,
+				"cpu_system": 0.25,
+				"disks_0":    int64(10),
+				"disks_1":    int64(-20),
+				"errors":     int64(0),
+				"created":    int64(0),
+			},
+			time.Unix(1600000000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseLengthDelimited(t *testing.T) {
+	parser := &Parser{
+		Config:          config,
+		MetricName:      "protobuf",
+		LengthDelimited: true,
+		Tags:            []string{"host"},
+		Fields:          []string{"value"},
+		Timestamp:       "created",
+		TimestampFormat: "unix_ms",
+	}
+	require.NoError(t, parser.Init())
+
+	var stream []byte
+	for i, host := range []string{"a", "b", "c"} {
+		msg := encode(t, map[string]interface{}{
+			"host":    host,
+			"value":   float64(i),
+			"created": int64(1600000000000 + i),
+		})
+		stream = protowire.AppendVarint(stream, uint64(len(msg)))
+		stream = append(stream, msg...)
+	}
+
+	metrics, err := parser.Parse(stream)
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+	for i, m := range metrics {
+		require.Equal(t, "protobuf", m.Name())
+		require.Equal(t, map[string]interface{}{"value": float64(i)}, m.Fields())
+		require.Equal(t, time.Unix(1600000000, int64(i)*1e6).UnixNano(), m.Time().UnixNano())
+	}
+	require.Equal(t, map[string]string{"host": "c"}, metrics[2].Tags())
+
+	_, err = parser.Parse(stream[:len(stream)-1])
+	require.EqualError(t, err, "message truncated")
+}
+
+func TestInitErrors(t *testing.T) {
+	parser := &Parser{Config: protobuf.Config{Files: []string{"../../common/protobuf/testdata/measurement.proto"}, MessageType: "telegraf.test.Missing"}}
+	require.Error(t, parser.Init())
+
+	parser = &Parser{Config: protobuf.Config{Files: []string{"../../common/protobuf/testdata/missing.proto"}, MessageType: "telegraf.test.Measurement"}}
+	require.Error(t, parser.Init())
+
+	// Loading the same files again must not conflict
+	parser = &Parser{Config: config}
+	require.NoError(t, parser.Init())
+}
+
+func TestParseUninitialized(t *testing.T) {
+	parser := &Parser{Config: config, MetricName: "protobuf"}
+	_, err := parser.Parse(encode(t, map[string]interface{}{"value": 1.0}))
+	require.EqualError(t, err, "parser is not initialized")
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 
 	"github.com/influxdata/telegraf"
+	protobufconfig "github.com/influxdata/telegraf/plugins/common/protobuf"
+	"github.com/influxdata/telegraf/plugins/parsers/avro"
+	"github.com/influxdata/telegraf/plugins/parsers/binary"
 	"github.com/influxdata/telegraf/plugins/parsers/collectd"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/dropwizard"
@@ -17,6 +20,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
 	"github.com/influxdata/telegraf/plugins/parsers/prometheus"
 	"github.com/influxdata/telegraf/plugins/parsers/prometheusremotewrite"
+	"github.com/influxdata/telegraf/plugins/parsers/protobuf"
 	"github.com/influxdata/telegraf/plugins/parsers/value"
 	"github.com/influxdata/telegraf/plugins/parsers/wavefront"
 	"github.com/influxdata/telegraf/plugins/parsers/xpath"
@@ -153,9 +157,36 @@ type Config struct {
 	CSVTrimSpace         bool     `toml:"csv_trim_space"`
 	CSVSkipValues        []string `toml:"csv_skip_values"`
 
+	// Avro configuration
+	AvroSchemaRegistry   string   `toml:"avro_schema_registry"`
+	AvroSchemaFile       string   `toml:"avro_schema_file"`
+	AvroMeasurement      string   `toml:"avro_measurement"`
+	AvroMeasurementField string   `toml:"avro_measurement_field"`
+	AvroTags             []string `toml:"avro_tags"`
+	AvroFields           []string `toml:"avro_fields"`
+	AvroFieldSeparator   string   `toml:"avro_field_separator"`
+	AvroTimestamp        string   `toml:"avro_timestamp"`
+	AvroTimestampFormat  string   `toml:"avro_timestamp_format"`
+
+	// Binary configuration
+	BinaryEndianness string `toml:"binary_endianness"`
+	BinaryConfig     []binary.Config
+
 	// FormData configuration
 	FormUrlencodedTagKeys []string `toml:"form_urlencoded_tag_keys"`
 
+	// Protobuf configuration
+	ProtobufFiles            []string `toml:"protobuf_files"`
+	ProtobufImportPaths      []string `toml:"protobuf_import_paths"`
+	ProtobufMessageType      string   `toml:"protobuf_message_type"`
+	ProtobufLengthDelimited  bool     `toml:"protobuf_length_delimited"`
+	ProtobufMeasurementField string   `toml:"protobuf_measurement_field"`
+	ProtobufTags             []string `toml:"protobuf_tags"`
+	ProtobufFields           []string `toml:"protobuf_fields"`
+	ProtobufFieldSeparator   string   `toml:"protobuf_field_separator"`
+	ProtobufTimestamp        string   `toml:"protobuf_timestamp"`
+	ProtobufTimestampFormat  string   `toml:"protobuf_timestamp_format"`
+
 	// Prometheus configuration
 	PrometheusIgnoreTimestamp bool `toml:"prometheus_ignore_timestamp"`
 
@@ -279,9 +310,53 @@ func NewParser(config *Config) (Parser, error) {
 		}
 	case "json_v2":
 		parser, err = NewJSONPathParser(config.JSONV2Config)
+	case "binary":
+		parser = &binary.Parser{
+			MetricName:  config.MetricName,
+			Endianness:  config.BinaryEndianness,
+			Configs:     config.BinaryConfig,
+			DefaultTags: config.DefaultTags,
+		}
+	case "protobuf":
+		parser = &protobuf.Parser{
+			Config: protobufconfig.Config{
+				Files:       config.ProtobufFiles,
+				ImportPaths: config.ProtobufImportPaths,
+				MessageType: config.ProtobufMessageType,
+			},
+			MetricName:       config.MetricName,
+			LengthDelimited:  config.ProtobufLengthDelimited,
+			MeasurementField: config.ProtobufMeasurementField,
+			Tags:             config.ProtobufTags,
+			Fields:           config.ProtobufFields,
+			FieldSeparator:   config.ProtobufFieldSeparator,
+			Timestamp:        config.ProtobufTimestamp,
+			TimestampFormat:  config.ProtobufTimestampFormat,
+			DefaultTags:      config.DefaultTags,
+		}
+	case "avro":
+		parser = &avro.Parser{
+			MetricName:       config.MetricName,
+			SchemaRegistry:   config.AvroSchemaRegistry,
+			SchemaFile:       config.AvroSchemaFile,
+			Measurement:      config.AvroMeasurement,
+			MeasurementField: config.AvroMeasurementField,
+			Tags:             config.AvroTags,
+			Fields:           config.AvroFields,
+			FieldSeparator:   config.AvroFieldSeparator,
+			Timestamp:        config.AvroTimestamp,
+			TimestampFormat:  config.AvroTimestampFormat,
+			DefaultTags:      config.DefaultTags,
+		}
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
+
+	// Parsers created by SetParserFunc inputs are not initialized by the
+	// config, so initialize them here.
+	if p, ok := parser.(telegraf.Initializer); ok && err == nil {
+		err = p.Init()
+	}
 	return parser, err
 }
 
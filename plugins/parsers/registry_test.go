@@ -0,0 +1,71 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/plugins/parsers/binary"
+)
+
+// Parsers of SetParserFunc inputs are created by NewParser only, so they must
+// be ready for parsing without calling Init.
+func TestNewParserInitialized(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *Config
+		data     []byte
+		expected int
+	}{
+		{
+			name: "avro",
+			config: &Config{
+				DataFormat:     "avro",
+				MetricName:     "avro",
+				AvroSchemaFile: "avro/testdata/measurement.avsc",
+			},
+		},
+		{
+			name: "binary",
+			config: &Config{
+				DataFormat: "binary",
+				MetricName: "binary",
+				BinaryConfig: []binary.Config{{
+					Filter:  &binary.Filter{Selections: []binary.Selection{{Offset: 0, Match: "0x01"}}},
+					Entries: []binary.Entry{{Name: "value", Type: "uint8"}},
+				}},
+			},
+			data:     []byte{0x01},
+			expected: 1,
+		},
+		{
+			name: "protobuf",
+			config: &Config{
+				DataFormat:          "protobuf",
+				MetricName:          "protobuf",
+				ProtobufFiles:       []string{"../common/protobuf/testdata/measurement.proto"},
+				ProtobufMessageType: "telegraf.test.Measurement",
+			},
+			data:     []byte{0x21, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f},
+			expected: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewParser(tt.config)
+			require.NoError(t, err)
+			require.NotPanics(t, func() {
+				metrics, _ := parser.Parse(tt.data)
+				require.Len(t, metrics, tt.expected)
+			})
+		})
+	}
+}
+
+func TestNewParserInitErrors(t *testing.T) {
+	_, err := NewParser(&Config{DataFormat: "avro", MetricName: "avro"})
+	require.EqualError(t, err, "either a schema registry or a schema file is required")
+
+	_, err = NewParser(&Config{DataFormat: "protobuf", MetricName: "protobuf"})
+	require.EqualError(t, err, "no .proto files given")
+}
@@ -0,0 +1,8 @@
+package all
+
+import (
+	//Blank imports for plugins to register themselves
+	_ "github.com/influxdata/telegraf/plugins/secretstores/env"
+	_ "github.com/influxdata/telegraf/plugins/secretstores/exec"
+	_ "github.com/influxdata/telegraf/plugins/secretstores/file"
+)
@@ -0,0 +1,65 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+const sampleConfig = `
+  ## Unique identifier of the store, used to reference secrets as
+  ## @{<id>:<key>} in the configuration of other plugins.
+  id = "env"
+
+  ## Prefix of the environment variables holding the secrets.  The secret
+  ## "password" is read from the variable TELEGRAF_SECRET_password.
+  # prefix = "TELEGRAF_SECRET_"
+`
+
+// Env is a read-only secret store using environment variables.
+type Env struct {
+	Prefix string `toml:"prefix"`
+}
+
+func (e *Env) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Env) Description() string {
+	return "Read secrets from environment variables"
+}
+
+func (e *Env) Get(key string) ([]byte, error) {
+	value, ok := os.LookupEnv(e.Prefix + key)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %q not set", e.Prefix+key)
+	}
+	return []byte(value), nil
+}
+
+func (e *Env) Set(string, []byte) error {
+	return errors.New("setting secrets is not supported, set the environment variable instead")
+}
+
+func (e *Env) List() ([]string, error) {
+	var keys []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, e.Prefix) && len(name) > len(e.Prefix) {
+			keys = append(keys, strings.TrimPrefix(name, e.Prefix))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func init() {
+	secretstores.Add("env", func() telegraf.SecretStore {
+		return &Env{Prefix: "TELEGRAF_SECRET_"}
+	})
+}
@@ -0,0 +1,27 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnv(t *testing.T) {
+	t.Setenv("TEST_SECRET_password", "secret")
+	t.Setenv("TEST_SECRET_token", "abc")
+
+	store := &Env{Prefix: "TEST_SECRET_"}
+
+	value, err := store.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), value)
+
+	_, err = store.Get("missing")
+	require.Error(t, err)
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token"}, keys)
+
+	require.Error(t, store.Set("password", []byte("other")))
+}
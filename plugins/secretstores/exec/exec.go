@@ -0,0 +1,107 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+const sampleConfig = `
+  ## Unique identifier of the store, used to reference secrets as
+  ## @{<id>:<key>} in the configuration of other plugins.
+  id = "helper"
+
+  ## Command of the helper program managing the secrets.  The helper is called
+  ## with the following arguments appended:
+  ##   get <key>  -- print the secret to stdout
+  ##   set <key>  -- store the secret read from stdin
+  ##   list       -- print the keys of all secrets, one per line
+  command = ["/usr/local/bin/secret-helper"]
+
+  ## Timeout for the helper to complete.
+  # timeout = "5s"
+`
+
+// Exec is a secret store delegating to an external helper program.
+type Exec struct {
+	Command []string        `toml:"command"`
+	Timeout config.Duration `toml:"timeout"`
+}
+
+func (e *Exec) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Exec) Description() string {
+	return "Manage secrets using an external helper program"
+}
+
+func (e *Exec) Init() error {
+	if len(e.Command) == 0 {
+		return errors.New("command is required")
+	}
+	return nil
+}
+
+func (e *Exec) Get(key string) ([]byte, error) {
+	out, err := e.run(nil, "get", key)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(bytes.TrimSuffix(out, []byte("\n")), []byte("\r")), nil
+}
+
+func (e *Exec) Set(key string, value []byte) error {
+	_, err := e.run(value, "set", key)
+	return err
+}
+
+func (e *Exec) List() ([]string, error) {
+	out, err := e.run(nil, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if key := strings.TrimSpace(line); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// run calls the helper with the given arguments and returns its output.
+func (e *Exec) run(stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.Timeout))
+	defer cancel()
+
+	args = append(append([]string{}, e.Command[1:]...), args...)
+	cmd := exec.CommandContext(ctx, e.Command[0], args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", e.Command[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", e.Command[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+func init() {
+	secretstores.Add("exec", func() telegraf.SecretStore {
+		return &Exec{Timeout: config.Duration(5 * time.Second)}
+	})
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package exec
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/stretchr/testify/require"
+)
+
+const helper = `#!/bin/sh
+dir=$(dirname "$0")/secrets
+case "$1" in
+get)
+	[ -f "$dir/$2" ] || { echo "secret $2 not found" >&2; exit 1; }
+	cat "$dir/$2"; echo ;;
+set)
+	mkdir -p "$dir"; cat > "$dir/$2" ;;
+list)
+	ls "$dir" ;;
+esac
+`
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "helper.sh")
+	require.NoError(t, ioutil.WriteFile(path, []byte(helper), 0700))
+
+	store := &Exec{
+		Command: []string{path},
+		Timeout: config.Duration(5 * time.Second),
+	}
+	require.NoError(t, store.Init())
+
+	require.NoError(t, store.Set("password", []byte("secret")))
+	require.NoError(t, store.Set("token", []byte("abc")))
+	require.FileExists(t, filepath.Join(dir, "secrets", "password"))
+
+	value, err := store.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), value)
+
+	_, err = store.Get("missing")
+	require.EqualError(t, err, path+" failed: exit status 1: secret missing not found")
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token"}, keys)
+}
+
+func TestExecInit(t *testing.T) {
+	store := &Exec{}
+	require.Error(t, store.Init())
+}
@@ -0,0 +1,231 @@
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+	"golang.org/x/crypto/scrypt"
+)
+
+const sampleConfig = `
+  ## Unique identifier of the store, used to reference secrets as
+  ## @{<id>:<key>} in the configuration of other plugins.
+  id = "local"
+
+  ## File to store the secrets in, the file is created with permissions
+  ## restricted to the current user.
+  path = "/etc/telegraf/secrets.json"
+
+  ## Password used to encrypt the secrets.  If empty, the secrets are stored
+  ## unencrypted and only protected by the file permissions.
+  # password = "$TELEGRAF_SECRETS_PASSWORD"
+`
+
+const saltSize = 16
+
+// storeFile is the content of the secrets file.
+type storeFile struct {
+	// Salt of the key derived from the password, empty if the secrets are not
+	// encrypted.
+	Salt    []byte            `json:"salt,omitempty"`
+	Secrets map[string][]byte `json:"secrets"`
+}
+
+// File is a secret store persisting the secrets in a local file, optionally
+// encrypted using a password.
+type File struct {
+	Path     string `toml:"path"`
+	Password string `toml:"password"`
+
+	aead cipher.AEAD
+	salt []byte
+	mu   sync.Mutex
+}
+
+func (f *File) SampleConfig() string {
+	return sampleConfig
+}
+
+func (f *File) Description() string {
+	return "Store secrets in a local, optionally encrypted file"
+}
+
+func (f *File) Init() error {
+	if f.Path == "" {
+		return errors.New("path is required")
+	}
+
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case f.Password == "" && content.Salt != nil:
+		return fmt.Errorf("secrets in %q are encrypted but no password is set", f.Path)
+	case f.Password != "" && content.Salt == nil && len(content.Secrets) > 0:
+		return fmt.Errorf("secrets in %q are not encrypted but a password is set", f.Path)
+	case f.Password == "":
+		return nil
+	}
+
+	f.salt = content.Salt
+	if f.salt == nil {
+		f.salt = make([]byte, saltSize)
+		if _, err := rand.Read(f.salt); err != nil {
+			return err
+		}
+	}
+
+	key, err := scrypt.Key([]byte(f.Password), f.salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	f.aead, err = cipher.NewGCM(block)
+	return err
+}
+
+func (f *File) Get(key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	value, ok := content.Secrets[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %q not found", key)
+	}
+	return f.decrypt(value)
+}
+
+func (f *File) Set(key string, value []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := f.encrypt(value)
+	if err != nil {
+		return err
+	}
+	content.Salt = f.salt
+	content.Secrets[key] = encrypted
+	return f.write(content)
+}
+
+func (f *File) List() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(content.Secrets))
+	for key := range content.Secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (f *File) encrypt(value []byte) ([]byte, error) {
+	if f.aead == nil {
+		return value, nil
+	}
+
+	nonce := make([]byte, f.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return f.aead.Seal(nonce, nonce, value, nil), nil
+}
+
+func (f *File) decrypt(value []byte) ([]byte, error) {
+	if f.aead == nil {
+		return value, nil
+	}
+
+	size := f.aead.NonceSize()
+	if len(value) < size {
+		return nil, errors.New("invalid encrypted secret")
+	}
+	plain, err := f.aead.Open(nil, value[:size], value[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting secret failed, wrong password? %w", err)
+	}
+	return plain, nil
+}
+
+// read returns the content of the secrets file, or an empty store if the file
+// does not exist.
+func (f *File) read() (*storeFile, error) {
+	content := &storeFile{}
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, content); err != nil {
+			return nil, fmt.Errorf("parsing %q failed: %w", f.Path, err)
+		}
+	}
+	if content.Secrets == nil {
+		content.Secrets = make(map[string][]byte)
+	}
+	return content, nil
+}
+
+// write replaces the secrets file with the given content.
+func (f *File) write(content *storeFile) error {
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+func init() {
+	secretstores.Add("file", func() telegraf.SecretStore {
+		return &File{}
+	})
+}
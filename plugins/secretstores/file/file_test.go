@@ -0,0 +1,72 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+
+	store := &File{Path: path}
+	require.NoError(t, store.Init())
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	require.NoError(t, store.Set("password", []byte("secret")))
+	require.NoError(t, store.Set("token", []byte("abc")))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	value, err := store.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), value)
+
+	_, err = store.Get("missing")
+	require.Error(t, err)
+
+	keys, err = store.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token"}, keys)
+
+	// A password cannot be used for an unencrypted store
+	store = &File{Path: path, Password: "password"}
+	require.Error(t, store.Init())
+}
+
+func TestFileEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+
+	store := &File{Path: path, Password: "password"}
+	require.NoError(t, store.Init())
+	require.NoError(t, store.Set("password", []byte("secret")))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "c2VjcmV0") // base64 of "secret"
+
+	// Reopen the store
+	store = &File{Path: path, Password: "password"}
+	require.NoError(t, store.Init())
+	value, err := store.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), value)
+
+	store = &File{Path: path, Password: "wrong"}
+	require.NoError(t, store.Init())
+	_, err = store.Get("password")
+	require.Error(t, err)
+
+	store = &File{Path: path}
+	require.Error(t, store.Init())
+}
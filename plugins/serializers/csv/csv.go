@@ -0,0 +1,296 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Column specifications of the csv_columns option.
+const (
+	columnTimestamp   = "timestamp"
+	columnMeasurement = "measurement"
+	tagPrefix         = "tag."
+	fieldPrefix       = "field."
+	allTags           = "tag.*"
+	allFields         = "field.*"
+)
+
+// DefaultColumns are the columns written if no columns are configured: the
+// timestamp and measurement followed by all tags and all fields in
+// alphabetical order.
+var DefaultColumns = []string{columnTimestamp, columnMeasurement, allTags, allFields}
+
+type columnKind int
+
+const (
+	timestampColumn columnKind = iota
+	measurementColumn
+	tagColumn
+	fieldColumn
+)
+
+type column struct {
+	kind columnKind
+	key  string
+}
+
+func (c column) name() string {
+	switch c.kind {
+	case timestampColumn:
+		return columnTimestamp
+	case measurementColumn:
+		return columnMeasurement
+	default:
+		return c.key
+	}
+}
+
+type Serializer struct {
+	specs           []string
+	header          bool
+	separator       rune
+	timestampFormat string
+
+	// Columns of the metrics serialized one by one, covering the tags and
+	// fields of all metrics passed to Serialize so far.
+	columns   []column
+	tagKeys   map[string]bool
+	fieldKeys map[string]bool
+}
+
+func NewSerializer(columns []string, header bool, separator string, timestampFormat string) (*Serializer, error) {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	for _, c := range columns {
+		switch {
+		case c == columnTimestamp, c == columnMeasurement:
+		case strings.HasPrefix(c, tagPrefix) && len(c) > len(tagPrefix):
+		case strings.HasPrefix(c, fieldPrefix) && len(c) > len(fieldPrefix):
+		default:
+			return nil, fmt.Errorf("invalid column %q", c)
+		}
+	}
+
+	if separator == "" {
+		separator = ","
+	}
+	sep, size := utf8.DecodeRuneInString(separator)
+	if size != len(separator) || sep == utf8.RuneError || sep == '"' || sep == '\r' || sep == '\n' {
+		return nil, fmt.Errorf("invalid separator %q, must be a single character", separator)
+	}
+
+	if timestampFormat == "" {
+		timestampFormat = "unix"
+	}
+
+	return &Serializer{
+		specs:           columns,
+		header:          header,
+		separator:       sep,
+		timestampFormat: timestampFormat,
+		tagKeys:         make(map[string]bool),
+		fieldKeys:       make(map[string]bool),
+	}, nil
+}
+
+// Serialize writes the metric as a row.  The columns include the tags and
+// fields of all metrics serialized so far.  The header is written before the
+// first row and again whenever a metric adds columns.
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	added := s.columns == nil
+	for _, tag := range metric.TagList() {
+		if !s.tagKeys[tag.Key] {
+			s.tagKeys[tag.Key] = true
+			added = true
+		}
+	}
+	for _, field := range metric.FieldList() {
+		if !s.fieldKeys[field.Key] {
+			s.fieldKeys[field.Key] = true
+			added = true
+		}
+	}
+
+	var buf bytes.Buffer
+	w := s.newWriter(&buf)
+	if added {
+		columns := s.buildColumns(s.tagKeys, s.fieldKeys)
+		if s.columns == nil || !equalColumns(columns, s.columns) {
+			s.columns = columns
+			if s.header {
+				if err := w.Write(header(s.columns)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := w.Write(s.row(metric, s.columns)); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// SerializeBatch writes the metrics as a complete document, starting with the
+// header.  The columns include the tags and fields of all metrics of the batch
+// and are left empty for metrics without the tag or field.
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	tagKeys := make(map[string]bool)
+	fieldKeys := make(map[string]bool)
+	for _, m := range metrics {
+		for _, tag := range m.TagList() {
+			tagKeys[tag.Key] = true
+		}
+		for _, field := range m.FieldList() {
+			fieldKeys[field.Key] = true
+		}
+	}
+	columns := s.buildColumns(tagKeys, fieldKeys)
+
+	var buf bytes.Buffer
+	w := s.newWriter(&buf)
+	if s.header {
+		if err := w.Write(header(columns)); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range metrics {
+		if err := w.Write(s.row(m, columns)); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (s *Serializer) newWriter(buf *bytes.Buffer) *csv.Writer {
+	w := csv.NewWriter(buf)
+	w.Comma = s.separator
+	return w
+}
+
+// buildColumns expands the column specifications, adding the tags and fields
+// not listed explicitly in alphabetical order for the tag.* and field.*
+// wildcards.
+func (s *Serializer) buildColumns(tagKeys, fieldKeys map[string]bool) []column {
+	listed := make(map[string]bool, len(s.specs))
+	for _, spec := range s.specs {
+		listed[spec] = true
+	}
+
+	columns := make([]column, 0, len(s.specs)+len(tagKeys)+len(fieldKeys))
+	for _, spec := range s.specs {
+		switch {
+		case spec == columnTimestamp:
+			columns = append(columns, column{kind: timestampColumn})
+		case spec == columnMeasurement:
+			columns = append(columns, column{kind: measurementColumn})
+		case spec == allTags:
+			for _, key := range remaining(tagKeys, tagPrefix, listed) {
+				columns = append(columns, column{kind: tagColumn, key: key})
+			}
+		case spec == allFields:
+			for _, key := range remaining(fieldKeys, fieldPrefix, listed) {
+				columns = append(columns, column{kind: fieldColumn, key: key})
+			}
+		case strings.HasPrefix(spec, tagPrefix):
+			columns = append(columns, column{kind: tagColumn, key: strings.TrimPrefix(spec, tagPrefix)})
+		case strings.HasPrefix(spec, fieldPrefix):
+			columns = append(columns, column{kind: fieldColumn, key: strings.TrimPrefix(spec, fieldPrefix)})
+		}
+	}
+	return columns
+}
+
+func remaining(keys map[string]bool, prefix string, listed map[string]bool) []string {
+	result := make([]string, 0, len(keys))
+	for key := range keys {
+		if !listed[prefix+key] {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func equalColumns(a, b []column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func header(columns []column) []string {
+	record := make([]string, 0, len(columns))
+	for _, c := range columns {
+		record = append(record, c.name())
+	}
+	return record
+}
+
+func (s *Serializer) row(metric telegraf.Metric, columns []column) []string {
+	record := make([]string, 0, len(columns))
+	for _, c := range columns {
+		switch c.kind {
+		case timestampColumn:
+			record = append(record, s.formatTimestamp(metric))
+		case measurementColumn:
+			record = append(record, metric.Name())
+		case tagColumn:
+			v, _ := metric.GetTag(c.key)
+			record = append(record, v)
+		case fieldColumn:
+			v, _ := metric.GetField(c.key)
+			record = append(record, formatValue(v))
+		}
+	}
+	return record
+}
+
+func (s *Serializer) formatTimestamp(metric telegraf.Metric) string {
+	t := metric.Time()
+	switch s.timestampFormat {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix_ms":
+		return strconv.FormatInt(t.UnixNano()/1e6, 10)
+	case "unix_us":
+		return strconv.FormatInt(t.UnixNano()/1e3, 10)
+	case "unix_ns":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.UTC().Format(s.timestampFormat)
+	}
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
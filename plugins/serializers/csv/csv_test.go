@@ -0,0 +1,127 @@
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+var metrics = []telegraf.Metric{
+	testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "server01", "cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 91.5, "usage_user": 2.25},
+		time.Unix(1600000000, 0),
+	),
+	testutil.MustMetric(
+		"disk",
+		map[string]string{"host": "server01", "path": "/"},
+		map[string]interface{}{"free": int64(1024), "mounted": true, "fstype": "ext4"},
+		time.Unix(1600000010, 0),
+	),
+}
+
+func TestSerializeBatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		columns         []string
+		header          bool
+		separator       string
+		timestampFormat string
+		expected        string
+	}{
+		{
+			name: "default columns",
+			expected: "1600000000,cpu,cpu0,server01,,,,,91.5,2.25\n" +
+				"1600000010,disk,,server01,/,1024,ext4,true,,\n",
+		},
+		{
+			name:   "header",
+			header: true,
+			expected: "timestamp,measurement,cpu,host,path,free,fstype,mounted,usage_idle,usage_user\n" +
+				"1600000000,cpu,cpu0,server01,,,,,91.5,2.25\n" +
+				"1600000010,disk,,server01,/,1024,ext4,true,,\n",
+		},
+		{
+			name:            "column order and timestamp format",
+			columns:         []string{"tag.host", "measurement", "field.*", "timestamp"},
+			header:          true,
+			separator:       ";",
+			timestampFormat: time.RFC3339,
+			expected: "host;measurement;free;fstype;mounted;usage_idle;usage_user;timestamp\n" +
+				"server01;cpu;;;;91.5;2.25;2020-09-13T12:26:40Z\n" +
+				"server01;disk;1024;ext4;true;;;2020-09-13T12:26:50Z\n",
+		},
+		{
+			name:            "selected columns",
+			columns:         []string{"timestamp", "field.usage_idle", "tag.*"},
+			timestampFormat: "unix_ms",
+			expected: "1600000000000,91.5,cpu0,server01,\n" +
+				"1600000010000,,,server01,/\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSerializer(tt.columns, tt.header, tt.separator, tt.timestampFormat)
+			require.NoError(t, err)
+
+			buf, err := s.SerializeBatch(metrics)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(buf))
+		})
+	}
+}
+
+func TestSerializeGrowingColumns(t *testing.T) {
+	s, err := NewSerializer([]string{"measurement", "field.*"}, true, "", "")
+	require.NoError(t, err)
+
+	m := testutil.MustMetric("test", nil, map[string]interface{}{"a": int64(1)}, time.Unix(0, 0))
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "measurement,a\ntest,1\n", string(buf))
+
+	buf, err = s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "test,1\n", string(buf))
+
+	// A metric adding a column is preceded by the new header
+	m = testutil.MustMetric("test", nil, map[string]interface{}{"a": int64(2), "b": "x,y"}, time.Unix(0, 0))
+	buf, err = s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "measurement,a,b\ntest,2,\"x,y\"\n", string(buf))
+
+	m = testutil.MustMetric("test", nil, map[string]interface{}{"b": "x,y"}, time.Unix(0, 0))
+	buf, err = s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "test,,\"x,y\"\n", string(buf))
+}
+
+func TestSerializeExplicitColumns(t *testing.T) {
+	s, err := NewSerializer([]string{"measurement", "field.a"}, true, "", "")
+	require.NoError(t, err)
+
+	m := testutil.MustMetric("test", nil, map[string]interface{}{"a": int64(1)}, time.Unix(0, 0))
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "measurement,a\ntest,1\n", string(buf))
+
+	// Fields without a configured column do not change the header
+	m = testutil.MustMetric("test", nil, map[string]interface{}{"a": int64(2), "b": "x"}, time.Unix(0, 0))
+	buf, err = s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "test,2\n", string(buf))
+}
+
+func TestInvalidOptions(t *testing.T) {
+	_, err := NewSerializer([]string{"host"}, false, "", "")
+	require.EqualError(t, err, `invalid column "host"`)
+
+	_, err = NewSerializer(nil, false, "::", "")
+	require.EqualError(t, err, `invalid separator "::", must be a single character`)
+}
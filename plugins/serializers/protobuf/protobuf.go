@@ -0,0 +1,250 @@
+package protobuf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/templating"
+	"github.com/influxdata/telegraf/plugins/common/protobuf"
+)
+
+// Serializer encodes metrics as protocol-buffer messages of the type defined
+// in .proto files.  Tags and fields are set on the message fields of the same
+// name, nested messages are addressed by joining the field names with the
+// separator.
+type Serializer struct {
+	protobuf.Config
+
+	LengthDelimited  bool
+	MeasurementField string
+	Timestamp        string
+	TimestampFormat  string
+	FieldSeparator   string
+
+	msgType protoreflect.MessageType
+}
+
+func (s *Serializer) Init() error {
+	md, err := s.LoadMessageDescriptor()
+	if err != nil {
+		return err
+	}
+	s.msgType = dynamicpb.NewMessageType(md)
+
+	if s.FieldSeparator == "" {
+		s.FieldSeparator = "_"
+	}
+	if s.TimestampFormat == "" {
+		s.TimestampFormat = "unix"
+	}
+	for _, name := range []string{s.MeasurementField, s.Timestamp} {
+		if name == "" {
+			continue
+		}
+		if path := s.resolve(md, name); path == nil {
+			return fmt.Errorf("field %q not found in message %q", name, md.FullName())
+		}
+	}
+	return nil
+}
+
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	msg, err := s.createMessage(metric)
+	if err != nil {
+		return nil, err
+	}
+	if s.LengthDelimited {
+		return appendDelimited(nil, msg)
+	}
+	return proto.Marshal(msg.Interface())
+}
+
+// SerializeBatch encodes the metrics as stream of length-delimited messages.
+// Without length delimiters the messages cannot be told apart, so only a
+// single metric can be encoded.
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	if !s.LengthDelimited {
+		if len(metrics) != 1 {
+			return nil, fmt.Errorf("serializing a batch of %d metrics requires protobuf_length_delimited", len(metrics))
+		}
+		return s.Serialize(metrics[0])
+	}
+
+	var buf []byte
+	for _, m := range metrics {
+		msg, err := s.createMessage(m)
+		if err != nil {
+			return nil, err
+		}
+		if buf, err = appendDelimited(buf, msg); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendDelimited(buf []byte, msg protoreflect.Message) ([]byte, error) {
+	data, err := proto.Marshal(msg.Interface())
+	if err != nil {
+		return nil, err
+	}
+	buf = protowire.AppendVarint(buf, uint64(len(data)))
+	return append(buf, data...), nil
+}
+
+func (s *Serializer) createMessage(metric telegraf.Metric) (protoreflect.Message, error) {
+	msg := s.msgType.New()
+
+	if s.MeasurementField != "" {
+		if err := s.set(msg, s.MeasurementField, metric.Name()); err != nil {
+			return nil, err
+		}
+	}
+	if s.Timestamp != "" {
+		if err := s.set(msg, s.Timestamp, metric.Time()); err != nil {
+			return nil, err
+		}
+	}
+	for _, tag := range metric.TagList() {
+		if err := s.set(msg, tag.Key, tag.Value); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range metric.FieldList() {
+		if err := s.set(msg, field.Key, field.Value); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// set sets the message field of the name to the value, ignoring names without
+// matching field.
+func (s *Serializer) set(msg protoreflect.Message, name string, value interface{}) error {
+	path := s.resolve(msg.Descriptor(), name)
+	if path == nil {
+		return nil
+	}
+	for _, fd := range path[:len(path)-1] {
+		msg = msg.Mutable(fd).Message()
+	}
+	fd := path[len(path)-1]
+
+	v, err := s.convert(fd, value)
+	if err != nil {
+		return fmt.Errorf("setting field %q failed: %v", name, err)
+	}
+	msg.Set(fd, v)
+	return nil
+}
+
+// resolve returns the path of message fields leading to the singular field of
+// the name, nil if there is no such field.
+func (s *Serializer) resolve(md protoreflect.MessageDescriptor, name string) []protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil && settable(fd) {
+		return []protoreflect.FieldDescriptor{fd}
+	}
+
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() || isTimestamp(fd) {
+			continue
+		}
+		prefix := string(fd.Name()) + s.FieldSeparator
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if path := s.resolve(fd.Message(), strings.TrimPrefix(name, prefix)); path != nil {
+			return append([]protoreflect.FieldDescriptor{fd}, path...)
+		}
+	}
+	return nil
+}
+
+func settable(fd protoreflect.FieldDescriptor) bool {
+	if fd.IsList() || fd.IsMap() {
+		return false
+	}
+	return fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind || isTimestamp(fd)
+}
+
+func isTimestamp(fd protoreflect.FieldDescriptor) bool {
+	return fd.Kind() == protoreflect.MessageKind && fd.Message().FullName() == protobuf.TimestampMessage
+}
+
+func (s *Serializer) convert(fd protoreflect.FieldDescriptor, value interface{}) (protoreflect.Value, error) {
+	if t, ok := value.(time.Time); ok {
+		switch {
+		case isTimestamp(fd):
+			ts := dynamicpb.NewMessage(fd.Message())
+			ts.Set(fd.Message().Fields().ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+			ts.Set(fd.Message().Fields().ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+			return protoreflect.ValueOfMessage(ts), nil
+		case fd.Kind() == protoreflect.StringKind:
+			value = templating.FormatTime(s.TimestampFormat, t)
+		default:
+			switch s.TimestampFormat {
+			case "unix_ms":
+				value = t.UnixNano() / 1e6
+			case "unix_us":
+				value = t.UnixNano() / 1e3
+			case "unix_ns":
+				value = t.UnixNano()
+			default:
+				value = t.Unix()
+			}
+		}
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v, err := internal.ToBool(value)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := internal.ToInt64(value)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := internal.ToInt64(value)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := internal.ToUint64(value)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := internal.ToUint64(value)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := internal.ToFloat64(value)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := internal.ToFloat64(value)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.StringKind:
+		v, err := internal.ToString(value)
+		return protoreflect.ValueOfString(v), err
+	case protoreflect.BytesKind:
+		v, err := internal.ToString(value)
+		return protoreflect.ValueOfBytes([]byte(v)), err
+	case protoreflect.EnumKind:
+		if name, ok := value.(string); ok {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(name)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+		}
+		v, err := internal.ToInt64(value)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("invalid value %v for enum %q", value, fd.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported type %v", fd.Kind())
+	}
+}
@@ -0,0 +1,145 @@
+package protobuf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/protobuf"
+	parser "github.com/influxdata/telegraf/plugins/parsers/protobuf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+var config = protobuf.Config{
+	Files:       []string{"../../common/protobuf/testdata/measurement.proto"},
+	MessageType: "telegraf.test.Measurement",
+}
+
+var metrics = []telegraf.Metric{
+	testutil.MustMetric(
+		"system",
+		map[string]string{"host": "server01", "status": "FAILED", "unknown": "ignored"},
+		map[string]interface{}{
+			"value":      42.5,
+			"count":      int64(3),
+			"bytes":      uint64(1024),
+			"running":    true,
+			"cpu_user":   1.5,
+			"cpu_system": 0.25,
+		},
+		time.Unix(1600000000, 0),
+	),
+	testutil.MustMetric(
+		"system",
+		map[string]string{"host": "server02", "status": "OK"},
+		map[string]interface{}{"value": 1.0, "count": "7"},
+		time.Unix(1600000010, 0),
+	),
+}
+
+func newParser(t *testing.T, lengthDelimited bool) *parser.Parser {
+	p := &parser.Parser{
+		Config:           config,
+		MetricName:       "protobuf",
+		LengthDelimited:  lengthDelimited,
+		MeasurementField: "name",
+		Tags:             []string{"host", "status"},
+		Fields:           []string{"value", "count", "bytes", "running", "cpu_user", "cpu_system"},
+		Timestamp:        "time",
+	}
+	require.NoError(t, p.Init())
+	return p
+}
+
+func TestSerialize(t *testing.T) {
+	s := &Serializer{
+		Config:           config,
+		MeasurementField: "name",
+		Timestamp:        "time",
+	}
+	require.NoError(t, s.Init())
+
+	buf, err := s.Serialize(metrics[0])
+	require.NoError(t, err)
+
+	actual, err := newParser(t, false).Parse(buf)
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"system",
+			map[string]string{"host": "server01", "status": "FAILED"},
+			map[string]interface{}{
+				"value":      42.5,
+				"count":      int64(3),
+				"bytes":      uint64(1024),
+				"running":    true,
+				"cpu_user":   1.5,
+				"cpu_system": 0.25,
+			},
+			time.Unix(1600000000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	_, err = s.SerializeBatch(metrics)
+	require.EqualError(t, err, "serializing a batch of 2 metrics requires protobuf_length_delimited")
+}
+
+func TestSerializeBatchLengthDelimited(t *testing.T) {
+	s := &Serializer{
+		Config:           config,
+		LengthDelimited:  true,
+		MeasurementField: "name",
+		Timestamp:        "time",
+	}
+	require.NoError(t, s.Init())
+
+	buf, err := s.SerializeBatch(metrics)
+	require.NoError(t, err)
+
+	actual, err := newParser(t, true).Parse(buf)
+	require.NoError(t, err)
+	require.Len(t, actual, 2)
+	require.Equal(t, map[string]string{"host": "server02", "status": "OK"}, actual[1].Tags())
+	require.Equal(t, int64(7), actual[1].Fields()["count"])
+	require.Equal(t, time.Unix(1600000010, 0).UnixNano(), actual[1].Time().UnixNano())
+}
+
+func TestSerializeIntegerTimestamp(t *testing.T) {
+	s := &Serializer{
+		Config:          config,
+		Timestamp:       "created",
+		TimestampFormat: "unix_ms",
+	}
+	require.NoError(t, s.Init())
+
+	buf, err := s.Serialize(metrics[1])
+	require.NoError(t, err)
+
+	p := &parser.Parser{
+		Config:          config,
+		MetricName:      "protobuf",
+		Fields:          []string{"value"},
+		Timestamp:       "created",
+		TimestampFormat: "unix_ms",
+	}
+	require.NoError(t, p.Init())
+	actual, err := p.Parse(buf)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, time.Unix(1600000010, 0).UnixNano(), actual[0].Time().UnixNano())
+}
+
+func TestSerializeErrors(t *testing.T) {
+	s := &Serializer{Config: config, Timestamp: "missing"}
+	require.EqualError(t, s.Init(), `field "missing" not found in message "telegraf.test.Measurement"`)
+
+	s = &Serializer{Config: config}
+	require.NoError(t, s.Init())
+	m := testutil.MustMetric("test", nil, map[string]interface{}{"count": "many"}, time.Unix(0, 0))
+	_, err := s.Serialize(m)
+	require.Error(t, err)
+}
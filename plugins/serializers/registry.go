@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	protobufconfig "github.com/influxdata/telegraf/plugins/common/protobuf"
 	"github.com/influxdata/telegraf/plugins/serializers/carbon2"
+	"github.com/influxdata/telegraf/plugins/serializers/csv"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
@@ -13,7 +15,9 @@ import (
 	"github.com/influxdata/telegraf/plugins/serializers/nowmetric"
 	"github.com/influxdata/telegraf/plugins/serializers/prometheus"
 	"github.com/influxdata/telegraf/plugins/serializers/prometheusremotewrite"
+	"github.com/influxdata/telegraf/plugins/serializers/protobuf"
 	"github.com/influxdata/telegraf/plugins/serializers/splunkmetric"
+	"github.com/influxdata/telegraf/plugins/serializers/template"
 	"github.com/influxdata/telegraf/plugins/serializers/wavefront"
 )
 
@@ -56,6 +60,18 @@ type Config struct {
 	// Character used for metric name sanitization in Carbon2.
 	Carbon2SanitizeReplaceChar string `toml:"carbon2_sanitize_replace_char"`
 
+	// Columns of the CSV output in the order to write them
+	CSVColumns []string `toml:"csv_columns"`
+
+	// Write a header row with the column names in CSV output
+	CSVHeader bool `toml:"csv_header"`
+
+	// Separator of the columns in CSV output
+	CSVSeparator string `toml:"csv_separator"`
+
+	// Timestamp format to use for CSV output
+	CSVTimestampFormat string `toml:"csv_timestamp_format"`
+
 	// Support tags in graphite protocol
 	GraphiteTagSupport bool `toml:"graphite_tag_support"`
 
@@ -78,10 +94,13 @@ type Config struct {
 	// Prefix to add to all measurements, only supports Graphite
 	Prefix string `toml:"prefix"`
 
-	// Template for converting telegraf metrics into Graphite
-	// only supports Graphite
+	// Template for converting telegraf metrics into Graphite, or Go template
+	// rendering each metric for the Template format
 	Template string `toml:"template"`
 
+	// Template rendering a batch of metrics, only supports Template
+	BatchTemplate string `toml:"batch_template"`
+
 	// Templates same Template, but multiple
 	Templates []string `toml:"templates"`
 
@@ -104,6 +123,22 @@ type Config struct {
 	// When enabled forward slash (/) and comma (,) will be accepted
 	WavefrontUseStrict bool `toml:"wavefront_use_strict"`
 
+	// Protocol-buffer definitions and message type for protobuf output
+	ProtobufFiles       []string `toml:"protobuf_files"`
+	ProtobufImportPaths []string `toml:"protobuf_import_paths"`
+	ProtobufMessageType string   `toml:"protobuf_message_type"`
+
+	// Prefix each protobuf message with its length
+	ProtobufLengthDelimited bool `toml:"protobuf_length_delimited"`
+
+	// Message fields receiving the metric name and time for protobuf output
+	ProtobufMeasurementField string `toml:"protobuf_measurement_field"`
+	ProtobufTimestamp        string `toml:"protobuf_timestamp"`
+	ProtobufTimestampFormat  string `toml:"protobuf_timestamp_format"`
+
+	// Separator joining the names of nested protobuf messages
+	ProtobufFieldSeparator string `toml:"protobuf_field_separator"`
+
 	// Include the metric timestamp on each sample.
 	PrometheusExportTimestamp bool `toml:"prometheus_export_timestamp"`
 
@@ -141,6 +176,12 @@ func NewSerializer(config *Config) (Serializer, error) {
 		serializer, err = NewPrometheusRemoteWriteSerializer(config)
 	case "msgpack":
 		serializer, err = NewMsgpackSerializer()
+	case "csv":
+		serializer, err = NewCSVSerializer(config.CSVColumns, config.CSVHeader, config.CSVSeparator, config.CSVTimestampFormat)
+	case "protobuf":
+		serializer, err = NewProtobufSerializer(config)
+	case "template":
+		serializer, err = NewTemplateSerializer(config.Template, config.BatchTemplate)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -195,6 +236,33 @@ func NewJSONSerializer(timestampUnits time.Duration, timestampFormat string) (Se
 	return json.NewSerializer(timestampUnits, timestampFormat)
 }
 
+func NewCSVSerializer(columns []string, header bool, separator string, timestampFormat string) (Serializer, error) {
+	return csv.NewSerializer(columns, header, separator, timestampFormat)
+}
+
+func NewProtobufSerializer(config *Config) (Serializer, error) {
+	s := &protobuf.Serializer{
+		Config: protobufconfig.Config{
+			Files:       config.ProtobufFiles,
+			ImportPaths: config.ProtobufImportPaths,
+			MessageType: config.ProtobufMessageType,
+		},
+		LengthDelimited:  config.ProtobufLengthDelimited,
+		MeasurementField: config.ProtobufMeasurementField,
+		Timestamp:        config.ProtobufTimestamp,
+		TimestampFormat:  config.ProtobufTimestampFormat,
+		FieldSeparator:   config.ProtobufFieldSeparator,
+	}
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func NewTemplateSerializer(metricTemplate string, batchTemplate string) (Serializer, error) {
+	return template.NewSerializer(metricTemplate, batchTemplate)
+}
+
 func NewCarbon2Serializer(carbon2format string, carbon2SanitizeReplaceChar string) (Serializer, error) {
 	return carbon2.NewSerializer(carbon2format, carbon2SanitizeReplaceChar)
 }
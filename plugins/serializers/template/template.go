@@ -0,0 +1,83 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/templating"
+)
+
+type Serializer struct {
+	tmpl      *template.Template
+	batchTmpl *template.Template
+}
+
+func NewSerializer(metricTemplate string, batchTemplate string) (*Serializer, error) {
+	if metricTemplate == "" && batchTemplate == "" {
+		return nil, errors.New("either a template or a batch template is required")
+	}
+
+	s := &Serializer{}
+	if metricTemplate != "" {
+		tmpl, err := template.New("template").Funcs(templating.FuncMap).Parse(metricTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template failed: %v", err)
+		}
+		s.tmpl = tmpl
+	}
+	if batchTemplate != "" {
+		tmpl, err := template.New("batch_template").Funcs(templating.FuncMap).Parse(batchTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("parsing batch template failed: %v", err)
+		}
+		s.batchTmpl = tmpl
+	}
+	return s, nil
+}
+
+// Serialize renders the metric with the template, terminated by a newline.
+// Without a template the batch template is rendered for the single metric.
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	if s.tmpl == nil {
+		return s.SerializeBatch([]telegraf.Metric{metric})
+	}
+
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, templating.NewMetric(metric)); err != nil {
+		return nil, err
+	}
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// SerializeBatch renders the metrics with the batch template.  Without a
+// batch template each metric is serialized on its own.
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	if s.batchTmpl == nil {
+		var batch bytes.Buffer
+		for _, m := range metrics {
+			buf, err := s.Serialize(m)
+			if err != nil {
+				return nil, err
+			}
+			batch.Write(buf)
+		}
+		return batch.Bytes(), nil
+	}
+
+	wrapped := make([]*templating.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		wrapped = append(wrapped, templating.NewMetric(m))
+	}
+
+	var buf bytes.Buffer
+	if err := s.batchTmpl.Execute(&buf, wrapped); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,77 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+var metrics = []telegraf.Metric{
+	testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "server01", "cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 91.5, "usage_user": int64(2)},
+		time.Unix(1600000000, 0),
+	),
+	testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "server01", "cpu": "cpu1"},
+		map[string]interface{}{"usage_idle": 89.25},
+		time.Unix(1600000010, 0),
+	),
+}
+
+func TestSerialize(t *testing.T) {
+	s, err := NewSerializer(`{{ .Name | upper }} {{ .Tag "host" }}/{{ .Tag "cpu" }} idle={{ .Field "usage_idle" }} at {{ .Time | formatTime "unix_ms" }}`, "")
+	require.NoError(t, err)
+
+	buf, err := s.Serialize(metrics[0])
+	require.NoError(t, err)
+	require.Equal(t, "CPU server01/cpu0 idle=91.5 at 1600000000000\n", string(buf))
+
+	buf, err = s.SerializeBatch(metrics)
+	require.NoError(t, err)
+	require.Equal(t, "CPU server01/cpu0 idle=91.5 at 1600000000000\n"+
+		"CPU server01/cpu1 idle=89.25 at 1600000010000\n", string(buf))
+}
+
+func TestSerializeTagsAndFields(t *testing.T) {
+	s, err := NewSerializer(`{{ .Name }}{{ range $k, $v := .Tags }} {{ $k }}={{ quote $v }}{{ end }} |{{ range $k, $v := .Fields }} {{ $k }}={{ $v }}{{ end }}
+`, "")
+	require.NoError(t, err)
+
+	buf, err := s.Serialize(metrics[0])
+	require.NoError(t, err)
+	require.Equal(t, `cpu cpu="cpu0" host="server01" | usage_idle=91.5 usage_user=2`+"\n", string(buf))
+}
+
+func TestSerializeBatchTemplate(t *testing.T) {
+	batch := `{{ len . }} metrics:{{ range . }} {{ .Tag "cpu" }}@{{ .Time | formatTime "2006-01-02T15:04:05Z07:00" }}{{ end }}`
+	s, err := NewSerializer("", batch)
+	require.NoError(t, err)
+
+	buf, err := s.SerializeBatch(metrics)
+	require.NoError(t, err)
+	require.Equal(t, "2 metrics: cpu0@2020-09-13T12:26:40Z cpu1@2020-09-13T12:26:50Z", string(buf))
+
+	buf, err = s.Serialize(metrics[1])
+	require.NoError(t, err)
+	require.Equal(t, "1 metrics: cpu1@2020-09-13T12:26:50Z", string(buf))
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	_, err := NewSerializer("", "")
+	require.Error(t, err)
+
+	_, err = NewSerializer("{{ .Name ", "")
+	require.Error(t, err)
+
+	s, err := NewSerializer(`{{ .Unknown }}`, "")
+	require.NoError(t, err)
+	_, err = s.Serialize(metrics[0])
+	require.Error(t, err)
+}
@@ -0,0 +1,16 @@
+package telegraf
+
+// SecretStore is a store of secrets, such as passwords and tokens, that can be
+// referenced in the configuration of other plugins.
+type SecretStore interface {
+	PluginDescriber
+
+	// Get returns the secret stored for the key.
+	Get(key string) ([]byte, error)
+
+	// Set stores the secret for the key, replacing any existing secret.
+	Set(key string, value []byte) error
+
+	// List returns the keys of all secrets in the store.
+	List() ([]string, error)
+}
@@ -104,6 +104,74 @@ func Metrics() []telegraf.Metric {
 	return metrics
 }
 
+// Snapshot returns all registered stats as telegraf metrics like Metrics.
+// Unlike Metrics, calling Snapshot does not reset the average of timing stats.
+func Snapshot() []telegraf.Metric {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	now := time.Now()
+	metrics := make([]telegraf.Metric, 0, len(registry.stats))
+	for _, stats := range registry.stats {
+		if len(stats) == 0 {
+			continue
+		}
+		var name string
+		var tags map[string]string
+		fields := make(map[string]interface{}, len(stats))
+		for fieldname, stat := range stats {
+			name, tags = stat.Name(), stat.Tags()
+			if s, ok := stat.(*timingStat); ok {
+				fields[fieldname] = s.peek()
+			} else {
+				fields[fieldname] = stat.Get()
+			}
+		}
+		metrics = append(metrics, metric.New(name, tags, fields, now))
+	}
+	return metrics
+}
+
+// Values returns the current values of all stats registered with exactly the
+// given tags, keyed by measurement and field name.  Unlike Metrics, calling
+// Values does not reset the average of timing stats.
+func Values(tags map[string]string) map[string]map[string]int64 {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	values := make(map[string]map[string]int64)
+	for _, stats := range registry.stats {
+		for _, stat := range stats {
+			if !equalTags(stat.Tags(), tags) {
+				break
+			}
+			fields, ok := values[stat.Name()]
+			if !ok {
+				fields = make(map[string]int64, len(stats))
+				values[stat.Name()] = fields
+			}
+			if s, ok := stat.(*timingStat); ok {
+				fields[stat.FieldName()] = s.peek()
+			} else {
+				fields[stat.FieldName()] = stat.Get()
+			}
+		}
+	}
+	return values
+}
+
+func equalTags(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
 type Registry struct {
 	stats map[uint64]map[string]Stat
 	mu    sync.Mutex
@@ -3,7 +3,9 @@ package selfstat
 import (
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -211,3 +213,60 @@ func TestRegisterCopy(t *testing.T) {
 	tags["new"] = "value"
 	require.NotEqual(t, tags, stat.Tags())
 }
+
+func TestValues(t *testing.T) {
+	testLock.Lock()
+	defer testCleanup()
+
+	tags := map[string]string{"input": "mem", "alias": "mem1"}
+	gathered := Register("gather", "metrics_gathered", tags)
+	gatherTime := RegisterTiming("gather", "gather_time_ns", tags)
+	Register("gather", "metrics_gathered", map[string]string{"input": "mem"}).Incr(1)
+	Register("write", "metrics_written", tags).Incr(3)
+
+	gathered.Incr(5)
+	gatherTime.Incr(10)
+	gatherTime.Incr(20)
+
+	expected := map[string]map[string]int64{
+		"internal_gather": {
+			"metrics_gathered": 5,
+			"gather_time_ns":   15,
+		},
+		"internal_write": {
+			"metrics_written": 3,
+		},
+	}
+	require.Equal(t, expected, Values(tags))
+
+	// The average of timing stats is kept
+	require.Equal(t, expected, Values(tags))
+	require.Equal(t, int64(15), gatherTime.Get())
+}
+
+func TestSnapshot(t *testing.T) {
+	testLock.Lock()
+	defer testCleanup()
+
+	tags := map[string]string{"input": "mem"}
+	Register("gather", "metrics_gathered", tags).Incr(5)
+	gatherTime := RegisterTiming("gather", "gather_time_ns", tags)
+	gatherTime.Incr(10)
+	gatherTime.Incr(20)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"internal_gather",
+			map[string]string{"input": "mem"},
+			map[string]interface{}{
+				"metrics_gathered": int64(5),
+				"gather_time_ns":   int64(15),
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, Snapshot(), testutil.IgnoreTime())
+
+	// The average of timing stats is kept
+	require.Equal(t, int64(15), gatherTime.Get())
+}